@@ -6,10 +6,29 @@ import (
 	"strings"
 
 	"github.com/skeptycal/util/datatools/format"
+	"github.com/skeptycal/util/gofile"
 )
 
+// With no arguments, the list is read from stdin. Lists exported on
+// Windows are decoded from UTF-16 or Latin-1 and lose their BOM; CRLF
+// line endings are whitespace to GetDomainNames already.
 func main() {
-    list := strings.Join(os.Args[1:]," ")
-    out := format.GetDomainNames(list)
-    fmt.Println(out)
+	list := strings.Join(os.Args[1:], " ")
+	if len(os.Args) < 2 {
+		r, _, err := gofile.DecodeReader(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		data, err := gofile.ReadAll(r)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		list = string(data)
+	}
+	list = strings.TrimPrefix(list, "\ufeff")
+
+	out := format.GetDomainNames(list)
+	fmt.Println(out)
 }
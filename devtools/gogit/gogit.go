@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 	"unicode"
 
 	"github.com/skeptycal/util/gofile"
@@ -32,31 +33,25 @@ func IsAlphaNum(s string) bool {
 }
 
 func AddAll() error {
-	return Err(zsh.Status("git add --all"))
+	return run("add", "git add --all")
 }
 
 func Add(s ...string) error {
-	command := fmt.Sprintf("git add %s", strings.Join(s, " "))
-
-	if err := Err(zsh.Status(command)); err != nil {
-		return fmt.Errorf("error during command: %v", command)
-	}
-	return nil
+	return run("add", fmt.Sprintf("git add %s", strings.Join(s, " ")))
 }
 
 // Commit creates a commit with message
 func Commit(message string) error {
-	command := fmt.Sprintf(gitCommitFormatString, message)
-	return Err(zsh.Status(command))
+	return run("commit", fmt.Sprintf(gitCommitFormatString, message))
 }
 
 // CommitAll creates a commit with message that
 // contains all updated files.
 func CommitAll(message string) error {
-	if err := Err(AddAll()); err != nil {
+	if err := AddAll(); err != nil {
 		return err
 	}
-	return Err(Commit(message))
+	return Commit(message)
 }
 
 // GitInit initializes the Git environment in the current directory with:
@@ -64,15 +59,14 @@ func CommitAll(message string) error {
 //  git add --all
 //  git commit -m 'Initial Commit'
 func GitInit() error {
-	if err := Err(zsh.Status("git init")); err != nil {
+	if err := run("init", "git init"); err != nil {
 		return err
 	}
-	return Err(CommitAll("Initial Commit"))
+	return CommitAll("Initial Commit")
 }
 
 func PushTags() error {
-	command := fmt.Sprintf("git push %s --tags", RemoteName())
-	return Err(zsh.Status(command))
+	return run("push", fmt.Sprintf("git push %s --tags", RemoteName()))
 }
 
 func getVersionCommitHash() string {
@@ -94,7 +88,7 @@ func Tag(s string) error {
 	fmt.Printf("command: %s", command)
 
 	tag := s[1:]
-	return zsh.Status(fmt.Sprintf("git tag %s", tag))
+	return run("tag", fmt.Sprintf("git tag %s", tag))
 }
 
 // RemoteName gets the name of the remote branch, usually origin.
@@ -133,7 +127,39 @@ func Remote() string {
 	return list[1]
 }
 
-// Err calls error handling and logging routines
+// run runs command and passes a failure, with the operation
+// and command attached, to Err.
+func run(op, command string) error {
+	return Err(gofile.NewCmdError(op, command, zsh.Status(command)))
+}
+
+var (
+	handlerMu sync.RWMutex
+	handler   gofile.ErrorHandler // nil uses the gofile handler
+)
+
+// SetErrorHandler sets the handler used by Err for gogit errors and
+// returns the previous one. A nil h makes gogit use the gofile error
+// handler again (see gofile.SetErrorHandler).
+func SetErrorHandler(h gofile.ErrorHandler) gofile.ErrorHandler {
+	handlerMu.Lock()
+	defer handlerMu.Unlock()
+	prev := handler
+	handler = h
+	return prev
+}
+
+// Err passes non-nil errors to the gogit error handler, or to
+// gofile.Err if none is set.
 func Err(err error) error {
-	return gofile.Err(err)
+	if err == nil {
+		return nil
+	}
+	handlerMu.RLock()
+	h := handler
+	handlerMu.RUnlock()
+	if h == nil {
+		return gofile.Err(err)
+	}
+	return h.Handle(err)
 }
@@ -5,9 +5,9 @@ go 1.16
 require (
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/sirupsen/logrus v1.8.1
-	github.com/skeptycal/ansi v0.3.3 // indirect
+	github.com/skeptycal/ansi v0.3.3
 	github.com/skeptycal/util/stringutils v0.0.0-20210327131358-3c9cdad9bb2e
 	github.com/skeptycal/zsh v0.3.0
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
-	golang.org/x/sys v0.0.0-20210331175145-43e1dd70ce54 // indirect
+	golang.org/x/sys v0.0.0-20210331175145-43e1dd70ce54
 )
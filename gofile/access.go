@@ -0,0 +1,154 @@
+package gofile
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// AccessClass is the permission class that applies to the current
+// user for a given file.
+type AccessClass int
+
+const (
+	ClassOwner AccessClass = iota
+	ClassGroup
+	ClassOther
+	ClassRoot // the superuser; permission bits are mostly bypassed
+)
+
+var accessClassNames = []string{"owner", "group", "other", "root"}
+
+func (c AccessClass) String() string {
+	if c < 0 || int(c) >= len(accessClassNames) {
+		return fmt.Sprintf("AccessClass(%d)", int(c))
+	}
+	return accessClassNames[c]
+}
+
+// Permission is the outcome of one access check.
+type Permission struct {
+	// Allowed reports whether the current user has the permission.
+	Allowed bool
+
+	// Bit is the mode bit that granted or denied the permission,
+	// e.g. 0400 for owner read. It is 0 when the decision was not
+	// made by a single bit, such as root read and write access.
+	Bit os.FileMode
+}
+
+// AccessReport describes the effective permissions of the current
+// user on a file.
+type AccessReport struct {
+	Path  string
+	Mode  os.FileMode
+	Class AccessClass
+	Read  Permission
+	Write Permission
+	Exec  Permission
+}
+
+// CanRead reports whether the current user may read the file.
+func (r *AccessReport) CanRead() bool { return r.Read.Allowed }
+
+// CanWrite reports whether the current user may write the file.
+func (r *AccessReport) CanWrite() bool { return r.Write.Allowed }
+
+// CanExec reports whether the current user may execute the file,
+// or search it if it is a directory.
+func (r *AccessReport) CanExec() bool { return r.Exec.Allowed }
+
+func (r *AccessReport) String() string {
+	var sb strings.Builder
+	for _, p := range []struct {
+		c byte
+		p Permission
+	}{{'r', r.Read}, {'w', r.Write}, {'x', r.Exec}} {
+		if p.p.Allowed {
+			sb.WriteByte(p.c)
+		} else {
+			sb.WriteByte('-')
+		}
+	}
+	return fmt.Sprintf("%s: %s as %v (%v)", r.Path, sb.String(), r.Class, r.Mode.Perm())
+}
+
+// Access reports the effective read, write and execute permissions of
+// the current user on the named file, following symlinks.
+//
+// The check uses the effective uid, gid and supplementary groups of the
+// process against the owner, group and other permission bits, in the
+// same order as the kernel: only the first class that matches is used.
+// ACLs and read-only mounts are not taken into account.
+func Access(name string) (*AccessReport, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, newPathError("access", name, err)
+	}
+	return accessInfo(name, fi), nil
+}
+
+// accessInfo computes the AccessReport for fi.
+func accessInfo(name string, fi os.FileInfo) *AccessReport {
+	m := fi.Mode()
+	r := &AccessReport{Path: name, Mode: m, Class: accessClass(fi)}
+
+	if r.Class == ClassRoot {
+		r.Read = Permission{Allowed: true}
+		r.Write = Permission{Allowed: true}
+		// root may execute only if some execute bit is set,
+		// but may always search directories
+		r.Exec = Permission{Allowed: fi.IsDir() || m&0111 != 0}
+		return r
+	}
+
+	// the owner bits are shifted by 6, group by 3, other by 0
+	shift := uint(3 * (2 - int(r.Class)))
+	check := func(bit os.FileMode) Permission {
+		bit <<= shift
+		return Permission{Allowed: m&bit != 0, Bit: bit}
+	}
+
+	r.Read = check(04)
+	r.Write = check(02)
+	r.Exec = check(01)
+	return r
+}
+
+// accessClass returns the permission class of the current user for fi.
+func accessClass(fi os.FileInfo) AccessClass {
+	uid, gid, ok := fileOwner(fi)
+	if !ok {
+		// no ownership information on this platform
+		return ClassOwner
+	}
+
+	euid := os.Geteuid()
+	switch {
+	case euid == 0:
+		return ClassRoot
+	case uid == euid:
+		return ClassOwner
+	case inGroup(gid):
+		return ClassGroup
+	}
+	return ClassOther
+}
+
+// inGroup reports whether the process is a member of gid, either
+// as its effective group or as one of its supplementary groups.
+func inGroup(gid int) bool {
+	if gid == os.Getegid() {
+		return true
+	}
+	groups, err := os.Getgroups()
+	if err != nil {
+		return false
+	}
+	for _, g := range groups {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
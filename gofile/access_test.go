@@ -0,0 +1,133 @@
+package gofile
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAccess(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permission bits do not apply to root")
+	}
+	dir := t.TempDir()
+
+	tests := []struct {
+		name  string
+		perm  os.FileMode
+		read  bool
+		write bool
+		exec  bool
+		bit   os.FileMode // bit deciding read access
+	}{
+		{"owner only", 0600, true, true, false, 0400},
+		{"others only", 0004, false, false, false, 0400},
+		{"executable", 0500, true, false, true, 0400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(dir, tt.name)
+			if err := ioutil.WriteFile(name, nil, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(name, tt.perm); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Access(name)
+			if err != nil {
+				t.Fatalf("Access() error = %v", err)
+			}
+			if got.Class != ClassOwner {
+				t.Errorf("Access() class = %v, want %v", got.Class, ClassOwner)
+			}
+			if got.CanRead() != tt.read || got.CanWrite() != tt.write || got.CanExec() != tt.exec {
+				t.Errorf("Access() = %v, want read %v write %v exec %v", got, tt.read, tt.write, tt.exec)
+			}
+			if got.Read.Bit != tt.bit {
+				t.Errorf("Access() read bit = %o, want %o", got.Read.Bit, tt.bit)
+			}
+		})
+	}
+}
+
+func TestAccessRoot(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("not running as root")
+	}
+	dir := t.TempDir()
+
+	tests := []struct {
+		name string
+		perm os.FileMode
+		dir  bool
+		exec bool
+	}{
+		{"plain", 0644, false, false},
+		{"no bits", 0000, false, false},
+		{"others exec", 0601, false, true},
+		{"closed dir", 0000, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(dir, tt.name)
+			if tt.dir {
+				if err := os.Mkdir(name, 0700); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := ioutil.WriteFile(name, nil, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(name, tt.perm); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Access(name)
+			if err != nil {
+				t.Fatalf("Access() error = %v", err)
+			}
+			if got.Class != ClassRoot {
+				t.Errorf("Access() class = %v, want %v", got.Class, ClassRoot)
+			}
+			if !got.CanRead() || !got.CanWrite() || got.CanExec() != tt.exec {
+				t.Errorf("Access() = %v, want read true write true exec %v", got, tt.exec)
+			}
+		})
+	}
+
+	// root reads files without any permission bits
+	name := filepath.Join(dir, "no bits")
+	if _, err := StatCheck(name); err != nil {
+		t.Errorf("StatCheck() error = %v, want nil", err)
+	}
+}
+
+func TestStatCheckPermissions(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permission bits do not apply to root")
+	}
+	dir := t.TempDir()
+
+	// readable by the owner but not by others
+	own := filepath.Join(dir, "own")
+	if err := ioutil.WriteFile(own, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := StatCheck(own); err != nil {
+		t.Errorf("StatCheck() error = %v, want nil", err)
+	}
+
+	// readable by others but not by the owner
+	other := filepath.Join(dir, "other")
+	if err := ioutil.WriteFile(other, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(other, 0004); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := StatCheck(other); !errors.Is(err, ErrPermission) {
+		t.Errorf("StatCheck() error = %v, want %v", err, ErrPermission)
+	}
+}
@@ -0,0 +1,701 @@
+package gofile
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveFormat is the file format of an archive.
+type ArchiveFormat int
+
+const (
+	// FormatAuto selects the format from the archive name when
+	// creating and from its contents when extracting.
+	FormatAuto ArchiveFormat = iota
+	FormatTar
+	FormatTarGz
+	FormatZip
+)
+
+var archiveFormatNames = []string{"auto", "tar", "tar.gz", "zip"}
+
+func (f ArchiveFormat) String() string {
+	if f < 0 || int(f) >= len(archiveFormatNames) {
+		return fmt.Sprintf("ArchiveFormat(%d)", int(f))
+	}
+	return archiveFormatNames[f]
+}
+
+// Default extraction limits, used when ArchiveOptions.MaxSize or
+// MaxEntries is 0.
+const (
+	DefaultMaxArchiveSize    = 1 << 30
+	DefaultMaxArchiveEntries = 100000
+)
+
+// maxSymlinkSize bounds the target of a symlink stored in a zip file.
+const maxSymlinkSize = 4096
+
+// ArchiveOptions configures CreateArchive and ExtractArchive.
+type ArchiveOptions struct {
+	Format ArchiveFormat
+
+	// Filters must all match for an entry to be archived or extracted.
+	// Like Walk filters they never prevent descending into a directory.
+	// Entry.Rel is the slash separated name in the archive.
+	Filters []Filter
+
+	// Exclude skips entries that match any of the filters. An excluded
+	// directory is skipped along with everything below it.
+	Exclude []Filter
+
+	// Overwrite replaces existing files when extracting. Otherwise an
+	// existing file is an error wrapping ErrExists.
+	Overwrite bool
+
+	// MaxSize limits the total number of bytes extracted and
+	// MaxEntries the number of entries, to guard against
+	// decompression bombs. 0 uses DefaultMaxArchiveSize and
+	// DefaultMaxArchiveEntries; a negative value means no limit.
+	MaxSize    int64
+	MaxEntries int
+}
+
+func archiveOptions(opts *ArchiveOptions) ArchiveOptions {
+	var o ArchiveOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxSize == 0 {
+		o.MaxSize = DefaultMaxArchiveSize
+	}
+	if o.MaxEntries == 0 {
+		o.MaxEntries = DefaultMaxArchiveEntries
+	}
+	return o
+}
+
+// excluded reports whether e matches any of the Exclude filters.
+func (o ArchiveOptions) excluded(e Entry) bool {
+	for _, f := range o.Exclude {
+		if f(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatOf returns the format of an archive from its name.
+func formatOf(name string) (ArchiveFormat, bool) {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return FormatTarGz, true
+	case strings.HasSuffix(name, ".tar"):
+		return FormatTar, true
+	case strings.HasSuffix(name, ".zip"):
+		return FormatZip, true
+	}
+	return FormatAuto, false
+}
+
+// sniffFormat returns the format of an archive from its first bytes.
+func sniffFormat(r io.ReaderAt) (ArchiveFormat, bool) {
+	var head [512]byte
+	n, _ := r.ReadAt(head[:], 0)
+	b := head[:n]
+	switch {
+	case bytes.HasPrefix(b, []byte{0x1f, 0x8b}):
+		return FormatTarGz, true
+	case bytes.HasPrefix(b, []byte("PK\x03\x04")), bytes.HasPrefix(b, []byte("PK\x05\x06")):
+		return FormatZip, true
+	case n >= 262 && bytes.HasPrefix(b[257:], []byte("ustar")):
+		return FormatTar, true
+	}
+	return FormatAuto, false
+}
+
+// CreateArchive writes the tree at root to the archive file, which is
+// replaced atomically. Entries are named by their path relative to
+// root; root itself is not included. Directories, regular files and
+// symlinks are stored with their permissions and modification times;
+// symlinks are not followed. The format is taken from the name of the
+// archive (.tar, .tar.gz, .tgz or .zip) unless opts.Format is set.
+//
+// opts may be nil.
+func CreateArchive(archive, root string, opts *ArchiveOptions) error {
+	o := archiveOptions(opts)
+
+	format := o.Format
+	if format == FormatAuto {
+		var ok bool
+		if format, ok = formatOf(archive); !ok {
+			return newPathError("archive", archive, errors.New("unknown archive format"))
+		}
+	}
+
+	a, err := CreateAtomic(archive, 0644)
+	if err != nil {
+		return newPathError("archive", archive, err)
+	}
+	defer a.Abort()
+
+	// never add the archive to itself
+	var skip []os.FileInfo
+	if fi, err := a.Stat(); err == nil {
+		skip = append(skip, fi)
+	}
+	if fi, err := os.Stat(a.Target()); err == nil {
+		skip = append(skip, fi)
+	}
+
+	var w archiveWriter
+	switch format {
+	case FormatTar:
+		w = &tarWriter{tw: tar.NewWriter(a)}
+	case FormatTarGz:
+		gz := gzip.NewWriter(a)
+		w = &tarWriter{tw: tar.NewWriter(gz), gz: gz}
+	case FormatZip:
+		w = &zipWriter{zw: zip.NewWriter(a)}
+	default:
+		return newPathError("archive", archive, fmt.Errorf("unsupported format %v", format))
+	}
+
+	err = Walk(root, &WalkOptions{FS: OS}, func(e Entry) error {
+		if e.Rel == "." {
+			return nil
+		}
+		if o.excluded(e) {
+			if e.IsDir() {
+				return SkipDir
+			}
+			return nil
+		}
+		if !matchAll(o.Filters, e) {
+			return nil
+		}
+		for _, fi := range skip {
+			if os.SameFile(fi, e.Info) {
+				return nil
+			}
+		}
+
+		var err error
+		switch e.Type() {
+		case TypeDir:
+			err = w.add(e, "", nil)
+		case TypeSymlink:
+			var link string
+			if link, err = os.Readlink(e.Path); err == nil {
+				err = w.add(e, link, nil)
+			}
+		case TypeFile:
+			var f *os.File
+			if f, err = os.Open(e.Path); err == nil {
+				err = w.add(e, "", f)
+				f.Close()
+			}
+		}
+		if err != nil {
+			return newPathError("archive", e.Path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return newPathError("archive", archive, err)
+	}
+	if err := a.Close(); err != nil {
+		return newPathError("archive", archive, err)
+	}
+	return nil
+}
+
+// archiveWriter adds entries to an archive.
+type archiveWriter interface {
+	// add writes e, with the symlink target link or the contents r.
+	add(e Entry, link string, r io.Reader) error
+	Close() error
+}
+
+type tarWriter struct {
+	tw *tar.Writer
+	gz *gzip.Writer // nil for plain tar
+}
+
+func (w *tarWriter) add(e Entry, link string, r io.Reader) error {
+	hdr, err := tar.FileInfoHeader(e.Info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = e.Rel
+	if e.Info.IsDir() {
+		hdr.Name += "/"
+	}
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if r != nil {
+		_, err = CopyStream(w.tw, r)
+	}
+	return err
+}
+
+func (w *tarWriter) Close() error {
+	err := w.tw.Close()
+	if w.gz != nil {
+		if gerr := w.gz.Close(); err == nil {
+			err = gerr
+		}
+	}
+	return err
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipWriter) add(e Entry, link string, r io.Reader) error {
+	hdr, err := zip.FileInfoHeader(e.Info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = e.Rel
+	if e.Info.IsDir() {
+		hdr.Name += "/"
+	} else if r != nil {
+		hdr.Method = zip.Deflate
+	}
+
+	zf, err := w.zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	switch {
+	case link != "":
+		_, err = io.WriteString(zf, link)
+	case r != nil:
+		_, err = CopyStream(zf, r)
+	}
+	return err
+}
+
+func (w *zipWriter) Close() error { return w.zw.Close() }
+
+// ExtractArchive extracts the archive file into dir, creating dir if
+// needed. Permissions and modification times are restored; setuid and
+// setgid bits and ownership are not. The format is detected from the
+// contents of the archive unless opts.Format is set.
+//
+// Every entry must stay below dir: names containing "..", absolute
+// names, symlinks pointing outside dir and entries that would be
+// written through such a symlink are refused with an error wrapping
+// ErrOutsideRoot. Exceeding opts.MaxSize or opts.MaxEntries stops the
+// extraction with an error wrapping ErrArchiveLimit. Entries extracted
+// before an error are left in place.
+//
+// opts may be nil.
+func ExtractArchive(archive, dir string, opts *ArchiveOptions) error {
+	o := archiveOptions(opts)
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return newPathError("extract", archive, err)
+	}
+	defer f.Close()
+
+	format := o.Format
+	if format == FormatAuto {
+		var ok bool
+		if format, ok = sniffFormat(f); !ok {
+			if format, ok = formatOf(archive); !ok {
+				return newPathError("extract", archive, errors.New("unknown archive format"))
+			}
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return newPathError("extract", dir, err)
+	}
+	x := &extractor{dir: filepath.Clean(dir), o: o}
+	if x.real, err = evalSymlinks(x.dir); err != nil {
+		return newPathError("extract", dir, err)
+	}
+
+	switch format {
+	case FormatTar:
+		err = x.tar(f)
+	case FormatTarGz:
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(f); err == nil {
+			err = x.tar(gz)
+		}
+	case FormatZip:
+		var fi os.FileInfo
+		if fi, err = f.Stat(); err == nil {
+			var zr *zip.Reader
+			if zr, err = zip.NewReader(f, fi.Size()); err == nil {
+				err = x.zip(zr)
+			}
+		}
+	default:
+		err = fmt.Errorf("unsupported format %v", format)
+	}
+
+	// restore directories even after an error, so that no
+	// directory is left with the temporary 0700 mode
+	if derr := x.finish(); err == nil {
+		err = derr
+	}
+
+	var pe *PathError
+	if err != nil && !errors.As(err, &pe) {
+		err = newPathError("extract", archive, err)
+	}
+	return err
+}
+
+// archiveEntry is an entry read from an archive.
+type archiveEntry struct {
+	name     string
+	info     os.FileInfo
+	link     string // symlink target or hard link name
+	hardlink bool
+	open     func() (io.ReadCloser, error)
+}
+
+// extractor writes archive entries below dir.
+type extractor struct {
+	dir     string
+	real    string // dir with symlinks resolved
+	o       ArchiveOptions
+	entries int
+	written int64
+	pruned  []string // excluded directories, with a trailing slash
+	dirs    []archiveDir
+	links   []string // extracted symlinks
+}
+
+// archiveDir is an extracted directory whose metadata is applied last.
+type archiveDir struct {
+	path string
+	info os.FileInfo
+}
+
+func (x *extractor) tar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = x.extract(archiveEntry{
+			name:     hdr.Name,
+			info:     hdr.FileInfo(),
+			link:     hdr.Linkname,
+			hardlink: hdr.Typeflag == tar.TypeLink,
+			open:     func() (io.ReadCloser, error) { return ioutil.NopCloser(tr), nil },
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (x *extractor) zip(zr *zip.Reader) error {
+	if x.o.MaxEntries > 0 && len(zr.File) > x.o.MaxEntries {
+		return fmt.Errorf("%d entries: %w", len(zr.File), ErrArchiveLimit)
+	}
+	for _, zf := range zr.File {
+		ae := archiveEntry{name: zf.Name, info: zf.FileInfo(), open: zf.Open}
+		if ae.info.Mode()&os.ModeSymlink != 0 {
+			link, err := readSymlinkEntry(zf)
+			if err != nil {
+				return newPathError("extract", zf.Name, err)
+			}
+			ae.link = link
+		}
+		if err := x.extract(ae); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSymlinkEntry returns the target of a symlink stored in a zip file.
+func readSymlinkEntry(zf *zip.File) (string, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(rc, maxSymlinkSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(b) > maxSymlinkSize {
+		return "", errors.New("symlink target too long")
+	}
+	return string(b), nil
+}
+
+// archivePath returns the clean slash separated form of an entry name.
+// ok is false for the root directory itself.
+func archivePath(name string) (rel string, ok bool, err error) {
+	rel = strings.TrimSuffix(name, "/")
+	for strings.HasPrefix(rel, "./") {
+		rel = rel[2:]
+	}
+	if rel == "" || rel == "." {
+		return "", false, nil
+	}
+	if !fs.ValidPath(rel) {
+		return "", false, ErrOutsideRoot
+	}
+	return rel, true, nil
+}
+
+// extract writes a single entry.
+func (x *extractor) extract(ae archiveEntry) error {
+	rel, ok, err := archivePath(ae.name)
+	if err != nil {
+		return newPathError("extract", ae.name, err)
+	}
+	if !ok {
+		return nil
+	}
+	for _, p := range x.pruned {
+		if strings.HasPrefix(rel, p) {
+			return nil
+		}
+	}
+
+	mode := ae.info.Mode()
+	e := Entry{Path: filepath.Join(x.dir, filepath.FromSlash(rel)), Rel: rel, Info: ae.info, Depth: strings.Count(rel, "/") + 1}
+	if x.o.excluded(e) {
+		if mode.IsDir() {
+			x.pruned = append(x.pruned, rel+"/")
+		}
+		return nil
+	}
+	if !matchAll(x.o.Filters, e) {
+		return nil
+	}
+
+	if x.entries++; x.o.MaxEntries > 0 && x.entries > x.o.MaxEntries {
+		return newPathError("extract", ae.name, fmt.Errorf("more than %d entries: %w", x.o.MaxEntries, ErrArchiveLimit))
+	}
+
+	target, err := SafeJoin(x.dir, filepath.FromSlash(rel))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+		return newPathError("extract", target, err)
+	}
+
+	switch {
+	case ae.hardlink:
+		err = x.hardlink(target, ae.link)
+	case mode.IsDir():
+		err = x.mkdir(target, ae.info)
+	case mode&os.ModeSymlink != 0:
+		err = x.symlink(target, ae.link)
+	case mode.IsRegular():
+		err = x.file(target, ae)
+	default:
+		// devices, pipes and sockets are not extracted
+		return nil
+	}
+	if err != nil {
+		return newPathError("extract", target, err)
+	}
+	return nil
+}
+
+// replace removes target, if it exists and Overwrite is set, so that
+// a link can be created in its place.
+func (x *extractor) replace(target string) error {
+	fi, err := os.Lstat(target)
+	switch {
+	case err != nil:
+		return nil
+	case !x.o.Overwrite:
+		return ErrExists
+	case fi.IsDir():
+		return ErrIsDir
+	}
+	return os.Remove(target)
+}
+
+func (x *extractor) mkdir(target string, fi os.FileInfo) error {
+	// writable until finish applies the real mode
+	if err := os.Mkdir(target, 0700); err != nil && !isDir(target) {
+		return err
+	}
+	x.dirs = append(x.dirs, archiveDir{target, fi})
+	return nil
+}
+
+func (x *extractor) symlink(target, link string) error {
+	if err := x.checkLink(target, link); err != nil {
+		return err
+	}
+	if err := x.replace(target); err != nil {
+		return err
+	}
+	if err := os.Symlink(link, target); err != nil {
+		return err
+	}
+	x.links = append(x.links, target)
+	return nil
+}
+
+// checkLink returns an error wrapping ErrOutsideRoot if a symlink at
+// target pointing to link would resolve outside dir. The link is
+// followed one element at a time through the symlinks already on
+// disk, as the kernel does, so "b/.." is the parent of wherever b
+// points and not the directory holding b.
+func (x *extractor) checkLink(target, link string) error {
+	if filepath.IsAbs(link) {
+		return fmt.Errorf("symlink to %s: %w", link, ErrOutsideRoot)
+	}
+	p, err := evalSymlinks(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	for _, elem := range strings.Split(filepath.ToSlash(link), "/") {
+		switch elem {
+		case "", ".":
+		case "..":
+			p = filepath.Dir(p)
+		default:
+			if p, err = evalSymlinks(filepath.Join(p, elem)); err != nil {
+				return err
+			}
+		}
+	}
+	if !within(x.real, p) {
+		return fmt.Errorf("symlink to %s: %w", link, ErrOutsideRoot)
+	}
+	return nil
+}
+
+func (x *extractor) hardlink(target, link string) error {
+	rel, ok, err := archivePath(link)
+	if err == nil && !ok {
+		err = ErrIsDir
+	}
+	if err != nil {
+		return fmt.Errorf("link to %s: %w", link, err)
+	}
+	src, err := SafeJoin(x.dir, filepath.FromSlash(rel))
+	if err != nil {
+		return err
+	}
+	if err := x.replace(target); err != nil {
+		return err
+	}
+	return os.Link(src, target)
+}
+
+func (x *extractor) file(target string, ae archiveEntry) error {
+	rc, err := ae.open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var w io.Writer
+	var commit func() error
+	var abort func()
+	if x.o.Overwrite {
+		a, err := CreateAtomic(target, 0600)
+		if err != nil {
+			return err
+		}
+		w, commit, abort = a, a.Close, func() { a.Abort() }
+	} else {
+		f, err := CreateSafeWith(target, CollisionFail)
+		if err != nil {
+			return err
+		}
+		w, commit = f, f.Close
+		abort = func() {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}
+
+	var r io.Reader = rc
+	if x.o.MaxSize > 0 {
+		r = io.LimitReader(rc, x.o.MaxSize-x.written+1)
+	}
+	n, err := CopyStream(w, r)
+	x.written += n
+	if err == nil && x.o.MaxSize > 0 && x.written > x.o.MaxSize {
+		err = fmt.Errorf("more than %d bytes: %w", x.o.MaxSize, ErrArchiveLimit)
+	}
+	if err != nil {
+		abort()
+		return err
+	}
+	if err := commit(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(target, ae.info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(target, ae.info.ModTime(), ae.info.ModTime())
+}
+
+// finish checks the extracted symlinks again, since a later entry may
+// have changed where an earlier one resolves, and removes those that
+// now point outside dir. It then applies the mode and times of the
+// extracted directories, deepest first so that a read-only parent is
+// set last.
+func (x *extractor) finish() error {
+	var err error
+	for _, link := range x.links {
+		target, rerr := os.Readlink(link)
+		if rerr != nil {
+			continue
+		}
+		if cerr := x.checkLink(link, target); cerr != nil {
+			os.Remove(link)
+			if err == nil {
+				err = newPathError("extract", link, cerr)
+			}
+		}
+	}
+
+	for i := len(x.dirs) - 1; i >= 0; i-- {
+		d := x.dirs[i]
+		mode := d.info.Mode() & (os.ModePerm | os.ModeSticky)
+		if err := os.Chmod(d.path, mode); err != nil {
+			return newPathError("extract", d.path, err)
+		}
+		if err := os.Chtimes(d.path, d.info.ModTime(), d.info.ModTime()); err != nil {
+			return newPathError("extract", d.path, err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,327 @@
+package gofile
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// archiveTree creates a tree with varied modes, times and a symlink.
+func archiveTree(t *testing.T, dir string) {
+	t.Helper()
+	makeTree(t, dir, map[string]string{
+		"README":         "read me",
+		"bin/run.sh":     "#!/bin/sh\necho hi\n",
+		"data/x.log":     "log",
+		"data/deep/y":    strings.Repeat("y", 10000),
+		"private/secret": "s3cr3t",
+	})
+	if err := os.Symlink("bin/run.sh", filepath.Join(dir, "run")); err != nil {
+		t.Fatal(err)
+	}
+	for name, mode := range map[string]os.FileMode{
+		"bin/run.sh":     0755,
+		"private/secret": 0600,
+		"private":        0700,
+		"data/deep":      0750,
+	} {
+		if err := os.Chmod(filepath.Join(dir, filepath.FromSlash(name)), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// archives store whole seconds, so use distinct whole second times
+	mtime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	for i, name := range walkRel(t, dir, &WalkOptions{Filters: []Filter{OfType(TypeFile | TypeDir)}}) {
+		mtime := mtime.Add(time.Duration(i) * time.Hour)
+		if err := os.Chtimes(filepath.Join(dir, filepath.FromSlash(name)), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// treeState describes every entry below dir for comparisons.
+func treeState(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	state := make(map[string]string)
+	err := Walk(dir, nil, func(e Entry) error {
+		if e.Rel == "." {
+			return nil
+		}
+		s := e.Info.Mode().String()
+		switch e.Type() {
+		case TypeFile:
+			data, err := ioutil.ReadFile(e.Path)
+			if err != nil {
+				return err
+			}
+			s += " " + e.Info.ModTime().UTC().Format(time.RFC3339) + " " + string(data)
+		case TypeDir:
+			s += " " + e.Info.ModTime().UTC().Format(time.RFC3339)
+		case TypeSymlink:
+			link, err := os.Readlink(e.Path)
+			if err != nil {
+				return err
+			}
+			s += " -> " + link
+		}
+		state[e.Rel] = s
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	archiveTree(t, src)
+	want := treeState(t, src)
+
+	for _, name := range []string{"out.tar", "out.tar.gz", "out.tgz", "out.zip"} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			archive := filepath.Join(dir, name)
+			if err := CreateArchive(archive, src, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			// detected from the contents, not the name
+			renamed := filepath.Join(dir, "archive.bin")
+			if err := os.Rename(archive, renamed); err != nil {
+				t.Fatal(err)
+			}
+
+			dst := filepath.Join(dir, "out")
+			if err := ExtractArchive(renamed, dst, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := treeState(t, dst); !reflect.DeepEqual(got, want) {
+				t.Errorf("extracted tree differs:\ngot  %v\nwant %v", got, want)
+			}
+		})
+	}
+}
+
+func TestArchiveFilters(t *testing.T) {
+	src := t.TempDir()
+	archiveTree(t, src)
+	opts := &ArchiveOptions{
+		Filters: []Filter{OfType(TypeFile | TypeDir)},
+		Exclude: []Filter{MatchGlob("*.log"), MatchGlob("private")},
+	}
+	want := []string{".", "README", "bin", "bin/run.sh", "data", "data/deep", "data/deep/y"}
+
+	t.Run("create", func(t *testing.T) {
+		// the archive is written into the tree it archives
+		archive := filepath.Join(src, "self.zip")
+		if err := CreateArchive(archive, src, opts); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(archive)
+
+		dst := t.TempDir()
+		if err := ExtractArchive(archive, dst, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got := walkRel(t, dst, nil); !reflect.DeepEqual(got, want) {
+			t.Errorf("extracted %v, want %v", got, want)
+		}
+	})
+
+	t.Run("extract", func(t *testing.T) {
+		archive := filepath.Join(t.TempDir(), "all.tar")
+		if err := CreateArchive(archive, src, nil); err != nil {
+			t.Fatal(err)
+		}
+		dst := t.TempDir()
+		if err := ExtractArchive(archive, dst, opts); err != nil {
+			t.Fatal(err)
+		}
+		if got := walkRel(t, dst, nil); !reflect.DeepEqual(got, want) {
+			t.Errorf("extracted %v, want %v", got, want)
+		}
+	})
+}
+
+type tarEntry struct {
+	hdr  tar.Header
+	data string
+}
+
+func writeTar(t *testing.T, name string, entries ...tarEntry) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := e.hdr
+		if hdr.Mode == 0 {
+			hdr.Mode = 0644
+		}
+		if hdr.Typeflag == 0 {
+			hdr.Typeflag = tar.TypeReg
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(e.data))
+		}
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeZip(t *testing.T, name string, files map[string]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractArchiveUnsafe(t *testing.T) {
+	file := func(name string) tarEntry { return tarEntry{tar.Header{Name: name}, "evil"} }
+	symlink := func(name, link string) tarEntry {
+		return tarEntry{hdr: tar.Header{Name: name, Typeflag: tar.TypeSymlink, Linkname: link}}
+	}
+	hardlink := func(name, link string) tarEntry {
+		return tarEntry{hdr: tar.Header{Name: name, Typeflag: tar.TypeLink, Linkname: link}}
+	}
+
+	tests := []struct {
+		name    string
+		entries []tarEntry
+	}{
+		{"parent", []tarEntry{file("../evil")}},
+		{"nested parent", []tarEntry{file("ok/../../evil")}},
+		{"absolute", []tarEntry{file("/tmp/evil")}},
+		{"absolute symlink", []tarEntry{symlink("link", "/etc")}},
+		{"escaping symlink", []tarEntry{symlink("link", "../..")}},
+		{"write through symlinks", []tarEntry{symlink("sub/l1", ".."), symlink("l2", "sub/l1/.."), file("l2/evil")}},
+		{"symlink through symlink", []tarEntry{symlink("b", "."), symlink("a", "b/..")}},
+		{"symlink retargeted later", []tarEntry{symlink("c", "d/.."), symlink("d", ".")}},
+		{"escaping hard link", []tarEntry{hardlink("link", "../outside")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := t.TempDir()
+			archive := filepath.Join(base, "evil.tar")
+			writeTar(t, archive, tt.entries...)
+
+			dst := filepath.Join(base, "a", "b")
+			err := ExtractArchive(archive, dst, nil)
+			if !errors.Is(err, ErrOutsideRoot) {
+				t.Errorf("ExtractArchive() error = %v, want %v", err, ErrOutsideRoot)
+			}
+			if exists(filepath.Join(base, "evil")) || exists(filepath.Join(base, "a", "evil")) {
+				t.Errorf("file written outside the target directory")
+			}
+
+			// no symlink is left pointing outside
+			filepath.Walk(dst, func(p string, fi os.FileInfo, err error) error {
+				if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+					return nil
+				}
+				real, err := filepath.EvalSymlinks(p)
+				if err == nil && !within(dst, real) {
+					t.Errorf("symlink %s resolves to %s, outside the target directory", p, real)
+				}
+				return nil
+			})
+		})
+	}
+
+	t.Run("zip", func(t *testing.T) {
+		base := t.TempDir()
+		archive := filepath.Join(base, "evil.zip")
+		writeZip(t, archive, map[string]string{"../evil": "evil"})
+		if err := ExtractArchive(archive, filepath.Join(base, "out"), nil); !errors.Is(err, ErrOutsideRoot) {
+			t.Errorf("ExtractArchive() error = %v, want %v", err, ErrOutsideRoot)
+		}
+		if exists(filepath.Join(base, "evil")) {
+			t.Errorf("file written outside the target directory")
+		}
+	})
+}
+
+func TestExtractArchiveLimits(t *testing.T) {
+	dir := t.TempDir()
+	big := filepath.Join(dir, "big.zip")
+	writeZip(t, big, map[string]string{"zeros": strings.Repeat("\x00", 1<<20)})
+	many := filepath.Join(dir, "many.tar")
+	writeTar(t, many, tarEntry{tar.Header{Name: "a"}, "a"}, tarEntry{tar.Header{Name: "b"}, "b"}, tarEntry{tar.Header{Name: "c"}, "c"})
+
+	tests := []struct {
+		name    string
+		archive string
+		opts    *ArchiveOptions
+		wantErr error
+	}{
+		{"size", big, &ArchiveOptions{MaxSize: 1000}, ErrArchiveLimit},
+		{"size unlimited", big, &ArchiveOptions{MaxSize: -1}, nil},
+		{"size exact", big, &ArchiveOptions{MaxSize: 1 << 20}, nil},
+		{"entries", many, &ArchiveOptions{MaxEntries: 2}, ErrArchiveLimit},
+		{"entries exact", many, &ArchiveOptions{MaxEntries: 3}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := t.TempDir()
+			err := ExtractArchive(tt.archive, dst, tt.opts)
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("ExtractArchive() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && exists(filepath.Join(dst, "zeros")) {
+				t.Errorf("partial file left behind")
+			}
+		})
+	}
+}
+
+func TestExtractArchiveOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "a.tar")
+	writeTar(t, archive, tarEntry{tar.Header{Name: "f"}, "new"})
+
+	dst := filepath.Join(dir, "out")
+	makeTree(t, dst, map[string]string{"f": "old"})
+
+	if err := ExtractArchive(archive, dst, nil); !errors.Is(err, ErrExists) {
+		t.Errorf("ExtractArchive() error = %v, want %v", err, ErrExists)
+	}
+	if err := ExtractArchive(archive, dst, &ArchiveOptions{Overwrite: true}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dst, "f")); string(data) != "new" {
+		t.Errorf("f = %q, want %q", data, "new")
+	}
+}
@@ -0,0 +1,202 @@
+package gofile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+)
+
+// AtomicFile is a file that is written to a temporary location in the
+// same directory as the target and moved into place when it is closed.
+//
+// Readers of the target never see a partially written file. Either the
+// previous contents remain or the complete new contents are present.
+// If the process crashes before Close, the target is left untouched.
+// The temporary file is removed by CleanupTemp, including when the
+// process is interrupted after CleanupOnSignal was called.
+type AtomicFile struct {
+	*os.File
+	target string
+	perm   os.FileMode
+	keep   bool // the target exists and perm is its mode
+	done   bool
+}
+
+// CreateAtomic returns an AtomicFile that will replace filename when
+// Close is called. If filename already exists, its mode is preserved;
+// otherwise the new file is created with perm, less the umask, as
+// os.OpenFile would.
+//
+// If filename is a symlink, the file it points to is replaced and the
+// link itself is left in place.
+func CreateAtomic(filename string, perm os.FileMode) (*AtomicFile, error) {
+	target := filename
+	if resolved, err := filepath.EvalSymlinks(filename); err == nil {
+		target = resolved
+	}
+
+	keep := false
+	if fi, err := os.Stat(target); err == nil {
+		if fi.IsDir() {
+			return nil, &os.PathError{Op: "create", Path: filename, Err: errors.New("is a directory")}
+		}
+		perm, keep = fi.Mode().Perm(), true
+	}
+
+	dir, base := filepath.Split(target)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := createAtomicTemp(dir, base, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AtomicFile{File: f, target: target, perm: perm, keep: keep}, nil
+}
+
+// createAtomicTemp creates a new temporary file for base in dir with
+// perm, less the umask, and adds it to tempFiles. Unlike
+// ioutil.TempFile it does not force mode 0600, so a new target gets
+// the mode a direct create would have given it.
+func createAtomicTemp(dir, base string, perm os.FileMode) (*os.File, error) {
+	prefix := filepath.Join(dir, "."+base+".tmp-"+strconv.Itoa(os.Getpid())+"-")
+	for i := 0; i < maxCollisionAttempts; i++ {
+		name := prefix + strconv.FormatUint(atomic.AddUint64(&tmpCounter, 1), 10)
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := tempFiles.Track(name); err != nil {
+			f.Close()
+			os.Remove(name)
+			return nil, err
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("no free temporary name for %s after %d attempts: %w", base, maxCollisionAttempts, ErrExists)
+}
+
+// Close flushes the temporary file to disk, renames it over the target
+// and syncs the parent directory so the rename itself is durable.
+//
+// If any step fails, the temporary file is removed and the target is
+// left unchanged. Calling Close after Close or Abort is a no-op.
+func (a *AtomicFile) Close() error {
+	if a.done {
+		return nil
+	}
+	a.done = true
+
+	tmp := a.File.Name()
+	defer tempFiles.Forget(tmp)
+
+	if err := a.commit(); err != nil {
+		a.File.Close()
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (a *AtomicFile) commit() error {
+	// the umask may have cleared bits of the existing mode
+	if a.keep {
+		if err := a.File.Chmod(a.perm); err != nil {
+			return err
+		}
+	}
+	if err := a.File.Sync(); err != nil {
+		return err
+	}
+	if err := a.File.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(a.File.Name(), a.target); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(a.target))
+}
+
+// Abort discards everything written so far and removes the temporary
+// file. The target is left unchanged. Abort after Close is a no-op, so
+// it is safe to defer Abort and call Close on success.
+func (a *AtomicFile) Abort() error {
+	if a.done {
+		return nil
+	}
+	a.done = true
+	defer tempFiles.Forget(a.File.Name())
+
+	a.File.Close()
+	return os.Remove(a.File.Name())
+}
+
+// Target returns the path that will be replaced when Close is called.
+func (a *AtomicFile) Target() string { return a.target }
+
+// WriteFileAtomic writes data to filename atomically. It is the atomic
+// counterpart of ioutil.WriteFile: if filename already exists its mode
+// is preserved, otherwise it is created with perm (before umask).
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	return On(nil).WriteFileAtomic(filename, data, perm)
+}
+
+func writeFileAtomicOS(filename string, data []byte, perm os.FileMode) error {
+	f, err := CreateAtomic(filename, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// WriteAtomic copies r to filename atomically. If filename already exists
+// its mode is preserved, otherwise it is created with perm (before umask).
+func WriteAtomic(filename string, r io.Reader, perm os.FileMode) (int64, error) {
+	f, err := CreateAtomic(filename, perm)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Abort()
+
+	n, err := CopyStream(f, r)
+	if err != nil {
+		return n, err
+	}
+	return n, f.Close()
+}
+
+// syncDir flushes the directory entry for dir to disk. This makes a
+// preceding rename into dir survive a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil && !isSyncUnsupported(err) {
+		return err
+	}
+	return nil
+}
+
+// isSyncUnsupported reports whether err is the error returned by
+// platforms that do not support fsync on directories.
+func isSyncUnsupported(err error) bool {
+	return errors.Is(err, syscall.EINVAL)
+}
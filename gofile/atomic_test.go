@@ -0,0 +1,88 @@
+package gofile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		existing os.FileMode // 0 means the file does not exist
+		perm     os.FileMode
+		want     os.FileMode
+	}{
+		{"new file", 0, 0640, 0640},
+		{"keeps existing mode", 0600, 0644, 0600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(dir, tt.name)
+			if tt.existing != 0 {
+				if err := ioutil.WriteFile(filename, []byte("old"), tt.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := WriteFileAtomic(filename, []byte("new"), tt.perm); err != nil {
+				t.Fatalf("WriteFileAtomic() error = %v", err)
+			}
+
+			got, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != "new" {
+				t.Errorf("WriteFileAtomic() contents = %q, want %q", got, "new")
+			}
+
+			fi, err := os.Stat(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm() != tt.want {
+				t.Errorf("WriteFileAtomic() mode = %v, want %v", fi.Mode().Perm(), tt.want)
+			}
+		})
+	}
+}
+
+func TestAtomicFileAbort(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.json")
+
+	if err := ioutil.WriteFile(filename, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := CreateAtomic(filename, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("partial"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Abort(); err != nil {
+		t.Fatalf("Abort() error = %v", err)
+	}
+
+	got, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "old" {
+		t.Errorf("target modified after Abort: %q", got)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary file left behind: %d entries in %s", len(entries), dir)
+	}
+}
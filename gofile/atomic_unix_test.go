@@ -0,0 +1,50 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package gofile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestWriteFileAtomicUmask(t *testing.T) {
+	defer syscall.Umask(syscall.Umask(077))
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		existing os.FileMode // 0 means the file does not exist
+		want     os.FileMode
+	}{
+		{"new file", 0, 0600},
+		{"keeps existing mode", 0644, 0644},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(dir, tt.name)
+			if tt.existing != 0 {
+				if err := ioutil.WriteFile(filename, []byte("old"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chmod(filename, tt.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := WriteFileAtomic(filename, []byte("new"), 0644); err != nil {
+				t.Fatalf("WriteFileAtomic() error = %v", err)
+			}
+			fi, err := os.Stat(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm() != tt.want {
+				t.Errorf("WriteFileAtomic() mode = %v, want %v", fi.Mode().Perm(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package gofile
+
+import (
+	"os"
+)
+
+// FileOps describes the basic gofile operations in a form that returns
+// errors to the caller instead of logging them.
+//
+// Errors are of type *PathError and wrap one of the sentinel errors
+// (ErrNotExist, ErrIsDir, ErrPermission, ErrExists, ...) so they can be
+// tested with errors.Is.
+type FileOps interface {
+	// Stat returns the os.FileInfo for name.
+	Stat(name string) (os.FileInfo, error)
+
+	// Create creates or truncates the named file.
+	Create(name string) (*os.File, error)
+
+	// CreateSafe creates the named file. If the file
+	// already exists, an error wrapping ErrExists is returned.
+	// Use CreateSafeWith for other collision policies.
+	CreateSafe(name string) (*os.File, error)
+
+	// Mode returns the file mode of name.
+	Mode(name string) (os.FileMode, error)
+}
+
+// Checked is the default FileOps implementation. It does not log;
+// wrap it with Logged or WithHandler to have errors reported as well
+// as returned.
+//
+// Checked returns *os.File, so it always works on the operating
+// system's files and ignores SetFS. Use On(nil) for the file system
+// set with SetFS.
+var Checked FileOps = checkedOps{}
+
+type checkedOps struct{}
+
+func (checkedOps) Stat(name string) (os.FileInfo, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, newPathError("stat", name, err)
+	}
+	return fi, nil
+}
+
+func (checkedOps) Create(name string) (*os.File, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, newPathError("create", name, err)
+	}
+	return f, nil
+}
+
+func (checkedOps) CreateSafe(name string) (*os.File, error) {
+	return CreateSafeWith(name, CollisionFail)
+}
+
+func (c checkedOps) Mode(name string) (os.FileMode, error) {
+	fi, err := c.Stat(name)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Mode(), nil
+}
+
+// Logged returns a FileOps that passes every error returned
+// by ops through Err before returning it.
+func Logged(ops FileOps) FileOps { return handledOps{ops: ops} }
+
+// WithHandler returns a FileOps that passes every error returned by
+// ops to h instead of the package error handler. It lets a single
+// caller collect or ignore errors without changing SetErrorHandler.
+func WithHandler(ops FileOps, h ErrorHandler) FileOps { return handledOps{ops, h} }
+
+type handledOps struct {
+	ops FileOps
+	h   ErrorHandler // nil uses Err
+}
+
+func (o handledOps) handle(err error) error {
+	if err == nil || o.h == nil {
+		return Err(err)
+	}
+	return o.h.Handle(err)
+}
+
+func (o handledOps) Stat(name string) (os.FileInfo, error) {
+	fi, err := o.ops.Stat(name)
+	return fi, o.handle(err)
+}
+
+func (o handledOps) Create(name string) (*os.File, error) {
+	f, err := o.ops.Create(name)
+	return f, o.handle(err)
+}
+
+func (o handledOps) CreateSafe(name string) (*os.File, error) {
+	f, err := o.ops.CreateSafe(name)
+	return f, o.handle(err)
+}
+
+func (o handledOps) Mode(name string) (os.FileMode, error) {
+	m, err := o.ops.Mode(name)
+	return m, o.handle(err)
+}
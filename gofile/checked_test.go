@@ -0,0 +1,56 @@
+package gofile
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChecked(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing")
+	missing := filepath.Join(dir, "missing")
+
+	if err := ioutil.WriteFile(existing, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		fn   func() error
+		want error
+	}{
+		{"stat missing", func() error { _, err := Checked.Stat(missing); return err }, ErrNotExist},
+		{"mode missing", func() error { _, err := Checked.Mode(missing); return err }, ErrNotExist},
+		{"create directory", func() error { _, err := Checked.Create(dir); return err }, ErrIsDir},
+		{"create safe existing", func() error { _, err := Checked.CreateSafe(existing); return err }, ErrExists},
+		{"create safe under file", func() error { _, err := Checked.CreateSafe(filepath.Join(existing, "x")); return err }, ErrNotDir},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fn()
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+			var pe *PathError
+			if !errors.As(err, &pe) {
+				t.Errorf("error %T is not a *PathError", err)
+			}
+		})
+	}
+}
+
+func TestModeMissing(t *testing.T) {
+	if got := Mode(filepath.Join(t.TempDir(), "missing")); got != 0 {
+		t.Errorf("Mode() = %v, want 0", got)
+	}
+}
+
+func TestCheckedIsOS(t *testing.T) {
+	_, err := Checked.Stat(filepath.Join(t.TempDir(), "missing"))
+	if !os.IsNotExist(errors.Unwrap(err)) {
+		t.Errorf("underlying error %v is not an os not-exist error", errors.Unwrap(err))
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/skeptycal/util/gofile"
+)
+
+const duUsage = "Usage: dir du [-a] [-s] [-d=N] [-sort] [-apparent] [-format=human|json] <path>..."
+
+// du runs the du subcommand with args, writing the report to w.
+//
+// Each path is followed by the directories below it (and with -a,
+// the files) and their disk usage, in walk order or with -sort
+// largest first. If more than one path is given, a total follows.
+func du(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("du", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), duUsage)
+		fs.PrintDefaults()
+	}
+
+	var (
+		opts      gofile.DuOptions
+		walk      gofile.WalkOptions
+		summarize bool
+		format    string
+	)
+	fs.BoolVar(&opts.All, "a", false, "report files as well as directories")
+	fs.BoolVar(&summarize, "s", false, "only report the total of each path")
+	fs.IntVar(&opts.MaxDepth, "d", 0, "report entries at most N levels below each path (0: no limit)")
+	fs.BoolVar(&opts.SortBySize, "sort", false, "sort by size, largest first")
+	fs.BoolVar(&opts.Apparent, "apparent", false, "report apparent sizes instead of disk usage")
+	fs.StringVar(&format, "format", "human", "output format: human or json")
+	fs.IntVar(&walk.Workers, "workers", 0, "number of concurrent workers (default: number of CPUs)")
+	fs.BoolVar(&walk.Gitignore, "gitignore", false, "skip files ignored by .gitignore")
+	fs.Parse(args)
+
+	if format != "human" && format != "json" {
+		fs.Usage()
+		return fmt.Errorf("unknown format %q", format)
+	}
+
+	roots := fs.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	if summarize {
+		opts.MaxDepth, opts.All = 1, false
+	}
+	opts.Walk = &walk
+
+	usage, err := gofile.DiskUsage(roots, &opts)
+	if err != nil {
+		return err
+	}
+	if summarize {
+		totals := usage[:0]
+		for _, u := range usage {
+			if u.Depth == 0 {
+				totals = append(totals, u)
+			}
+		}
+		usage = totals
+	}
+
+	if format == "json" {
+		if usage == nil {
+			usage = []gofile.Usage{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(usage)
+	}
+
+	var total int64
+	for _, u := range usage {
+		size := u.Size(opts.Apparent)
+		if u.Depth == 0 {
+			total += size
+		}
+		fmt.Fprintf(w, "%7s  %s\n", gofile.HumanSize(size), u.Path)
+	}
+	if len(roots) > 1 {
+		fmt.Fprintf(w, "%7s  total\n", gofile.HumanSize(total))
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	ansi "github.com/skeptycal/ansi"
+)
+
+// defaultLSColors are the GNU ls colors used when LS_COLORS is not set.
+const defaultLSColors = "di=01;34:ln=01;36:pi=40;33:so=01;35:bd=40;33;01:cd=40;33;01:or=40;31;01:su=37;41:sg=30;43:ex=01;32"
+
+// sgr is the parameter list of an ANSI Select Graphic Rendition
+// sequence, such as "01;34", as found in LS_COLORS.
+type sgr string
+
+func (s sgr) String() string { return "\033[" + string(s) + "m" }
+
+// lsColors maps file types and name suffixes to colors, as parsed
+// from LS_COLORS.
+type lsColors struct {
+	types    map[string]sgr // "di", "ln", "ex", ...
+	suffixes []suffixColor  // "*.tar", "*README", ...
+}
+
+type suffixColor struct {
+	suffix string // lower case
+	color  sgr
+}
+
+// parseLSColors parses a LS_COLORS value such as
+// "di=01;34:ln=01;36:*.tar=01;31". Malformed entries are ignored.
+func parseLSColors(s string) *lsColors {
+	c := &lsColors{types: make(map[string]sgr)}
+	for _, field := range strings.Split(s, ":") {
+		i := strings.IndexByte(field, '=')
+		if i <= 0 || i == len(field)-1 {
+			continue
+		}
+		key, color := field[:i], sgr(field[i+1:])
+		if strings.HasPrefix(key, "*") {
+			c.suffixes = append(c.suffixes, suffixColor{strings.ToLower(key[1:]), color})
+			continue
+		}
+		c.types[key] = color
+	}
+	return c
+}
+
+// envLSColors returns the colors from LS_COLORS, or the GNU defaults.
+func envLSColors() *lsColors {
+	if s := os.Getenv("LS_COLORS"); s != "" {
+		return parseLSColors(s)
+	}
+	return parseLSColors(defaultLSColors)
+}
+
+// color returns the color of n, or "" if it has none. As in GNU ls,
+// the type of a file takes precedence over its name, so an
+// executable archive is colored as an executable. Types of regular
+// files without a color in LS_COLORS fall through: a setuid
+// executable is colored by "ex" if "su" is not set, and by its name
+// or "fi" if neither is.
+func (c *lsColors) color(n *node) sgr {
+	m := n.info.Mode()
+	var key string
+	switch {
+	case m.IsDir():
+		key = "di"
+	case m&os.ModeSymlink != 0:
+		key = "ln"
+		if _, err := os.Stat(n.path); err != nil {
+			key = "or"
+		}
+	case m&os.ModeNamedPipe != 0:
+		key = "pi"
+	case m&os.ModeSocket != 0:
+		key = "so"
+	case m&os.ModeCharDevice != 0:
+		key = "cd"
+	case m&os.ModeDevice != 0:
+		key = "bd"
+	}
+	if key != "" {
+		return c.types[key]
+	}
+
+	for _, t := range []struct {
+		key string
+		set bool
+	}{
+		{"su", m&os.ModeSetuid != 0},
+		{"sg", m&os.ModeSetgid != 0},
+		{"ex", m&0111 != 0},
+	} {
+		if color, ok := c.types[t.key]; ok && t.set {
+			return color
+		}
+	}
+
+	// a plain file: the last matching suffix wins
+	name := strings.ToLower(n.Name)
+	var color sgr
+	for _, s := range c.suffixes {
+		if strings.HasSuffix(name, s.suffix) {
+			color = s.color
+		}
+	}
+	if color == "" {
+		color = c.types["fi"]
+	}
+	return color
+}
+
+// paint returns s in the color of n, or s itself if n has no color.
+func (c *lsColors) paint(n *node, s string) string {
+	if c == nil {
+		return s
+	}
+	color := c.color(n)
+	if color == "" {
+		return s
+	}
+	return ansi.NewAnsiString(color, s).String()
+}
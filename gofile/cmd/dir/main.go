@@ -1,29 +1,50 @@
 package main
 
 import (
-	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
-	"github.com/skeptycal/zsh"
 )
 
+// Dir returns a recursive listing of the regular files below path,
+// one path (relative to path) per line.
 func Dir(path string) string {
-	command := fmt.Sprintf("ls -R %s", path)
-	result := zsh.Sh(command)
-	return result
+	if path == "" {
+		path = "."
+	}
+
+	root, err := buildTree(path, &treeOptions{all: true})
+	if err != nil {
+		log.Error(err)
+	}
+	if root == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	root.files(func(n *node) {
+		sb.WriteString(n.rel)
+		sb.WriteByte('\n')
+	})
+	return sb.String()
 }
 
-func DirTime(args string) string {
-	command := fmt.Sprintf("time ls -R . %s", args)
-	result := zsh.Sh(command)
+// DirTime returns the same listing as Dir and logs the time it took.
+func DirTime(path string) string {
+	start := time.Now()
+	result := Dir(path)
+	log.Infof("Dir(%q) took %v", path, time.Since(start))
 	return result
 }
 
 func main() {
-
-	testpath := "/Users/skeptycal/local_coding"
-	fmt.Println("Directory Listing Benchmarks:\n ")
-	log.Info("logger started")
-	fmt.Println("")
-	fmt.Println(Dir(testpath))
+	run, args := tree, os.Args[1:]
+	if len(args) > 0 && args[0] == "du" {
+		run, args = du, args[1:]
+	}
+	if err := run(os.Stdout, args); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -1,7 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/skeptycal/util/gofile"
 )
 
 func BenchmarkDirShellBM(b *testing.B) {
@@ -17,22 +26,28 @@ func Test_main(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			defer func(args []string) { os.Args = args }(os.Args)
+			os.Args = []string{"dir", "-L=1", "."}
 			main()
 		})
 	}
 }
 
+// treeFiles is the listing of Dir for treeDir.
+const treeFiles = ".hidden/d\na/big.bin\na/deep/c.tar\na/run.sh\nb.txt\n"
+
 func TestDir(t *testing.T) {
 	type args struct {
 		path string
 	}
+	dir := treeDir(t)
 	tests := []struct {
 		name string
 		args args
 		want string
 	}{
-		// TODO: Add test cases.
-		{"pwd", args{"."}, "main.go\nmain_test.go\n"},
+		{"tree", args{dir}, treeFiles},
+		{"missing", args{filepath.Join(dir, "missing")}, ""},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -52,8 +67,7 @@ func TestDirTime(t *testing.T) {
 		args args
 		want string
 	}{
-		// TODO: Add test cases.
-        {"test", args{""}, "main.go\nmain_test.go\n"},
+        {"tree", args{treeDir(t)}, treeFiles},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -63,3 +77,240 @@ func TestDirTime(t *testing.T) {
 		})
 	}
 }
+
+func TestDu(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"one/a", "two/b"} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	one, two := filepath.Join(dir, "one"), filepath.Join(dir, "two")
+
+	var buf bytes.Buffer
+	if err := du(&buf, []string{"-s", one, two}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], one) || !strings.HasSuffix(lines[1], two) || !strings.HasSuffix(lines[2], "total") {
+		t.Errorf("du -s output:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := du(&buf, []string{"-a", "-format=json", one}); err != nil {
+		t.Fatal(err)
+	}
+	var usage []gofile.Usage
+	if err := json.Unmarshal(buf.Bytes(), &usage); err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 2 || usage[1].Path != filepath.Join(one, "a") || usage[0].Files != 1 {
+		t.Errorf("du -a -format=json = %+v", usage)
+	}
+}
+
+// treeDir creates a small tree for the listing tests.
+func treeDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := [][2]string{
+		{"b.txt", "bb"},
+		{"a/big.bin", strings.Repeat("x", 3000)},
+		{"a/run.sh", "#!/bin/sh\n"},
+		{"a/deep/c.tar", "c"},
+		{".hidden/d", "d"},
+	}
+	mtime := time.Date(2021, 4, 5, 6, 7, 8, 0, time.UTC)
+	for _, f := range files {
+		name, data := f[0], f[1]
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime = mtime.Add(time.Hour)
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(dir, "a", "run.sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("b.txt", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestTree(t *testing.T) {
+	dir := treeDir(t)
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"default", nil, `
+├── a
+│   ├── big.bin
+│   ├── deep
+│   │   └── c.tar
+│   └── run.sh
+├── b.txt
+└── link -> b.txt
+
+2 directories, 5 files
+`},
+		{"all and depth", []string{"-a", "-L=1"}, `
+├── .hidden
+├── a
+├── b.txt
+└── link -> b.txt
+
+2 directories, 2 files
+`},
+		{"size reversed", []string{"-L=1", "-sort=size", "-r"}, `
+├── b.txt
+├── link -> b.txt
+└── a
+
+1 directory, 2 files
+`},
+		{"colors", []string{"-color=always", "-L=2"}, `
+├── ` + "\033[01;34ma\033[0m" + `
+│   ├── big.bin
+│   ├── ` + "\033[01;34mdeep\033[0m" + `
+│   └── ` + "\033[01;32mrun.sh\033[0m" + `
+├── b.txt
+└── ` + "\033[01;36mlink\033[0m" + ` -> b.txt
+
+2 directories, 4 files
+`},
+	}
+	defer os.Setenv("LS_COLORS", os.Getenv("LS_COLORS"))
+	os.Setenv("LS_COLORS", "")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tree(&buf, append(tt.args, dir)); err != nil {
+				t.Fatal(err)
+			}
+			want := dir + tt.want
+			if strings.Contains(strings.Join(tt.args, " "), "-color=always") {
+				want = "\033[01;34m" + dir + "\033[0m" + tt.want
+			}
+			if got := buf.String(); got != want {
+				t.Errorf("tree %v =\n%s\nwant\n%s", tt.args, got, want)
+			}
+		})
+	}
+}
+
+func TestTreeLongAndJSON(t *testing.T) {
+	dir := treeDir(t)
+
+	var buf bytes.Buffer
+	if err := tree(&buf, []string{"-l", "-L=1", "-sort=time", filepath.Join(dir, "a")}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) < 4 || !strings.Contains(lines[2], "[-rwxr-xr-x") || !strings.HasSuffix(lines[2], "]  run.sh") ||
+		!strings.Contains(lines[3], gofile.HumanSize(3000)) || !strings.HasSuffix(lines[3], "big.bin") {
+		t.Errorf("tree -l -sort=time =\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := tree(&buf, []string{"-format=json", dir}); err != nil {
+		t.Fatal(err)
+	}
+	var nodes []struct {
+		Name     string
+		Type     string
+		Contents []struct {
+			Name   string
+			Type   string
+			Size   int64
+			Target string
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &nodes); err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].Name != dir || nodes[0].Type != "directory" || len(nodes[0].Contents) != 3 {
+		t.Fatalf("tree -format=json = %s", buf.String())
+	}
+	if c := nodes[0].Contents[2]; c.Name != "link" || c.Type != "link" || c.Target != "b.txt" {
+		t.Errorf("link entry = %+v", c)
+	}
+	if c := nodes[0].Contents[1]; c.Name != "b.txt" || c.Type != "file" || c.Size != 2 {
+		t.Errorf("file entry = %+v", c)
+	}
+}
+
+func TestLSColors(t *testing.T) {
+	dir := treeDir(t)
+	info := func(rel string) *node {
+		p := filepath.Join(dir, filepath.FromSlash(rel))
+		fi, err := os.Lstat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &node{Name: filepath.Base(p), path: p, info: fi}
+	}
+	if err := os.Symlink("missing", filepath.Join(dir, "broken")); err != nil {
+		t.Fatal(err)
+	}
+
+	c := parseLSColors("di=01;34:ln=01;36:or=31:ex=01;32:fi=0:*.tar=01;31:*.TXT=33:bogus:=1:x=")
+	tests := []struct {
+		rel  string
+		want sgr
+	}{
+		{"a", "01;34"},
+		{"link", "01;36"},
+		{"broken", "31"},
+		{"a/run.sh", "01;32"},
+		{"a/deep/c.tar", "01;31"},
+		{"b.txt", "33"},
+		{"a/big.bin", "0"},
+	}
+	for _, tt := range tests {
+		if got := c.color(info(tt.rel)); got != tt.want {
+			t.Errorf("color(%s) = %q, want %q", tt.rel, got, tt.want)
+		}
+	}
+
+	// unset file types fall through to the next one, then to the name
+	if err := os.Chmod(filepath.Join(dir, "a", "deep", "c.tar"), 0755|os.ModeSetuid); err != nil {
+		t.Fatal(err)
+	}
+	fallthroughs := []struct {
+		colors string
+		rel    string
+		want   sgr
+	}{
+		{"su=37;41:ex=01;32", "a/deep/c.tar", "37;41"},
+		{"ex=01;32:*.tar=01;31", "a/deep/c.tar", "01;32"},
+		{"*.tar=01;31", "a/deep/c.tar", "01;31"},
+		{"fi=0:*.tar=01;31", "a/run.sh", "0"},
+		{"ln=01;36", "a", ""},
+	}
+	for _, tt := range fallthroughs {
+		if got := parseLSColors(tt.colors).color(info(tt.rel)); got != tt.want {
+			t.Errorf("%s: color(%s) = %q, want %q", tt.colors, tt.rel, got, tt.want)
+		}
+	}
+
+	if got := c.paint(info("a"), "a"); got != "\033[01;34ma\033[0m" {
+		t.Errorf("paint(a) = %q", got)
+	}
+	if got := (*lsColors)(nil).paint(info("a"), "a"); got != "a" {
+		t.Errorf("nil paint(a) = %q", got)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skeptycal/util/gofile"
+)
+
+const treeUsage = "Usage: dir [-a] [-l] [-L=N] [-sort=name|size|time] [-r] [-color=auto|always|never] [-format=tree|json] [path]...\n       dir du ..."
+
+// treeOptions configures a listing.
+type treeOptions struct {
+	all       bool   // include names starting with "."
+	long      bool   // show mode, size and modification time
+	depth     int    // levels below the root, 0 for no limit
+	sortBy    string // "name", "size" or "time"
+	reverse   bool
+	gitignore bool
+	colors    *lsColors // nil for no color
+}
+
+// node is a file in a listing.
+type node struct {
+	Name     string    `json:"name"`
+	Type     string    `json:"type"`
+	Target   string    `json:"target,omitempty"`
+	Mode     string    `json:"mode"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Contents []*node   `json:"contents,omitempty"`
+
+	path string
+	rel  string
+	info os.FileInfo
+}
+
+func newNode(e gofile.Entry) *node {
+	n := &node{
+		Name:     path.Base(e.Rel),
+		Mode:     e.Info.Mode().String(),
+		Size:     e.Info.Size(),
+		Modified: e.Info.ModTime(),
+		path:     e.Path,
+		rel:      e.Rel,
+		info:     e.Info,
+	}
+	if e.Rel == "." {
+		n.Name = e.Path
+	}
+
+	switch e.Type() {
+	case gofile.TypeDir:
+		n.Type = "directory"
+	case gofile.TypeFile:
+		n.Type = "file"
+	case gofile.TypeSymlink:
+		n.Type = "link"
+		n.Target, _ = os.Readlink(e.Path)
+	default:
+		n.Type = "other"
+	}
+	if e.IsDir() && n.Type == "link" {
+		// a followed root
+		n.Type = "directory"
+	}
+	return n
+}
+
+// buildTree walks root and returns its listing. On error, the part
+// listed so far is returned with it.
+func buildTree(root string, o *treeOptions) (*node, error) {
+	dirs := make(map[string]*node)
+	var top *node
+
+	opts := &gofile.WalkOptions{MaxDepth: o.depth, Gitignore: o.gitignore}
+	err := gofile.Walk(root, opts, func(e gofile.Entry) error {
+		if e.Rel != "." && !o.all && strings.HasPrefix(path.Base(e.Rel), ".") {
+			if e.IsDir() {
+				return gofile.SkipDir
+			}
+			return nil
+		}
+
+		n := newNode(e)
+		if e.Rel == "." {
+			top = n
+		} else if parent := dirs[path.Dir(e.Rel)]; parent != nil {
+			parent.Contents = append(parent.Contents, n)
+		}
+		if e.IsDir() {
+			dirs[e.Rel] = n
+		}
+		return nil
+	})
+	if top != nil {
+		top.sort(o.sortBy, o.reverse)
+	}
+	return top, err
+}
+
+// sort orders the contents of n and its subdirectories. Names are
+// already in order; sizes sort largest and times newest first.
+func (n *node) sort(by string, reverse bool) {
+	less := func(a, b *node) bool { return a.Name < b.Name }
+	switch by {
+	case "size":
+		less = func(a, b *node) bool { return a.Size > b.Size }
+	case "time":
+		less = func(a, b *node) bool { return a.Modified.After(b.Modified) }
+	}
+
+	sort.SliceStable(n.Contents, func(i, j int) bool {
+		if reverse {
+			return less(n.Contents[j], n.Contents[i])
+		}
+		return less(n.Contents[i], n.Contents[j])
+	})
+	for _, c := range n.Contents {
+		c.sort(by, reverse)
+	}
+}
+
+// files calls fn for every regular file below n, in listing order.
+func (n *node) files(fn func(*node)) {
+	for _, c := range n.Contents {
+		if c.Type == "file" {
+			fn(c)
+		}
+		c.files(fn)
+	}
+}
+
+// treeCounts holds the totals printed after a tree.
+type treeCounts struct{ dirs, files int }
+
+// writeTree writes n and its contents with box-drawing characters.
+func writeTree(w io.Writer, n *node, o *treeOptions, counts *treeCounts) {
+	fmt.Fprintln(w, o.colors.paint(n, n.Name))
+	writeContents(w, n, "", o, counts)
+}
+
+func writeContents(w io.Writer, n *node, prefix string, o *treeOptions, counts *treeCounts) {
+	for i, c := range n.Contents {
+		branch, indent := "├── ", "│   "
+		if i == len(n.Contents)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		line := prefix + branch
+		if o.long {
+			line += fmt.Sprintf("[%s %6s %s]  ", c.Mode, gofile.HumanSize(c.Size), c.Modified.Format("2006-01-02 15:04"))
+		}
+		line += o.colors.paint(c, c.Name)
+		if c.Target != "" {
+			line += " -> " + c.Target
+		}
+		fmt.Fprintln(w, line)
+
+		if c.Type == "directory" {
+			counts.dirs++
+			writeContents(w, c, prefix+indent, o, counts)
+		} else {
+			counts.files++
+		}
+	}
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, one)
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
+
+// tree runs the listing command with args, writing to w.
+func tree(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("dir", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), treeUsage)
+		fs.PrintDefaults()
+	}
+
+	var (
+		o      treeOptions
+		color  string
+		format string
+	)
+	fs.BoolVar(&o.all, "a", false, "list names starting with a dot")
+	fs.BoolVar(&o.long, "l", false, "show mode, size and modification time")
+	fs.IntVar(&o.depth, "L", 0, "descend at most N levels (0: no limit)")
+	fs.StringVar(&o.sortBy, "sort", "name", "sort by name, size (largest first) or time (newest first)")
+	fs.BoolVar(&o.reverse, "r", false, "reverse the sort order")
+	fs.BoolVar(&o.gitignore, "gitignore", false, "skip files ignored by .gitignore")
+	fs.StringVar(&color, "color", "auto", "color names from LS_COLORS: auto, always or never")
+	fs.StringVar(&format, "format", "tree", "output format: tree or json")
+	fs.Parse(args)
+
+	switch {
+	case o.sortBy != "name" && o.sortBy != "size" && o.sortBy != "time":
+		fs.Usage()
+		return fmt.Errorf("unknown sort order %q", o.sortBy)
+	case format != "tree" && format != "json":
+		fs.Usage()
+		return fmt.Errorf("unknown format %q", format)
+	case color != "auto" && color != "always" && color != "never":
+		fs.Usage()
+		return fmt.Errorf("unknown color mode %q", color)
+	}
+	if color == "always" || color == "auto" && format == "tree" && isTerminal(w) {
+		o.colors = envLSColors()
+	}
+
+	roots := fs.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	var nodes []*node
+	for _, root := range roots {
+		n, err := buildTree(root, &o)
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, n)
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(nodes)
+	}
+
+	var counts treeCounts
+	for _, n := range nodes {
+		writeTree(w, n, &o, &counts)
+	}
+	fmt.Fprintf(w, "\n%s, %s\n", plural(counts.dirs, "directory", "directories"), plural(counts.files, "file", "files"))
+	return nil
+}
+
+// isTerminal reports whether w is a terminal that wants color.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,179 @@
+// Command dupes finds duplicate files in one or more directory trees.
+//
+//	Usage: dupes [-format=human|json|script] [-action=link|trash|delete] [-n] [-min-size=N] <dir>...
+//
+// Files are compared by size, then by a partial hash and finally by a
+// full SHA256. The first path of each group (in lexical order) is kept.
+// -action=trash moves duplicates to the desktop trash, where they can be
+// restored; -action=delete removes them permanently. Files are hashed
+// again before they are changed, and files modified since the scan are
+// left alone. With -format=script, -action only selects the commands in
+// the script.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeptycal/util/gofile"
+)
+
+const usage = "Usage: dupes [-format=human|json|script] [-action=link|trash|delete] [-n] [-min-size=N] <dir>..."
+
+func main() {
+	if err := run(os.Stdout, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run runs the command with args, writing to w.
+func run(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("dupes", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+
+	var (
+		format    string
+		action    string
+		dryRun    bool
+		minSize   int64
+		workers   int
+		gitignore bool
+	)
+	fs.StringVar(&format, "format", "human", "output format: human, json or script")
+	fs.StringVar(&action, "action", "", "remove duplicates: link (hard link to the kept file), trash or delete (permanently)")
+	fs.BoolVar(&dryRun, "n", false, "dry run: show what -action would do without changing anything")
+	fs.Int64Var(&minSize, "min-size", 1, "ignore files smaller than this many bytes")
+	fs.IntVar(&workers, "workers", 0, "number of concurrent workers (default: number of CPUs)")
+	fs.BoolVar(&gitignore, "gitignore", false, "skip files ignored by .gitignore")
+	fs.Parse(args)
+
+	roots := fs.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	var dedupe gofile.DedupeAction
+	switch action {
+	case "":
+	case "link":
+		dedupe = gofile.DedupeLink
+	case "trash":
+		dedupe = gofile.DedupeTrash
+	case "delete":
+		dedupe = gofile.DedupeDelete
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown action %q", action)
+	}
+	if format != "human" && format != "json" && format != "script" {
+		fs.Usage()
+		return fmt.Errorf("unknown format %q", format)
+	}
+
+	opts := &gofile.DupeOptions{
+		MinSize: minSize,
+		Walk:    &gofile.WalkOptions{Workers: workers, Gitignore: gitignore},
+	}
+
+	groups, err := gofile.FindDuplicates(roots, opts)
+	if err != nil {
+		return err
+	}
+
+	if dedupe != 0 && format != "script" {
+		return apply(w, groups, dedupe, dryRun)
+	}
+
+	switch format {
+	case "json":
+		return writeJSON(w, groups)
+	case "script":
+		return writeScript(w, groups, dedupe)
+	}
+	return writeHuman(w, groups)
+}
+
+// apply runs the dedupe action on every group and reports each change.
+func apply(w io.Writer, groups []gofile.DupeGroup, a gofile.DedupeAction, dryRun bool) error {
+	verb := a.String()
+	if dryRun {
+		verb = "would " + verb
+	}
+
+	var files int
+	var saved int64
+	for _, g := range groups {
+		changed, err := gofile.Dedupe(g, a, dryRun)
+		for _, p := range changed {
+			fmt.Fprintf(w, "%s %s (keeping %s)\n", verb, p, g.Paths[0])
+		}
+		files += len(changed)
+		saved += g.Size * int64(len(changed))
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "%s %d files, %s\n", verb, files, gofile.HumanSize(saved))
+	return nil
+}
+
+func writeHuman(w io.Writer, groups []gofile.DupeGroup) error {
+	var wasted int64
+	for _, g := range groups {
+		fmt.Fprintf(w, "%d files, %s each (sha256 %.12s)\n", len(g.Paths), gofile.HumanSize(g.Size), g.Sum)
+		for _, p := range g.Paths {
+			fmt.Fprintf(w, "  %s\n", p)
+		}
+		fmt.Fprintln(w)
+		wasted += g.Wasted()
+	}
+	_, err := fmt.Fprintf(w, "%d groups, %s wasted\n", len(groups), gofile.HumanSize(wasted))
+	return err
+}
+
+func writeJSON(w io.Writer, groups []gofile.DupeGroup) error {
+	if groups == nil {
+		groups = []gofile.DupeGroup{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(groups)
+}
+
+// writeScript writes a shell script that removes the duplicates, or
+// replaces them with hard links if -action=link is given, or moves them
+// to the trash with gio if -action=trash is given. Review it before
+// running it.
+func writeScript(w io.Writer, groups []gofile.DupeGroup, a gofile.DedupeAction) error {
+	fmt.Fprintln(w, "#!/bin/sh")
+	fmt.Fprintln(w, "# generated by dupes; review before running")
+	fmt.Fprintln(w, "set -e")
+	for _, g := range groups {
+		keep := shellQuote(g.Paths[0])
+		fmt.Fprintf(w, "\n# keep %s\n", keep)
+		for _, p := range g.Paths[1:] {
+			switch a {
+			case gofile.DedupeLink:
+				fmt.Fprintf(w, "ln -f -- %s %s\n", keep, shellQuote(p))
+			case gofile.DedupeTrash:
+				fmt.Fprintf(w, "gio trash -- %s\n", shellQuote(p))
+			default:
+				fmt.Fprintf(w, "rm -- %s\n", shellQuote(p))
+			}
+		}
+	}
+	return nil
+}
+
+// shellQuote quotes s for use as a single sh argument.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skeptycal/util/gofile"
+)
+
+// dupesTree creates a tree with one group of three duplicates and
+// returns its root.
+func dupesTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"a/keep.txt": "same",
+		"b/dup.txt":  "same",
+		"c/dup.txt":  "same",
+		"c/other":    "diff",
+	}
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+func TestRunReport(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"human", nil, []string{"3 files, " + gofile.HumanSize(4) + " each", "1 groups, " + gofile.HumanSize(8) + " wasted"}},
+		{"json", []string{"-format=json"}, []string{`"size": 4`, `"paths": [`}},
+		{"script", []string{"-format=script"}, []string{"#!/bin/sh", "rm -- '", "b/dup.txt'"}},
+		{"link script", []string{"-format=script", "-action=link"}, []string{"ln -f -- '"}},
+		{"trash script", []string{"-format=script", "-action=trash"}, []string{"gio trash -- '"}},
+		{"dry run", []string{"-action=delete", "-n"}, []string{"would delete", "would delete 2 files, " + gofile.HumanSize(8)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := dupesTree(t)
+			var buf bytes.Buffer
+			if err := run(&buf, append(tt.args, dir)); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("run() output does not contain %q:\n%s", want, buf.String())
+				}
+			}
+
+			// reports and dry runs change nothing
+			for _, name := range []string{"a/keep.txt", "b/dup.txt", "c/dup.txt"} {
+				if !exists(filepath.Join(dir, filepath.FromSlash(name))) {
+					t.Errorf("run() removed %s", name)
+				}
+			}
+		})
+	}
+}
+
+func TestRunActions(t *testing.T) {
+	tests := []struct {
+		action string
+		linked bool
+	}{
+		{"delete", false},
+		{"trash", false},
+		{"link", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			defer func(v string, ok bool) {
+				if ok {
+					os.Setenv("XDG_DATA_HOME", v)
+				} else {
+					os.Unsetenv("XDG_DATA_HOME")
+				}
+			}(os.LookupEnv("XDG_DATA_HOME"))
+			data := t.TempDir()
+			os.Setenv("XDG_DATA_HOME", data)
+
+			dir := dupesTree(t)
+			keep := filepath.Join(dir, "a", "keep.txt")
+			dup := filepath.Join(dir, "b", "dup.txt")
+
+			var buf bytes.Buffer
+			if err := run(&buf, []string{"-action=" + tt.action, dir}); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(buf.String(), tt.action+" 2 files, "+gofile.HumanSize(8)) {
+				t.Errorf("run() output =\n%s", buf.String())
+			}
+			if !exists(keep) {
+				t.Fatalf("run() removed the kept file")
+			}
+
+			kfi, _ := os.Stat(keep)
+			for _, name := range []string{dup, filepath.Join(dir, "c", "dup.txt")} {
+				fi, err := os.Stat(name)
+				switch {
+				case tt.linked && (err != nil || !os.SameFile(fi, kfi)):
+					t.Errorf("%s is not linked to the kept file: %v", name, err)
+				case !tt.linked && err == nil:
+					t.Errorf("%s was not removed", name)
+				}
+			}
+			if other := filepath.Join(dir, "c", "other"); !exists(other) {
+				t.Errorf("run() removed a unique file")
+			}
+
+			trashed, _ := filepath.Glob(filepath.Join(data, "Trash", "files", "*"))
+			if want := tt.action == "trash"; want != (len(trashed) == 2) {
+				t.Errorf("trash holds %v", trashed)
+			}
+		})
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"-action=shred"},
+		{"-format=xml"},
+		{filepath.Join(t.TempDir(), "missing")},
+	} {
+		if err := run(ioutil.Discard, args); err == nil {
+			t.Errorf("run(%q) succeeded", args)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package gofile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// CopyOptions configures Copy, CopyDir and Move. The zero value copies
+// file contents only, follows symlinks and refuses to overwrite
+// existing files.
+type CopyOptions struct {
+	// PreserveMode copies the permission bits, including setuid,
+	// setgid and sticky, from the source.
+	PreserveMode bool
+
+	// PreserveTimes copies the modification time from the source.
+	PreserveTimes bool
+
+	// PreserveSymlinks copies symlinks as symlinks instead of
+	// copying the files they point to.
+	PreserveSymlinks bool
+
+	// Collision selects what happens when a destination file exists.
+	// CollisionOverwrite replaces files atomically. Symlinks and
+	// directories only support CollisionFail and CollisionOverwrite;
+	// other policies act like CollisionFail for them.
+	Collision Collision
+
+	// Progress, if not nil, is called after every write with the
+	// source path, the bytes copied so far and the size of the file.
+	Progress func(path string, written, size int64)
+}
+
+// Copy copies the file src to dst.
+func Copy(src, dst string, opts *CopyOptions) error {
+	o := copyOptions(opts)
+
+	fi, err := o.stat(src)
+	if err != nil {
+		return newPathError("copy", src, err)
+	}
+
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		return copySymlink(src, dst, o)
+	case fi.IsDir():
+		return newPathError("copy", src, ErrIsDir)
+	case !fi.Mode().IsRegular():
+		return newPathError("copy", src, ErrNotRegular)
+	}
+	return copyFile(src, dst, fi, o)
+}
+
+// CopyDir copies the directory tree src to dst, creating dst if needed.
+// Devices, pipes and sockets are skipped. Directory permissions and
+// times are applied after their contents have been copied, so
+// read-only directories are copied correctly.
+func CopyDir(src, dst string, opts *CopyOptions) error {
+	o := copyOptions(opts)
+
+	if err := checkNotInside(src, dst); err != nil {
+		return err
+	}
+
+	var dirs []Entry
+	walkOpts := &WalkOptions{FollowSymlinks: !o.PreserveSymlinks, FS: OS}
+
+	err := Walk(src, walkOpts, func(e Entry) error {
+		target := filepath.Join(dst, filepath.FromSlash(e.Rel))
+
+		switch {
+		case e.IsDir():
+			if err := os.Mkdir(target, 0777); err != nil && !isDir(target) {
+				return newPathError("copy", target, err)
+			}
+			dirs = append(dirs, e)
+			return nil
+
+		case e.Type() == TypeSymlink:
+			// either preserved, or a link to a file we follow
+			return Copy(e.Path, target, &o)
+
+		case e.Type() == TypeFile:
+			return copyFile(e.Path, target, e.Info, o)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// deepest first, so setting a parent read-only comes last
+	for i := len(dirs) - 1; i >= 0; i-- {
+		target := filepath.Join(dst, filepath.FromSlash(dirs[i].Rel))
+		fi, err := os.Stat(dirs[i].Path)
+		if err != nil {
+			return newPathError("copy", dirs[i].Path, err)
+		}
+		if err := o.applyMetadata(target, fi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Move moves src to dst. It renames when possible and falls back to
+// copying and deleting when src and dst are on different devices
+// (EXDEV). The fallback always preserves mode, times and symlinks.
+//
+// Unless opts.Collision is CollisionOverwrite, a file dst is reserved
+// with an exclusive create before the rename, so an existing file is
+// never replaced. A directory is not moved onto an existing dst with
+// any policy; the rename(2) system call would replace an empty
+// directory, so dst is checked first.
+//
+// If the fallback copy fails, whatever it created at dst is removed
+// and src is left in place.
+func Move(src, dst string, opts *CopyOptions) error {
+	o := copyOptions(opts)
+
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return newPathError("move", src, err)
+	}
+
+	// directories are checked, files and symlinks are reserved
+	reserved := false
+	switch {
+	case fi.IsDir():
+		if _, err := os.Lstat(dst); err == nil {
+			return newPathError("move", dst, ErrExists)
+		}
+	case o.Collision != CollisionOverwrite:
+		if dst, err = reserve(dst, fi, o.Collision); err != nil {
+			return err
+		}
+		reserved = true
+	}
+
+	err = os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, syscall.EXDEV) {
+		if reserved {
+			os.Remove(dst)
+		}
+		return newPathError("move", src, err)
+	}
+
+	// different devices: copy everything, then remove the source
+	co := o
+	co.PreserveMode, co.PreserveTimes, co.PreserveSymlinks = true, true, true
+	co.Collision = CollisionOverwrite
+
+	if fi.IsDir() {
+		if err := os.Mkdir(dst, 0700); err != nil {
+			return newPathError("move", dst, err)
+		}
+		if err = CopyDir(src, dst, &co); err != nil {
+			removeAllWritable(dst)
+		}
+	} else if err = Copy(src, dst, &co); err != nil && reserved {
+		// an atomic copy leaves an existing dst alone, but not the
+		// placeholder
+		os.Remove(dst)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(src); err != nil {
+		return newPathError("move", src, err)
+	}
+	return nil
+}
+
+// reserve creates an empty placeholder file for dst according to
+// policy and returns the name that was reserved.
+func reserve(dst string, fi os.FileInfo, policy Collision) (string, error) {
+	if fi.Mode()&os.ModeSymlink != 0 {
+		policy = CollisionFail
+	}
+
+	f, err := CreateSafeWith(dst, policy)
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	return f.Name(), nil
+}
+
+func copyOptions(opts *CopyOptions) CopyOptions {
+	if opts == nil {
+		return CopyOptions{}
+	}
+	return *opts
+}
+
+// stat returns the info used to decide how name is copied.
+func (o CopyOptions) stat(name string) (os.FileInfo, error) {
+	if o.PreserveSymlinks {
+		return os.Lstat(name)
+	}
+	return os.Stat(name)
+}
+
+// applyMetadata sets the mode and times of name from fi as
+// requested by the options.
+func (o CopyOptions) applyMetadata(name string, fi os.FileInfo) error {
+	if o.PreserveMode {
+		mode := fi.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		if err := os.Chmod(name, mode); err != nil {
+			return newPathError("copy", name, err)
+		}
+	}
+	if o.PreserveTimes {
+		if err := os.Chtimes(name, fi.ModTime(), fi.ModTime()); err != nil {
+			return newPathError("copy", name, err)
+		}
+	}
+	return nil
+}
+
+// copyFile copies the regular file src, described by fi, to dst.
+func copyFile(src, dst string, fi os.FileInfo, o CopyOptions) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return newPathError("copy", src, err)
+	}
+	defer in.Close()
+
+	var w io.Writer
+	var commit func() (string, error)
+	var abort func()
+
+	if o.Collision == CollisionOverwrite {
+		a, err := CreateAtomic(dst, fi.Mode().Perm())
+		if err != nil {
+			return newPathError("copy", dst, err)
+		}
+		w = a
+		commit = func() (string, error) { return a.Target(), a.Close() }
+		abort = func() { a.Abort() }
+	} else {
+		f, err := CreateSafeWith(dst, o.Collision)
+		if err != nil {
+			return err
+		}
+		w = f
+		commit = func() (string, error) { return f.Name(), f.Close() }
+		abort = func() {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}
+
+	if o.Progress != nil {
+		w = &progressWriter{w: w, path: src, size: fi.Size(), fn: o.Progress}
+	}
+
+	if _, err := CopyStream(w, in); err != nil {
+		abort()
+		return newPathError("copy", src, err)
+	}
+
+	name, err := commit()
+	if err != nil {
+		return newPathError("copy", dst, err)
+	}
+	return o.applyMetadata(name, fi)
+}
+
+// copySymlink recreates the symlink src at dst.
+func copySymlink(src, dst string, o CopyOptions) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return newPathError("copy", src, err)
+	}
+
+	if o.Collision != CollisionOverwrite {
+		if err := os.Symlink(target, dst); err != nil {
+			return newPathError("copy", dst, err)
+		}
+		return nil
+	}
+
+	// create the link under a temporary name and rename it
+	// over dst, so dst is replaced atomically
+	dir, base := filepath.Split(dst)
+	for i := 0; i < maxCollisionAttempts; i++ {
+		tmp := filepath.Join(dir, fmt.Sprintf(".%s.tmp-%d-%d", base, os.Getpid(), time.Now().UnixNano()))
+		err := os.Symlink(target, tmp)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return newPathError("copy", dst, err)
+		}
+		if err := os.Rename(tmp, dst); err != nil {
+			os.Remove(tmp)
+			return newPathError("copy", dst, err)
+		}
+		return nil
+	}
+	return newPathError("copy", dst, ErrExists)
+}
+
+// checkNotInside returns an error if dst is src or below it.
+func checkNotInside(src, dst string) error {
+	s, err := filepath.Abs(src)
+	if err != nil {
+		return newPathError("copy", src, err)
+	}
+	d, err := filepath.Abs(dst)
+	if err != nil {
+		return newPathError("copy", dst, err)
+	}
+	if d == s || strings.HasPrefix(d, s+string(filepath.Separator)) {
+		return newPathError("copy", dst, fmt.Errorf("destination is inside source %s", src))
+	}
+	return nil
+}
+
+func isDir(name string) bool {
+	fi, err := os.Stat(name)
+	return err == nil && fi.IsDir()
+}
+
+// progressWriter reports the number of bytes written through it.
+type progressWriter struct {
+	w       io.Writer
+	path    string
+	written int64
+	size    int64
+	fn      func(path string, written, size int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	p.fn(p.path, p.written, p.size)
+	return n, err
+}
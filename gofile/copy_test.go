@@ -0,0 +1,167 @@
+package gofile
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCopy(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if err := ioutil.WriteFile(src, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(src, 0751); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	var progress int64
+	opts := &CopyOptions{
+		PreserveMode:  true,
+		PreserveTimes: true,
+		Progress:      func(path string, written, size int64) { progress = written },
+	}
+
+	dst := filepath.Join(dir, "dst")
+	if err := Copy(src, dst, opts); err != nil {
+		t.Fatalf("Copy() error = %v", err)
+	}
+
+	fi, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0751 {
+		t.Errorf("Copy() mode = %v, want %v", fi.Mode().Perm(), os.FileMode(0751))
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Errorf("Copy() mtime = %v, want %v", fi.ModTime(), mtime)
+	}
+	if progress != 5 {
+		t.Errorf("Copy() progress = %d, want 5", progress)
+	}
+
+	if err := Copy(src, dst, nil); !errors.Is(err, ErrExists) {
+		t.Errorf("Copy() onto existing file error = %v, want %v", err, ErrExists)
+	}
+	if err := Copy(src, dst, &CopyOptions{Collision: CollisionOverwrite}); err != nil {
+		t.Errorf("Copy() with CollisionOverwrite error = %v", err)
+	}
+}
+
+func TestCopyDir(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	makeTree(t, src, map[string]string{"a": "a", "sub/b": "b", "empty/": ""})
+	if err := os.Symlink("a", filepath.Join(src, "link")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	if err := os.Chmod(filepath.Join(src, "sub"), 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(filepath.Join(src, "sub"), 0755)
+
+	dst := filepath.Join(dir, "dst")
+	opts := &CopyOptions{PreserveMode: true, PreserveSymlinks: true}
+	if err := CopyDir(src, dst, opts); err != nil {
+		t.Fatalf("CopyDir() error = %v", err)
+	}
+	defer os.Chmod(filepath.Join(dst, "sub"), 0755)
+
+	if got := walkRel(t, dst, nil); len(got) != 6 {
+		t.Errorf("CopyDir() copied %v", got)
+	}
+	if target, err := os.Readlink(filepath.Join(dst, "link")); err != nil || target != "a" {
+		t.Errorf("CopyDir() symlink = %q, %v; want %q", target, err, "a")
+	}
+	if fi, err := os.Stat(filepath.Join(dst, "sub")); err != nil || fi.Mode().Perm() != 0555 {
+		t.Errorf("CopyDir() directory mode = %v, %v; want 0555", fi.Mode().Perm(), err)
+	}
+
+	if err := CopyDir(src, filepath.Join(src, "sub", "inside"), nil); err == nil {
+		t.Errorf("CopyDir() into itself did not fail")
+	}
+}
+
+func TestMove(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+
+	if err := ioutil.WriteFile(src, []byte("src"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dst, []byte("dst"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Move(src, dst, nil); !errors.Is(err, ErrExists) {
+		t.Errorf("Move() onto existing file error = %v, want %v", err, ErrExists)
+	}
+
+	if err := Move(src, dst, &CopyOptions{Collision: CollisionRename}); err != nil {
+		t.Fatalf("Move() error = %v", err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("Move() left source behind: %v", err)
+	}
+	if got, _ := ioutil.ReadFile(filepath.Join(dir, "dst (1)")); string(got) != "src" {
+		t.Errorf("Move() moved contents = %q, want %q", got, "src")
+	}
+}
+
+func TestMoveDir(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{"src/a": "a", "empty/": ""})
+	src, empty := filepath.Join(dir, "src"), filepath.Join(dir, "empty")
+
+	for _, opts := range []*CopyOptions{nil, {Collision: CollisionOverwrite}} {
+		if err := Move(src, empty, opts); !errors.Is(err, ErrExists) {
+			t.Errorf("Move(%+v) onto an empty directory error = %v, want %v", opts, err, ErrExists)
+		}
+	}
+	if !exists(filepath.Join(src, "a")) || exists(filepath.Join(empty, "a")) {
+		t.Errorf("Move() onto an empty directory moved the source")
+	}
+}
+
+func TestMoveCrossDeviceFailure(t *testing.T) {
+	other, err := ioutil.TempDir("/dev/shm", "gofile")
+	if err != nil {
+		t.Skip("no /dev/shm:", err)
+	}
+	defer os.RemoveAll(other)
+
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{"src/a": "a", "src/b": "b"})
+	src := filepath.Join(dir, "src")
+	if err := os.Rename(filepath.Join(src, "a"), filepath.Join(other, "probe")); !errors.Is(err, syscall.EXDEV) {
+		t.Skip("/dev/shm is on the same device:", err)
+	}
+	makeTree(t, dir, map[string]string{"src/a": "a"})
+
+	// b vanishes while a is copied, failing the copy
+	dst := filepath.Join(other, "dst")
+	opts := &CopyOptions{Progress: func(path string, written, size int64) {
+		os.Remove(filepath.Join(src, "b"))
+	}}
+	if err := Move(src, dst, opts); err == nil {
+		t.Fatal("Move() succeeded")
+	}
+	if exists(dst) {
+		t.Errorf("Move() left a partial copy at dst")
+	}
+	if !exists(filepath.Join(src, "a")) {
+		t.Errorf("Move() removed the source")
+	}
+}
@@ -0,0 +1,202 @@
+package gofile
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Collision selects what CreateSafeWith does when the file
+// it is asked to create already exists.
+type Collision int
+
+const (
+	// CollisionFail returns an error wrapping ErrExists.
+	CollisionFail Collision = iota
+
+	// CollisionOverwrite truncates the existing file.
+	CollisionOverwrite
+
+	// CollisionBackup renames the existing file to name.bak,
+	// replacing any previous backup, and creates a new file.
+	CollisionBackup
+
+	// CollisionBackupNumbered renames the existing file to the
+	// first free name.~N~ and creates a new file.
+	CollisionBackupNumbered
+
+	// CollisionRename leaves the existing file alone and creates
+	// the first free "name (N).ext" instead.
+	CollisionRename
+
+	// CollisionTimestamp leaves the existing file alone and creates
+	// "name-YYYYMMDD-HHMMSS.ext" instead, adding " (N)" if that
+	// name is also taken.
+	CollisionTimestamp
+)
+
+// maxCollisionAttempts limits the number of names tried before
+// CreateSafeWith gives up.
+const maxCollisionAttempts = 1000
+
+// timestampLayout is the suffix format used by CollisionTimestamp.
+const timestampLayout = "20060102-150405"
+
+var collisionNames = []string{"fail", "overwrite", "backup", "backup-numbered", "rename", "timestamp"}
+
+func (c Collision) String() string {
+	if c < 0 || int(c) >= len(collisionNames) {
+		return fmt.Sprintf("Collision(%d)", int(c))
+	}
+	return collisionNames[c]
+}
+
+// CreateSafeWith creates the named file and applies policy if it
+// already exists. The returned file is opened O_RDWR; use its Name
+// method to find the path actually created.
+//
+// Every policy except CollisionOverwrite is built on exclusive creates
+// (O_EXCL) and atomic renames, so concurrent callers never share a
+// file or clobber each other's output.
+//
+// Errors are of type *PathError.
+func CreateSafeWith(name string, policy Collision) (*os.File, error) {
+	switch policy {
+	case CollisionFail:
+		return createExcl(name)
+	case CollisionOverwrite:
+		return Checked.Create(name)
+	case CollisionBackup:
+		return createWithBackup(name, func(tmp string) error {
+			return os.Rename(tmp, name+".bak")
+		})
+	case CollisionBackupNumbered:
+		return createWithBackup(name, func(tmp string) error {
+			return linkNumbered(tmp, name)
+		})
+	case CollisionRename:
+		return createFree(name, "")
+	case CollisionTimestamp:
+		f, err := createExcl(name)
+		if !errors.Is(err, ErrExists) {
+			return f, err
+		}
+		return createFree(name, "-"+time.Now().Format(timestampLayout))
+	}
+	return nil, newPathError("create", name, fmt.Errorf("unknown collision policy: %v", policy))
+}
+
+// createExcl creates name, failing if it already exists.
+func createExcl(name string) (*os.File, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return nil, newPathError("create", name, err)
+	}
+	return f, nil
+}
+
+// createWithBackup creates name exclusively. If name exists, it is
+// first moved to a private temporary name, which only one concurrent
+// caller can succeed at, and then handed to backup.
+func createWithBackup(name string, backup func(tmp string) error) (*os.File, error) {
+	for i := 0; i < maxCollisionAttempts; i++ {
+		f, err := createExcl(name)
+		if !errors.Is(err, ErrExists) {
+			return f, err
+		}
+
+		tmp, err := reserveName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := os.Rename(name, tmp); err != nil {
+			os.Remove(tmp)
+			if errors.Is(err, os.ErrNotExist) {
+				// someone else moved it; try again
+				continue
+			}
+			return nil, newPathError("backup", name, err)
+		}
+
+		if err := backup(tmp); err != nil {
+			// put the original back without clobbering a newer
+			// file; if that fails too, it is kept at tmp
+			if os.Link(tmp, name) == nil {
+				os.Remove(tmp)
+			}
+			return nil, newPathError("backup", name, err)
+		}
+	}
+	return nil, newPathError("create", name, fmt.Errorf("gave up after %d attempts: %w", maxCollisionAttempts, ErrExists))
+}
+
+// reserveName returns the path of a new, empty file in the directory
+// of name that is guaranteed not to be used by anyone else.
+func reserveName(name string) (string, error) {
+	dir, base := filepath.Split(name)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := ioutil.TempFile(dir, "."+base+".old-")
+	if err != nil {
+		return "", newPathError("backup", name, err)
+	}
+	tmp := f.Name()
+	f.Close()
+	return tmp, nil
+}
+
+// linkNumbered hard links tmp to the first free name.~N~ and removes
+// tmp. Link fails rather than replaces an existing name, so numbered
+// backups are never overwritten.
+func linkNumbered(tmp, name string) error {
+	for n := 1; n <= maxCollisionAttempts; n++ {
+		err := os.Link(tmp, fmt.Sprintf("%s.~%d~", name, n))
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		return os.Remove(tmp)
+	}
+	return fmt.Errorf("no free backup name after %d attempts: %w", maxCollisionAttempts, ErrExists)
+}
+
+// splitExt splits a file name into its stem and extension. Dotfiles
+// such as ".env" are all stem.
+func splitExt(file string) (stem, ext string) {
+	ext = filepath.Ext(file)
+	stem = strings.TrimSuffix(file, ext)
+	if stem == "" {
+		return file, ""
+	}
+	return stem, ext
+}
+
+// createFree exclusively creates the first free name in the sequence
+// "base<suffix>.ext", "base<suffix> (1).ext", "base<suffix> (2).ext", ...
+// If suffix is empty, the sequence starts with name itself.
+func createFree(name, suffix string) (*os.File, error) {
+	dir, file := filepath.Split(name)
+	stem, ext := splitExt(file)
+	base := dir + stem + suffix
+
+	for n := 0; n < maxCollisionAttempts; n++ {
+		candidate := base + ext
+		if n > 0 {
+			candidate = fmt.Sprintf("%s (%d)%s", base, n, ext)
+		}
+
+		f, err := createExcl(candidate)
+		if !errors.Is(err, ErrExists) {
+			return f, err
+		}
+	}
+	return nil, newPathError("create", name, fmt.Errorf("no free name after %d attempts: %w", maxCollisionAttempts, ErrExists))
+}
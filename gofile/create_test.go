@@ -0,0 +1,142 @@
+package gofile
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestCreateSafeWith(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		policy Collision
+		want   []string // patterns of the files in the directory afterwards
+	}{
+		{"overwrite", "out.txt", CollisionOverwrite, []string{"out.txt"}},
+		{"backup", "out.txt", CollisionBackup, []string{"out.txt", "out.txt.bak"}},
+		{"backup numbered", "out.txt", CollisionBackupNumbered, []string{"out.txt", "out.txt.~1~"}},
+		{"rename", "out.txt", CollisionRename, []string{"out (1).txt", "out.txt"}},
+		{"timestamp", "out.txt", CollisionTimestamp, []string{"out-????????-??????.txt", "out.txt"}},
+		{"rename dotfile", ".env", CollisionRename, []string{".env", ".env (1)"}},
+		{"timestamp dotfile", ".env", CollisionTimestamp, []string{".env", ".env-????????-??????"}},
+		{"rename dotfile with ext", ".env.local", CollisionRename, []string{".env (1).local", ".env.local"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			name := filepath.Join(dir, tt.file)
+			if err := ioutil.WriteFile(name, []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := CreateSafeWith(name, tt.policy)
+			if err != nil {
+				t.Fatalf("CreateSafeWith() error = %v", err)
+			}
+			f.Close()
+
+			if got := dirNames(t, dir); !matchNames(got, tt.want) {
+				t.Errorf("CreateSafeWith() files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateSafeWithFail(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "out.txt")
+	if err := ioutil.WriteFile(name, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CreateSafeWith(name, CollisionFail); !errors.Is(err, ErrExists) {
+		t.Errorf("CreateSafeWith() error = %v, want %v", err, ErrExists)
+	}
+	if CreateSafe(name) != nil {
+		t.Errorf("CreateSafe() returned a file for an existing name")
+	}
+}
+
+func TestCreateSafeWithConcurrent(t *testing.T) {
+	const n = 20
+	dir := t.TempDir()
+	name := filepath.Join(dir, "out.txt")
+
+	for _, policy := range []Collision{CollisionRename, CollisionBackupNumbered} {
+		var wg sync.WaitGroup
+		names := make(chan string, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				f, err := CreateSafeWith(name, policy)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				names <- f.Name()
+				f.Close()
+			}()
+		}
+		wg.Wait()
+		close(names)
+
+		if policy == CollisionRename {
+			seen := map[string]bool{}
+			for s := range names {
+				if seen[s] {
+					t.Errorf("%v: %s handed out twice", policy, s)
+				}
+				seen[s] = true
+			}
+		}
+	}
+
+	// n files from the rename pass (name itself and "out (1..n-1).txt")
+	// plus n numbered backups, one for each create in the second pass
+	if got := len(dirNames(t, dir)); got != 2*n {
+		t.Errorf("got %d files, want %d", got, 2*n)
+	}
+}
+
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// matchNames reports whether names match patterns one by one.
+func matchNames(names, patterns []string) bool {
+	if len(names) != len(patterns) {
+		return false
+	}
+	for i := range names {
+		if ok, _ := filepath.Match(patterns[i], names[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
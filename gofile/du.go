@@ -0,0 +1,140 @@
+package gofile
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// DuOptions configures DiskUsage.
+type DuOptions struct {
+	// MaxDepth limits the reported entries to the given depth below
+	// each root, like du -d. Sizes always include the whole tree.
+	// 0 means no limit; the roots are always reported.
+	MaxDepth int
+
+	// All reports files as well as directories, like du -a.
+	All bool
+
+	// SortBySize orders the result largest first instead of by path.
+	SortBySize bool
+
+	// Apparent sorts by apparent size rather than allocated size.
+	Apparent bool
+
+	// Walk is used to find files below each root and may be nil.
+	// Its MaxDepth is ignored and its Filters select the files that
+	// are counted; directories are always included.
+	Walk *WalkOptions
+}
+
+// Usage is the disk usage of a file or directory tree.
+type Usage struct {
+	Path string `json:"path"`
+	Dir  bool   `json:"dir"`
+
+	// Depth is the depth below the root, which has depth 0.
+	Depth int `json:"depth"`
+
+	// Apparent is the sum of the file sizes and Allocated the
+	// disk space used, including that of the directories.
+	Apparent  int64 `json:"apparent"`
+	Allocated int64 `json:"allocated"`
+
+	// Files is the number of files in the tree, not counting
+	// directories.
+	Files int `json:"files"`
+}
+
+// Size returns the apparent or the allocated size of u.
+func (u Usage) Size(apparent bool) int64 {
+	if apparent {
+		return u.Apparent
+	}
+	return u.Allocated
+}
+
+// DiskUsage returns the disk usage of each root and of the directories
+// (and with opts.All, files) below it. Hard links to the same file are
+// counted once, even across roots, and symlinks are not followed
+// unless opts.Walk.FollowSymlinks is set.
+//
+// Each tree is read concurrently as Walk does. Unless sorted by size,
+// the result is in walk order: roots in the order given, each followed
+// by its contents in lexical order. opts may be nil.
+func DiskUsage(roots []string, opts *DuOptions) ([]Usage, error) {
+	var o DuOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	var wo WalkOptions
+	if o.Walk != nil {
+		wo = *o.Walk
+	}
+	filters := wo.Filters
+	wo.MaxDepth, wo.Filters, wo.FS = 0, nil, OS
+
+	var usage []*Usage
+	seen := make(map[devIno]bool)
+
+	for _, root := range roots {
+		nodes := make(map[string]*Usage)
+		node := func(rel string, depth int, dir bool) *Usage {
+			u := nodes[rel]
+			if u == nil {
+				u = &Usage{Path: filepath.Join(root, filepath.FromSlash(rel)), Dir: dir, Depth: depth}
+				nodes[rel] = u
+				usage = append(usage, u)
+			}
+			return u
+		}
+
+		err := Walk(root, &wo, func(e Entry) error {
+			dir := e.IsDir()
+			if !dir && !matchAll(filters, e) {
+				return nil
+			}
+			if !dir && linkCount(e.Info) > 1 {
+				if id, ok := fileID(e.Info); ok {
+					if seen[id] {
+						return nil
+					}
+					seen[id] = true
+				}
+			}
+
+			apparent, allocated := e.Info.Size(), allocSize(e.Info)
+			rel, depth := e.Rel, e.Depth
+			for self := true; ; self = false {
+				if (o.MaxDepth <= 0 || depth <= o.MaxDepth) && (!self || dir || o.All) {
+					u := node(rel, depth, !self || dir)
+					u.Apparent += apparent
+					u.Allocated += allocated
+					if !dir {
+						u.Files++
+					}
+				}
+				if rel == "." {
+					return nil
+				}
+				rel, depth = path.Dir(rel), depth-1
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.SortBySize {
+		sort.SliceStable(usage, func(i, j int) bool {
+			return usage[i].Size(o.Apparent) > usage[j].Size(o.Apparent)
+		})
+	}
+
+	result := make([]Usage, len(usage))
+	for i, u := range usage {
+		result[i] = *u
+	}
+	return result, nil
+}
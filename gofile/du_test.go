@@ -0,0 +1,104 @@
+package gofile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiskUsage(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{
+		"a.txt":          strings.Repeat("a", 100),
+		"sub/b.txt":      strings.Repeat("b", 1000),
+		"sub/deep/c.txt": strings.Repeat("c", 10),
+		"empty/":         "",
+	})
+	if err := os.Link(filepath.Join(root, "a.txt"), filepath.Join(root, "sub", "link")); err != nil {
+		t.Skip("hard links not supported:", err)
+	}
+
+	dirSize := func(rel ...string) (n int64) {
+		for _, r := range rel {
+			fi, err := os.Lstat(filepath.Join(root, r))
+			if err != nil {
+				t.Fatal(err)
+			}
+			n += fi.Size()
+		}
+		return n
+	}
+	p := func(rel string) string { return filepath.Join(root, filepath.FromSlash(rel)) }
+
+	tests := []struct {
+		name string
+		opts *DuOptions
+		want []Usage
+	}{
+		{"directories", nil, []Usage{
+			{p("."), true, 0, 1110 + dirSize(".", "empty", "sub", "sub/deep"), 0, 3},
+			{p("empty"), true, 1, dirSize("empty"), 0, 0},
+			{p("sub"), true, 1, 1010 + dirSize("sub", "sub/deep"), 0, 2},
+			{p("sub/deep"), true, 2, 10 + dirSize("sub/deep"), 0, 1},
+		}},
+		{"max depth", &DuOptions{MaxDepth: 1, SortBySize: true, Apparent: true}, []Usage{
+			{p("."), true, 0, 1110 + dirSize(".", "empty", "sub", "sub/deep"), 0, 3},
+			{p("sub"), true, 1, 1010 + dirSize("sub", "sub/deep"), 0, 2},
+			{p("empty"), true, 1, dirSize("empty"), 0, 0},
+		}},
+		{"all files", &DuOptions{All: true, MaxDepth: 1}, []Usage{
+			{p("."), true, 0, 1110 + dirSize(".", "empty", "sub", "sub/deep"), 0, 3},
+			{p("a.txt"), false, 1, 100, 0, 1},
+			{p("empty"), true, 1, dirSize("empty"), 0, 0},
+			{p("sub"), true, 1, 1010 + dirSize("sub", "sub/deep"), 0, 2},
+		}},
+		{"filtered", &DuOptions{MaxDepth: 1, Walk: &WalkOptions{Filters: []Filter{MatchGlob("*.txt"), SizeBetween(500, 0)}}}, []Usage{
+			{p("."), true, 0, 1000 + dirSize(".", "empty", "sub", "sub/deep"), 0, 1},
+			{p("empty"), true, 1, dirSize("empty"), 0, 0},
+			{p("sub"), true, 1, 1000 + dirSize("sub", "sub/deep"), 0, 1},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DiskUsage([]string{root}, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range got {
+				if got[i].Allocated < 0 {
+					t.Errorf("%s: negative allocated size", got[i].Path)
+				}
+				got[i].Allocated = 0
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiskUsage() =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiskUsageRoots(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{"one/a": "aaaa", "two/b": "bb"})
+	if err := os.Link(filepath.Join(dir, "one", "a"), filepath.Join(dir, "two", "a")); err != nil {
+		t.Skip("hard links not supported:", err)
+	}
+
+	got, err := DiskUsage([]string{filepath.Join(dir, "one"), filepath.Join(dir, "two")}, &DuOptions{All: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, u := range got {
+		paths = append(paths, u.Path)
+	}
+	want := []string{
+		filepath.Join(dir, "one"), filepath.Join(dir, "one", "a"),
+		filepath.Join(dir, "two"), filepath.Join(dir, "two", "b"),
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("DiskUsage() paths = %v, want %v (hard link counted twice?)", paths, want)
+	}
+}
@@ -0,0 +1,276 @@
+package gofile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// partialHashSize is the number of leading bytes hashed to split
+// files of equal size before they are hashed completely.
+const partialHashSize = defaultBufSize
+
+// DupeOptions configures FindDuplicates.
+type DupeOptions struct {
+	// MinSize skips files smaller than MinSize bytes. Empty files
+	// are always skipped.
+	MinSize int64
+
+	// Walk is used to find files below each root and may be nil.
+	// Walk.Workers also bounds the number of files hashed at once.
+	Walk *WalkOptions
+}
+
+// DupeGroup is a set of files with identical contents.
+type DupeGroup struct {
+	Size  int64    `json:"size"`
+	Sum   string   `json:"sha256"`
+	Paths []string `json:"paths"`
+}
+
+// Wasted returns the number of bytes used by all but one of the files.
+func (g DupeGroup) Wasted() int64 { return g.Size * int64(len(g.Paths)-1) }
+
+// FindDuplicates returns groups of files below roots that have the same
+// contents, largest waste first. Paths within a group are sorted and
+// hard links to the same file are only reported once.
+//
+// Files are grouped by size first, then by a hash of their first 4 KiB,
+// and only files that still collide are hashed completely with SHA256.
+func FindDuplicates(roots []string, opts *DupeOptions) ([]DupeGroup, error) {
+	var o DupeOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.MinSize < 1 {
+		o.MinSize = 1
+	}
+
+	bySize, err := filesBySize(roots, o)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []DupeGroup
+	for size, paths := range bySize {
+		if len(paths) < 2 {
+			continue
+		}
+
+		partial, err := groupByHash(paths, o.Walk, func(p string) (string, error) {
+			return hashHead(p, partialHashSize)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for sum, same := range partial {
+			if len(same) < 2 {
+				continue
+			}
+
+			// the partial hash covers files this small completely
+			if size <= partialHashSize {
+				groups = append(groups, DupeGroup{Size: size, Sum: sum, Paths: same})
+				continue
+			}
+
+			full, err := groupByHash(same, o.Walk, func(p string) (string, error) {
+				return Hash(p, SHA256)
+			})
+			if err != nil {
+				return nil, err
+			}
+			for sum, dupes := range full {
+				if len(dupes) > 1 {
+					groups = append(groups, DupeGroup{Size: size, Sum: sum, Paths: dupes})
+				}
+			}
+		}
+	}
+
+	for _, g := range groups {
+		sort.Strings(g.Paths)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Wasted() != groups[j].Wasted() {
+			return groups[i].Wasted() > groups[j].Wasted()
+		}
+		return groups[i].Paths[0] < groups[j].Paths[0]
+	})
+	return groups, nil
+}
+
+// filesBySize walks roots and groups the regular files found by size.
+func filesBySize(roots []string, o DupeOptions) (map[int64][]string, error) {
+	var wo WalkOptions
+	if o.Walk != nil {
+		wo = *o.Walk
+	}
+	wo.Filters = append([]Filter{OfType(TypeFile), SizeBetween(o.MinSize, 0)}, wo.Filters...)
+	wo.FS = OS
+
+	bySize := make(map[int64][]string)
+	seen := make(map[devIno]bool)
+
+	for _, root := range roots {
+		err := Walk(root, &wo, func(e Entry) error {
+			if id, ok := fileID(e.Info); ok {
+				if seen[id] {
+					return nil
+				}
+				seen[id] = true
+			}
+			bySize[e.Info.Size()] = append(bySize[e.Info.Size()], e.Path)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bySize, nil
+}
+
+// groupByHash hashes paths concurrently with fn and groups them by sum.
+func groupByHash(paths []string, opts *WalkOptions, fn func(string) (string, error)) (map[string][]string, error) {
+	sums := make([]string, len(paths))
+	err := parallel(len(paths), workerCount(opts), func(i int) error {
+		sum, err := fn(paths[i])
+		sums[i] = sum
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]string)
+	for i, sum := range sums {
+		groups[sum] = append(groups[sum], paths[i])
+	}
+	return groups, nil
+}
+
+// hashHead returns the SHA256 of the first n bytes of the named file.
+func hashHead(path string, n int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", newPathError("hash", path, err)
+	}
+	defer f.Close()
+
+	sum, err := hashReader(io.LimitReader(f, n), SHA256, n)
+	if err != nil {
+		return "", newPathError("hash", path, err)
+	}
+	return sum, nil
+}
+
+// DedupeAction selects what Dedupe does with duplicate files.
+type DedupeAction int
+
+const (
+	// DedupeLink replaces duplicates with hard links to the kept file.
+	DedupeLink DedupeAction = iota + 1
+
+	// DedupeDelete removes duplicates permanently.
+	DedupeDelete
+
+	// DedupeTrash moves duplicates to the trash, see Trash.
+	DedupeTrash
+)
+
+func (a DedupeAction) String() string {
+	switch a {
+	case DedupeLink:
+		return "link"
+	case DedupeDelete:
+		return "delete"
+	case DedupeTrash:
+		return "trash"
+	}
+	return fmt.Sprintf("DedupeAction(%d)", int(a))
+}
+
+// Dedupe keeps the first path of g and applies action to the others.
+// With dryRun, nothing is changed. It returns the paths that were
+// changed, or would have been.
+//
+// Before anything is changed, the kept file and each duplicate are
+// hashed again and compared with g.Sum, so files modified since
+// FindDuplicates are left alone, even if their size did not change. If
+// the kept file was modified, the whole group is left alone. A group
+// without a Sum uses the current hash of the kept file.
+func Dedupe(g DupeGroup, action DedupeAction, dryRun bool) ([]string, error) {
+	if len(g.Paths) < 2 {
+		return nil, nil
+	}
+	keep := g.Paths[0]
+
+	sum, modified, err := modifiedSince(keep, g.Size, g.Sum)
+	if err != nil || modified {
+		return nil, err
+	}
+
+	var done []string
+	for _, p := range g.Paths[1:] {
+		_, modified, err := modifiedSince(p, g.Size, sum)
+		if err != nil {
+			return done, err
+		}
+		if modified {
+			continue
+		}
+
+		if !dryRun {
+			switch action {
+			case DedupeLink:
+				err = replaceWithLink(keep, p)
+			case DedupeDelete:
+				err = os.Remove(p)
+			case DedupeTrash:
+				_, err = Trash(p)
+			default:
+				err = fmt.Errorf("unknown dedupe action: %v", action)
+			}
+			if err != nil {
+				return done, newPathError("dedupe", p, err)
+			}
+		}
+		done = append(done, p)
+	}
+	return done, nil
+}
+
+// modifiedSince hashes the named file and reports whether its size or
+// SHA256 differ from size and sum, as recorded by FindDuplicates. An
+// empty sum matches any hash. The hash is returned with the result.
+func modifiedSince(path string, size int64, sum string) (string, bool, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", false, newPathError("dedupe", path, err)
+	}
+	if fi.Size() != size {
+		return "", true, nil
+	}
+	got, err := Hash(path, SHA256)
+	if err != nil {
+		return "", false, err
+	}
+	return got, sum != "" && got != sum, nil
+}
+
+// replaceWithLink atomically replaces name with a hard link to target.
+func replaceWithLink(target, name string) error {
+	tmp := filepath.Join(filepath.Dir(name), fmt.Sprintf(".%s.link-%d-%d", filepath.Base(name), os.Getpid(), time.Now().UnixNano()))
+	if err := os.Link(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package gofile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	big := strings.Repeat("x", partialHashSize+10)
+	makeTree(t, dir, map[string]string{
+		"a/small":       "same",
+		"b/small":       "same",
+		"b/other":       "diff",
+		"a/big":         big + "1",
+		"b/big":         big + "1",
+		"c/big-changed": big + "2", // same head and size, different tail
+		"c/empty":       "",
+		"d/empty":       "",
+	})
+	if err := os.Link(filepath.Join(dir, "a", "small"), filepath.Join(dir, "a", "hardlink")); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := FindDuplicates([]string{filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")}, nil)
+	if err != nil {
+		t.Fatalf("FindDuplicates() error = %v", err)
+	}
+
+	var got [][]string
+	for _, g := range groups {
+		var rel []string
+		for _, p := range g.Paths {
+			r, _ := filepath.Rel(dir, p)
+			rel = append(rel, filepath.ToSlash(r))
+		}
+		got = append(got, rel)
+	}
+	want := [][]string{
+		{"a/big", "b/big"},
+		{"a/hardlink", "b/small"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindDuplicates() = %v, want %v", got, want)
+	}
+
+	changed, err := Dedupe(groups[0], DedupeLink, false)
+	if err != nil {
+		t.Fatalf("Dedupe() error = %v", err)
+	}
+	if len(changed) != 1 {
+		t.Errorf("Dedupe() changed %v", changed)
+	}
+	a, _ := os.Stat(filepath.Join(dir, "a", "big"))
+	b, _ := os.Stat(filepath.Join(dir, "b", "big"))
+	if !os.SameFile(a, b) {
+		t.Errorf("Dedupe() did not link %s", changed)
+	}
+}
+
+func TestDedupeModified(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify string // path rewritten with the same size
+		want   []string
+	}{
+		{"unmodified", "", []string{"b", "c"}},
+		{"duplicate", "b", []string{"c"}},
+		{"kept file", "a", nil},
+	}
+	for _, tt := range tests {
+		for _, action := range []DedupeAction{DedupeLink, DedupeDelete} {
+			t.Run(tt.name+" "+action.String(), func(t *testing.T) {
+				dir := t.TempDir()
+				makeTree(t, dir, map[string]string{"a": "same", "b": "same", "c": "same"})
+				groups, err := FindDuplicates([]string{dir}, nil)
+				if err != nil || len(groups) != 1 {
+					t.Fatalf("FindDuplicates() = %v, %v", groups, err)
+				}
+				if tt.modify != "" {
+					makeTree(t, dir, map[string]string{tt.modify: "diff"})
+				}
+
+				changed, err := Dedupe(groups[0], action, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []string
+				for _, p := range changed {
+					got = append(got, filepath.Base(p))
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Dedupe() changed %v, want %v", got, tt.want)
+				}
+				if tt.modify != "" && readGen(t, filepath.Join(dir, tt.modify)) != "diff" {
+					t.Errorf("Dedupe() changed the modified file %s", tt.modify)
+				}
+			})
+		}
+	}
+}
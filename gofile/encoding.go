@@ -0,0 +1,356 @@
+package gofile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is a text encoding recognized by DetectEncoding.
+type Encoding int
+
+const (
+	// UnknownEncoding is text that cannot be decoded, such as UTF-32.
+	UnknownEncoding Encoding = iota
+	UTF8
+	UTF16LE
+	UTF16BE
+
+	// Latin1 is ISO 8859-1 as web browsers read it: bytes 0x80 to 0x9f
+	// are decoded as Windows-1252, which is what Windows exports that
+	// claim to be Latin-1 contain.
+	Latin1
+)
+
+// encodingNames match the FileType.Encoding names.
+var encodingNames = []string{"unknown", "utf-8", "utf-16le", "utf-16be", "iso-8859-1"}
+
+func (e Encoding) String() string {
+	if e < 0 || int(e) >= len(encodingNames) {
+		return fmt.Sprintf("Encoding(%d)", int(e))
+	}
+	return encodingNames[e]
+}
+
+// boms are the byte order marks recognized by DetectEncoding and
+// DetectReader, with the charset name each one implies. UTF-32 marks
+// come first because the UTF-32LE mark begins with the UTF-16LE one.
+var boms = []struct {
+	mark    []byte
+	enc     Encoding
+	charset string
+}{
+	{[]byte{0xef, 0xbb, 0xbf}, UTF8, "utf-8"},
+	{[]byte{0xff, 0xfe, 0x00, 0x00}, UnknownEncoding, "utf-32le"},
+	{[]byte{0x00, 0x00, 0xfe, 0xff}, UnknownEncoding, "utf-32be"},
+	{[]byte{0xff, 0xfe}, UTF16LE, "utf-16le"},
+	{[]byte{0xfe, 0xff}, UTF16BE, "utf-16be"},
+}
+
+// DetectEncoding guesses the encoding of text that starts with data
+// and returns it with the length of its byte order mark, or 0. At
+// most the first 512 bytes are examined.
+//
+// Without a byte order mark, text is UTF-16 if most of its even or odd
+// bytes are zero, UTF-8 if it is valid UTF-8 and Latin1 otherwise.
+func DetectEncoding(data []byte) (Encoding, int) {
+	for _, b := range boms {
+		if bytes.HasPrefix(data, b.mark) {
+			return b.enc, len(b.mark)
+		}
+	}
+
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+	if enc, ok := detectUTF16(data); ok {
+		return enc, 0
+	}
+	if utf8.Valid(data) || len(data) == sniffLen && validUTF8Prefix(data) {
+		return UTF8, 0
+	}
+	return Latin1, 0
+}
+
+// detectUTF16 recognizes UTF-16 text without a byte order mark by the
+// zero high bytes of its ASCII characters.
+func detectUTF16(data []byte) (Encoding, bool) {
+	pairs := len(data) / 2
+	if pairs < 2 {
+		return UnknownEncoding, false
+	}
+	var even, odd int
+	for i := 0; i < pairs*2; i += 2 {
+		if data[i] == 0 {
+			even++
+		}
+		if data[i+1] == 0 {
+			odd++
+		}
+	}
+	switch {
+	case odd*10 >= pairs*4 && even*10 < pairs:
+		return UTF16LE, true
+	case even*10 >= pairs*4 && odd*10 < pairs:
+		return UTF16BE, true
+	}
+	return UnknownEncoding, false
+}
+
+// validUTF8Prefix reports whether data is valid UTF-8, allowing a rune
+// cut off at the end by the sniff limit.
+func validUTF8Prefix(data []byte) bool {
+	if utf8.Valid(data) {
+		return true
+	}
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax+1; i-- {
+		if utf8.RuneStart(data[i]) {
+			return !utf8.FullRune(data[i:]) && utf8.Valid(data[:i])
+		}
+	}
+	return false
+}
+
+// converter transforms a stream one piece at a time, keeping whatever
+// it cannot convert yet, such as half a rune, for the next call.
+type converter interface {
+	// convert appends the conversion of src to dst. eof is set on the
+	// last call, which must flush anything kept back.
+	convert(dst, src []byte, eof bool) []byte
+}
+
+// convReader applies a converter to the bytes read from r.
+type convReader struct {
+	r   io.Reader
+	c   converter
+	buf []byte
+	out []byte
+	pos int
+	err error
+}
+
+func newConvReader(r io.Reader, c converter) *convReader {
+	return &convReader{r: r, c: c, buf: make([]byte, copyBufSize/2)}
+}
+
+func (cr *convReader) Read(p []byte) (int, error) {
+	for cr.pos == len(cr.out) {
+		if cr.err != nil {
+			return 0, cr.err
+		}
+		n, err := cr.r.Read(cr.buf)
+		if n > 0 || err == io.EOF {
+			cr.out = cr.c.convert(cr.out[:0], cr.buf[:n], err == io.EOF)
+			cr.pos = 0
+		}
+		cr.err = err
+	}
+	n := copy(p, cr.out[cr.pos:])
+	cr.pos += n
+	return n, nil
+}
+
+// convWriter applies a converter to the bytes written to w.
+type convWriter struct {
+	w   io.Writer
+	c   converter
+	out []byte
+}
+
+func (cw *convWriter) Write(p []byte) (int, error) {
+	cw.out = cw.c.convert(cw.out[:0], p, false)
+	if _, err := cw.w.Write(cw.out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close flushes anything held back. It does not close the underlying writer.
+func (cw *convWriter) Close() error {
+	cw.out = cw.c.convert(cw.out[:0], nil, true)
+	_, err := cw.w.Write(cw.out)
+	return err
+}
+
+// decoder converts text in an encoding to UTF-8, dropping a leading
+// byte order mark.
+type decoder struct {
+	enc     Encoding
+	started bool
+	carry   []byte // bytes of an incomplete rune
+}
+
+func newDecoder(enc Encoding) (*decoder, error) {
+	switch enc {
+	case UTF8, UTF16LE, UTF16BE, Latin1:
+		return &decoder{enc: enc}, nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrEncoding, enc)
+}
+
+func (d *decoder) convert(dst, src []byte, eof bool) []byte {
+	if len(d.carry) > 0 {
+		src = append(d.carry, src...)
+		d.carry = nil
+	}
+
+	if !d.started {
+		mark := bomOf(d.enc)
+		if len(src) < len(mark) && bytes.HasPrefix(mark, src) && !eof {
+			// wait for all of the mark before deciding
+			d.carry = append(d.carry, src...)
+			return dst
+		}
+		d.started = true
+		src = bytes.TrimPrefix(src, mark)
+	}
+
+	switch d.enc {
+	case UTF8:
+		dst = append(dst, src...)
+	case Latin1:
+		for _, c := range src {
+			dst = appendRune(dst, latin1Rune(c))
+		}
+	case UTF16LE, UTF16BE:
+		var rest []byte
+		dst, rest = d.decodeUTF16(dst, src, eof)
+		d.carry = append(d.carry, rest...)
+	}
+	return dst
+}
+
+// bomOf returns the byte order mark of enc, or nil.
+func bomOf(enc Encoding) []byte {
+	for _, b := range boms {
+		if b.enc == enc && enc != UnknownEncoding {
+			return b.mark
+		}
+	}
+	return nil
+}
+
+// decodeUTF16 appends the runes of src to dst and returns the bytes of
+// an incomplete rune at the end. At eof they are decoded as U+FFFD.
+func (d *decoder) decodeUTF16(dst, src []byte, eof bool) ([]byte, []byte) {
+	unit := func(b []byte) rune {
+		if d.enc == UTF16LE {
+			return rune(b[0]) | rune(b[1])<<8
+		}
+		return rune(b[0])<<8 | rune(b[1])
+	}
+
+	for len(src) >= 2 {
+		r := unit(src)
+		if !utf16.IsSurrogate(r) {
+			dst = appendRune(dst, r)
+			src = src[2:]
+			continue
+		}
+		if len(src) < 4 {
+			if !eof {
+				return dst, src
+			}
+			dst = appendRune(dst, utf8.RuneError)
+			src = src[2:]
+			continue
+		}
+		if r2 := utf16.DecodeRune(r, unit(src[2:])); r2 != utf8.RuneError {
+			dst = appendRune(dst, r2)
+			src = src[4:]
+			continue
+		}
+		// an unpaired surrogate
+		dst = appendRune(dst, utf8.RuneError)
+		src = src[2:]
+	}
+
+	if len(src) > 0 && eof {
+		return appendRune(dst, utf8.RuneError), nil
+	}
+	return dst, src
+}
+
+// cp1252 maps bytes 0x80 to 0x9f to the Windows-1252 characters.
+// Bytes Windows-1252 leaves undefined map to the Latin-1 controls.
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
+
+func latin1Rune(c byte) rune {
+	if c >= 0x80 && c < 0xa0 {
+		return cp1252[c-0x80]
+	}
+	return rune(c)
+}
+
+func appendRune(dst []byte, r rune) []byte {
+	if r < utf8.RuneSelf {
+		return append(dst, byte(r))
+	}
+	var b [utf8.UTFMax]byte
+	n := utf8.EncodeRune(b[:], r)
+	return append(dst, b[:n]...)
+}
+
+// NewDecoder returns a reader that converts text in enc read from r to
+// UTF-8. A leading byte order mark is dropped. Invalid input, such as
+// an unpaired UTF-16 surrogate, is decoded as U+FFFD except in UTF-8,
+// which is passed through unchanged.
+func NewDecoder(r io.Reader, enc Encoding) (io.Reader, error) {
+	d, err := newDecoder(enc)
+	if err != nil {
+		return nil, err
+	}
+	return newConvReader(r, d), nil
+}
+
+// NewDecodingWriter returns a writer that converts text in enc to UTF-8
+// and writes it to w, like NewDecoder. Close must be called to write
+// the end of the text; it does not close w.
+func NewDecodingWriter(w io.Writer, enc Encoding) (io.WriteCloser, error) {
+	d, err := newDecoder(enc)
+	if err != nil {
+		return nil, err
+	}
+	return &convWriter{w: w, c: d}, nil
+}
+
+// DecodeReader detects the encoding of the text read from r and
+// returns a reader of the text as UTF-8 without a byte order mark,
+// along with the encoding detected. Text in an encoding that cannot
+// be decoded returns an error wrapping ErrEncoding.
+func DecodeReader(r io.Reader) (io.Reader, Encoding, error) {
+	br, enc, _, err := peekEncoding(r)
+	if err != nil {
+		return nil, enc, err
+	}
+	dr, err := NewDecoder(br, enc)
+	if err != nil {
+		return nil, enc, err
+	}
+	return dr, enc, nil
+}
+
+// peekEncoding detects the encoding of the text read from r. It
+// returns a reader of all of r with the encoding and the length of
+// its byte order mark.
+func peekEncoding(r io.Reader) (*bufio.Reader, Encoding, int, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	data, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return nil, UnknownEncoding, 0, err
+	}
+	enc, bom := DetectEncoding(data)
+	return br, enc, bom, nil
+}
+
+// StripBOM returns a reader that drops a UTF-8 byte order mark from
+// the start of r. Anything else is passed through unchanged.
+func StripBOM(r io.Reader) io.Reader {
+	return newConvReader(r, &decoder{enc: UTF8})
+}
@@ -0,0 +1,142 @@
+package gofile
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+)
+
+// utf16Bytes encodes s as UTF-16 in the given byte order.
+func utf16Bytes(s string, bigEndian bool) string {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			b = append(b, byte(u>>8), byte(u))
+		} else {
+			b = append(b, byte(u), byte(u>>8))
+		}
+	}
+	return string(b)
+}
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		enc  Encoding
+		bom  int
+	}{
+		{"empty", "", UTF8, 0},
+		{"ascii", "hello\r\n", UTF8, 0},
+		{"utf-8", "héllo", UTF8, 0},
+		{"utf-8 truncated", strings.Repeat("a", sniffLen-1) + "é", UTF8, 0},
+		{"utf-8 cut short", "h\xc3", Latin1, 0},
+		{"utf-8 bom", "\xef\xbb\xbfhi", UTF8, 3},
+		{"utf-16le bom", "\xff\xfeh\x00", UTF16LE, 2},
+		{"utf-16be bom", "\xfe\xff\x00h", UTF16BE, 2},
+		{"utf-16le", utf16Bytes("a@b.com\r\n", false), UTF16LE, 0},
+		{"utf-16be", utf16Bytes("a@b.com\r\n", true), UTF16BE, 0},
+		{"utf-32le bom", "\xff\xfe\x00\x00h\x00\x00\x00", UnknownEncoding, 4},
+		{"latin-1", "caf\xe9", Latin1, 0},
+		{"windows-1252", "\x93quoted\x94", Latin1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, bom := DetectEncoding([]byte(tt.data))
+			if enc != tt.enc || bom != tt.bom {
+				t.Errorf("DetectEncoding() = %v, %d, want %v, %d", enc, bom, tt.enc, tt.bom)
+			}
+		})
+	}
+}
+
+func TestDecodeReader(t *testing.T) {
+	const text = "Zoë <zoe@example.com>, 😀\r\n"
+	tests := []struct {
+		name string
+		data string
+		enc  Encoding
+		want string
+	}{
+		{"utf-8", text, UTF8, text},
+		{"utf-8 bom", "\xef\xbb\xbf" + text, UTF8, text},
+		{"utf-16le bom", "\xff\xfe" + utf16Bytes(text, false), UTF16LE, text},
+		{"utf-16be bom", "\xfe\xff" + utf16Bytes(text, true), UTF16BE, text},
+		{"utf-16le", utf16Bytes(text, false), UTF16LE, text},
+		{"latin-1", "Zo\xeb \x93hi\x94 \x80", Latin1, "Zoë “hi” €"},
+		{"odd utf-16", "\xff\xfeh\x00i", UTF16LE, "h�"},
+		{"unpaired surrogate", "\xff\xfe\x3d\xd8h\x00", UTF16LE, "�h"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// one byte at a time, to split runes and marks between reads
+			r, enc, err := DecodeReader(iotest.OneByteReader(strings.NewReader(tt.data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if enc != tt.enc {
+				t.Errorf("encoding = %v, want %v", enc, tt.enc)
+			}
+			got, err := ioutil.ReadAll(iotest.OneByteReader(r))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("decoded %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := DecodeReader(strings.NewReader("\x00\x00\xfe\xff\x00\x00\x00h")); !errors.Is(err, ErrEncoding) {
+		t.Errorf("DecodeReader(utf-32) error = %v, want %v", err, ErrEncoding)
+	}
+}
+
+func TestDecodingWriter(t *testing.T) {
+	const text = "héllo 😀\n"
+	data := "\xff\xfe" + utf16Bytes(text, false)
+
+	var buf bytes.Buffer
+	w, err := NewDecodingWriter(&buf, UTF16LE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(data); i++ {
+		if _, err := w.Write([]byte{data[i]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != text {
+		t.Errorf("decoded %q, want %q", buf.String(), text)
+	}
+
+	if _, err := NewDecodingWriter(&buf, UnknownEncoding); !errors.Is(err, ErrEncoding) {
+		t.Errorf("NewDecodingWriter(unknown) error = %v, want %v", err, ErrEncoding)
+	}
+}
+
+func TestStripBOM(t *testing.T) {
+	tests := []struct{ data, want string }{
+		{"\xef\xbb\xbfhello", "hello"},
+		{"hello\xef\xbb\xbf", "hello\xef\xbb\xbf"},
+		{"\xef\xbb", "\xef\xbb"},
+		{"\xef\xbbx", "\xef\xbbx"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := ioutil.ReadAll(StripBOM(iotest.OneByteReader(strings.NewReader(tt.data))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("StripBOM(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
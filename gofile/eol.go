@@ -0,0 +1,264 @@
+package gofile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// LineEnding is a line terminator. The zero value leaves line endings
+// as they are.
+type LineEnding int
+
+const (
+	LF   LineEnding = iota + 1 // "\n", as on Unix
+	CRLF                       // "\r\n", as on Windows
+	CR                         // "\r", as on classic Mac OS
+)
+
+var lineEndingNames = []string{"keep", "LF", "CRLF", "CR"}
+
+func (e LineEnding) String() string {
+	if e < 0 || int(e) >= len(lineEndingNames) {
+		return fmt.Sprintf("LineEnding(%d)", int(e))
+	}
+	return lineEndingNames[e]
+}
+
+// Bytes returns the bytes of the line ending, or nil for the zero value.
+func (e LineEnding) Bytes() []byte {
+	switch e {
+	case LF:
+		return []byte("\n")
+	case CRLF:
+		return []byte("\r\n")
+	case CR:
+		return []byte("\r")
+	}
+	return nil
+}
+
+// EOLStats counts the line endings of each kind found in text.
+type EOLStats struct {
+	LF   int `json:"lf"`
+	CRLF int `json:"crlf"`
+	CR   int `json:"cr"`
+}
+
+// Lines returns the number of line endings counted.
+func (s EOLStats) Lines() int { return s.LF + s.CRLF + s.CR }
+
+// Mixed reports whether more than one kind of line ending was found.
+func (s EOLStats) Mixed() bool {
+	var kinds int
+	for _, n := range []int{s.LF, s.CRLF, s.CR} {
+		if n > 0 {
+			kinds++
+		}
+	}
+	return kinds > 1
+}
+
+// Dominant returns the most common line ending, preferring LF, then
+// CRLF, on ties. It returns 0 if no line endings were found.
+func (s EOLStats) Dominant() LineEnding {
+	switch {
+	case s.Lines() == 0:
+		return 0
+	case s.LF >= s.CRLF && s.LF >= s.CR:
+		return LF
+	case s.CRLF >= s.CR:
+		return CRLF
+	}
+	return CR
+}
+
+func (s EOLStats) String() string {
+	return fmt.Sprintf("LF=%d CRLF=%d CR=%d", s.LF, s.CRLF, s.CR)
+}
+
+// eolConverter counts line endings and replaces them with to.
+type eolConverter struct {
+	mu    sync.Mutex
+	to    []byte
+	cr    bool // src ended with "\r"
+	stats EOLStats
+}
+
+func (c *eolConverter) convert(dst, src []byte, eof bool) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	end := func(orig []byte, count *int) {
+		*count++
+		if c.to != nil {
+			orig = c.to
+		}
+		dst = append(dst, orig...)
+	}
+
+	for _, b := range src {
+		if c.cr {
+			c.cr = false
+			if b == '\n' {
+				end([]byte("\r\n"), &c.stats.CRLF)
+				continue
+			}
+			end([]byte("\r"), &c.stats.CR)
+		}
+		switch b {
+		case '\r':
+			c.cr = true
+		case '\n':
+			end([]byte("\n"), &c.stats.LF)
+		default:
+			dst = append(dst, b)
+		}
+	}
+	if c.cr && eof {
+		c.cr = false
+		end([]byte("\r"), &c.stats.CR)
+	}
+	return dst
+}
+
+func (c *eolConverter) counts() EOLStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// EOLReader converts the line endings of the text it reads and counts
+// the line endings it found. Stats may be called while reading.
+type EOLReader struct {
+	c *eolConverter
+	r *convReader
+}
+
+// NewEOLReader returns a reader of r with every "\r\n", "\r" and "\n"
+// replaced by to. If to is 0, line endings are only counted.
+func NewEOLReader(r io.Reader, to LineEnding) *EOLReader {
+	c := &eolConverter{to: to.Bytes()}
+	return &EOLReader{c: c, r: newConvReader(r, c)}
+}
+
+func (r *EOLReader) Read(p []byte) (int, error) { return r.r.Read(p) }
+
+// Stats returns the line endings read so far, before conversion.
+func (r *EOLReader) Stats() EOLStats { return r.c.counts() }
+
+// EOLWriter converts the line endings of the text written to it, like
+// EOLReader. A "\r" at the end of a write is held back until the next
+// byte shows whether it starts a "\r\n", so Close must be called when
+// done.
+type EOLWriter struct {
+	c *eolConverter
+	w *convWriter
+}
+
+// NewEOLWriter returns a writer to w that replaces every "\r\n", "\r"
+// and "\n" with to. If to is 0, line endings are only counted.
+func NewEOLWriter(w io.Writer, to LineEnding) *EOLWriter {
+	c := &eolConverter{to: to.Bytes()}
+	return &EOLWriter{c: c, w: &convWriter{w: w, c: c}}
+}
+
+func (w *EOLWriter) Write(p []byte) (int, error) { return w.w.Write(p) }
+
+// Stats returns the line endings written so far, before conversion.
+func (w *EOLWriter) Stats() EOLStats { return w.c.counts() }
+
+// Close writes a held back "\r". It does not close the underlying writer.
+func (w *EOLWriter) Close() error { return w.w.Close() }
+
+// TextInfo describes the encoding and line endings of text.
+type TextInfo struct {
+	Encoding Encoding `json:"encoding"`
+	BOM      bool     `json:"bom"`
+	EOL      EOLStats `json:"eol"`
+}
+
+// InspectText detects the encoding of the text read from r and counts
+// its line endings. Text in an encoding that cannot be decoded returns
+// an error wrapping ErrEncoding.
+func InspectText(r io.Reader) (TextInfo, error) {
+	br, enc, bom, err := peekEncoding(r)
+	info := TextInfo{Encoding: enc, BOM: bom > 0}
+	if err != nil {
+		return info, err
+	}
+	dr, err := NewDecoder(br, enc)
+	if err != nil {
+		return info, err
+	}
+	er := NewEOLReader(dr, 0)
+	_, err = CopyStream(io.Discard, er)
+	info.EOL = er.Stats()
+	return info, err
+}
+
+// NormalizeOptions configures NormalizeFile.
+type NormalizeOptions struct {
+	// EOL is the line ending to convert to. 0 keeps line endings.
+	EOL LineEnding
+
+	// Encoding is the encoding of the file. 0 detects it.
+	Encoding Encoding
+}
+
+// NormalizeFile rewrites the named file as UTF-8 without a byte order
+// mark, with its line endings converted to opts.EOL. The file is
+// replaced atomically and only if its contents change. It returns the
+// encoding and line endings the file had. opts may be nil.
+func NormalizeFile(name string, opts *NormalizeOptions) (TextInfo, error) {
+	var o NormalizeOptions
+	if opts != nil {
+		o = *opts
+	}
+	var info TextInfo
+
+	f, err := os.Open(name)
+	if err != nil {
+		return info, newPathError("normalize", name, err)
+	}
+	defer f.Close()
+
+	br, enc, bom, err := peekEncoding(f)
+	if err != nil {
+		return info, newPathError("normalize", name, err)
+	}
+	if o.Encoding != UnknownEncoding && o.Encoding != enc {
+		// the mark of another encoding is just text in this one
+		enc, bom = o.Encoding, 0
+	}
+	info.Encoding, info.BOM = enc, bom > 0
+
+	dr, err := NewDecoder(br, enc)
+	if err != nil {
+		return info, newPathError("normalize", name, err)
+	}
+
+	a, err := CreateAtomic(name, 0)
+	if err != nil {
+		return info, err
+	}
+	defer a.Abort()
+
+	er := NewEOLReader(dr, o.EOL)
+	_, err = CopyStream(a, er)
+	info.EOL = er.Stats()
+	if err != nil {
+		return info, newPathError("normalize", name, err)
+	}
+
+	// leave an unchanged file, and its modification time, alone
+	unchanged := info.Encoding == UTF8 && !info.BOM
+	if o.EOL != 0 && info.EOL.Lines() > 0 && (info.EOL.Mixed() || info.EOL.Dominant() != o.EOL) {
+		unchanged = false
+	}
+	if unchanged {
+		return info, nil
+	}
+	return info, a.Close()
+}
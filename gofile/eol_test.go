@@ -0,0 +1,157 @@
+package gofile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestEOLReader(t *testing.T) {
+	const mixed = "a\r\nb\nc\rd\r\n\r\re"
+	tests := []struct {
+		name  string
+		to    LineEnding
+		want  string
+		stats EOLStats
+	}{
+		{"keep", 0, mixed, EOLStats{LF: 1, CRLF: 2, CR: 3}},
+		{"lf", LF, "a\nb\nc\nd\n\n\ne", EOLStats{LF: 1, CRLF: 2, CR: 3}},
+		{"crlf", CRLF, "a\r\nb\r\nc\r\nd\r\n\r\n\r\ne", EOLStats{LF: 1, CRLF: 2, CR: 3}},
+		{"cr", CR, "a\rb\rc\rd\r\r\re", EOLStats{LF: 1, CRLF: 2, CR: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewEOLReader(iotest.OneByteReader(strings.NewReader(mixed)), tt.to)
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("read %q, want %q", got, tt.want)
+			}
+			if r.Stats() != tt.stats {
+				t.Errorf("Stats() = %v, want %v", r.Stats(), tt.stats)
+			}
+		})
+	}
+}
+
+func TestEOLWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewEOLWriter(&buf, LF)
+	for _, s := range []string{"one\r", "\ntwo\r", "three\r"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := buf.String(); got != "one\ntwo\nthree" {
+		t.Errorf("before Close %q, want %q", got, "one\ntwo\nthree")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "one\ntwo\nthree\n" {
+		t.Errorf("wrote %q, want %q", got, "one\ntwo\nthree\n")
+	}
+	if want := (EOLStats{CRLF: 1, CR: 2}); w.Stats() != want {
+		t.Errorf("Stats() = %v, want %v", w.Stats(), want)
+	}
+}
+
+func TestEOLStats(t *testing.T) {
+	tests := []struct {
+		stats    EOLStats
+		mixed    bool
+		dominant LineEnding
+	}{
+		{EOLStats{}, false, 0},
+		{EOLStats{CRLF: 3}, false, CRLF},
+		{EOLStats{LF: 1, CRLF: 1}, true, LF},
+		{EOLStats{LF: 1, CRLF: 2, CR: 2}, true, CRLF},
+		{EOLStats{LF: 1, CR: 2}, true, CR},
+	}
+	for _, tt := range tests {
+		if got := tt.stats.Mixed(); got != tt.mixed {
+			t.Errorf("%v Mixed() = %v, want %v", tt.stats, got, tt.mixed)
+		}
+		if got := tt.stats.Dominant(); got != tt.dominant {
+			t.Errorf("%v Dominant() = %v, want %v", tt.stats, got, tt.dominant)
+		}
+	}
+}
+
+func TestInspectText(t *testing.T) {
+	info, err := InspectText(strings.NewReader("\xff\xfe" + utf16Bytes("a\r\nb\nc", false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TextInfo{Encoding: UTF16LE, BOM: true, EOL: EOLStats{LF: 1, CRLF: 1}}
+	if info != want {
+		t.Errorf("InspectText() = %+v, want %+v", info, want)
+	}
+}
+
+func TestNormalizeFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		opts    *NormalizeOptions
+		want    string
+		info    TextInfo
+		changed bool
+	}{
+		{"bom and crlf", "\xef\xbb\xbfa@b.com\r\nc@d.com\r\n", &NormalizeOptions{EOL: LF}, "a@b.com\nc@d.com\n",
+			TextInfo{UTF8, true, EOLStats{CRLF: 2}}, true},
+		{"utf-16", "\xff\xfe" + utf16Bytes("é\r\n", false), &NormalizeOptions{EOL: LF}, "é\n",
+			TextInfo{UTF16LE, true, EOLStats{CRLF: 1}}, true},
+		{"latin-1 kept endings", "caf\xe9\r\n", nil, "café\r\n",
+			TextInfo{Latin1, false, EOLStats{CRLF: 1}}, true},
+		{"forced encoding", "\xef\xbb\xbf", &NormalizeOptions{Encoding: Latin1}, "ï»¿",
+			TextInfo{Latin1, false, EOLStats{}}, true},
+		{"mixed", "a\nb\r\n", &NormalizeOptions{EOL: LF}, "a\nb\n",
+			TextInfo{UTF8, false, EOLStats{LF: 1, CRLF: 1}}, true},
+		{"already normal", "a\nb\n", &NormalizeOptions{EOL: LF}, "a\nb\n",
+			TextInfo{UTF8, false, EOLStats{LF: 2}}, false},
+		{"no endings", "a", &NormalizeOptions{EOL: CRLF}, "a",
+			TextInfo{UTF8, false, EOLStats{}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "list.txt")
+			if err := ioutil.WriteFile(name, []byte(tt.data), 0640); err != nil {
+				t.Fatal(err)
+			}
+			old := time.Now().Add(-time.Hour).Truncate(time.Second)
+			if err := os.Chtimes(name, old, old); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := NormalizeFile(name, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info != tt.info {
+				t.Errorf("NormalizeFile() = %+v, want %+v", info, tt.info)
+			}
+			if got := readGen(t, name); got != tt.want {
+				t.Errorf("contents %q, want %q", got, tt.want)
+			}
+
+			fi, err := os.Stat(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed := !fi.ModTime().Equal(old); changed != tt.changed {
+				t.Errorf("file rewritten = %v, want %v", changed, tt.changed)
+			}
+			if fi.Mode().Perm() != 0640 {
+				t.Errorf("mode = %v, want %v", fi.Mode().Perm(), os.FileMode(0640))
+			}
+		})
+	}
+}
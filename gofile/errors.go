@@ -0,0 +1,126 @@
+package gofile
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+// Sentinel errors returned by gofile operations. Errors returned by the
+// Checked API wrap one of these, so callers can test for them with
+// errors.Is. ErrNotExist, ErrExists and ErrPermission are the same values
+// as their io/fs counterparts, so errors.Is(err, os.ErrNotExist) works too.
+var (
+	ErrNotExist     = fs.ErrNotExist
+	ErrExists       = fs.ErrExist
+	ErrPermission   = fs.ErrPermission
+	ErrIsDir        = errors.New("is a directory")
+	ErrNotDir       = errors.New("not a directory")
+	ErrNotRegular   = errors.New("not a regular file")
+	ErrSymlinkLoop  = errors.New("symlink loop")
+	ErrOutsideRoot  = errors.New("path escapes root")
+	ErrLocked       = errors.New("file is locked")
+	ErrArchiveLimit = errors.New("archive exceeds extraction limit")
+	ErrEncoding     = errors.New("unsupported text encoding")
+
+	// ErrNoXattr is returned for an extended attribute that is not
+	// set. ErrXattrUnsupported is returned when setting one on a file
+	// system or platform without extended attributes; reading from
+	// such files finds no attributes instead.
+	ErrNoXattr          = errors.New("no such extended attribute")
+	ErrXattrUnsupported = errors.New("extended attributes not supported")
+)
+
+// PathError records a failed gofile operation along with the path that
+// caused it. Kind holds the sentinel error that best describes the
+// failure (or nil) and Err holds the underlying error.
+type PathError struct {
+	Op   string
+	Path string
+	Kind error
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *PathError) Unwrap() error { return e.Err }
+
+// Is reports whether target is the sentinel error for this failure.
+func (e *PathError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+// newPathError returns err wrapped in a *PathError with its Kind set
+// from the underlying error. A nil err returns nil.
+func newPathError(op, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	// unwrap *os.PathError so the message is not repeated
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+
+	return &PathError{Op: op, Path: path, Kind: kindOf(err), Err: err}
+}
+
+// CmdError records a failed external command, such as a git command
+// run by gogit, along with the operation it was part of.
+type CmdError struct {
+	Op  string
+	Cmd string
+	Err error
+}
+
+func (e *CmdError) Error() string {
+	return e.Op + " `" + e.Cmd + "`: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *CmdError) Unwrap() error { return e.Err }
+
+// NewCmdError returns err wrapped in a *CmdError. A nil err returns nil.
+func NewCmdError(op, cmd string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CmdError{Op: op, Cmd: cmd, Err: err}
+}
+
+// kindOf returns the sentinel error matching err, or nil.
+func kindOf(err error) error {
+	switch {
+	case errors.Is(err, ErrNotExist):
+		return ErrNotExist
+	case errors.Is(err, ErrExists):
+		return ErrExists
+	case errors.Is(err, ErrPermission):
+		return ErrPermission
+	case errors.Is(err, ErrIsDir), errors.Is(err, syscall.EISDIR):
+		return ErrIsDir
+	case errors.Is(err, ErrNotDir), errors.Is(err, syscall.ENOTDIR):
+		return ErrNotDir
+	case errors.Is(err, ErrNotRegular):
+		return ErrNotRegular
+	case errors.Is(err, ErrSymlinkLoop), errors.Is(err, syscall.ELOOP):
+		return ErrSymlinkLoop
+	case errors.Is(err, ErrOutsideRoot):
+		return ErrOutsideRoot
+	case errors.Is(err, ErrLocked), errors.Is(err, syscall.EWOULDBLOCK):
+		return ErrLocked
+	case errors.Is(err, ErrArchiveLimit):
+		return ErrArchiveLimit
+	case errors.Is(err, ErrEncoding):
+		return ErrEncoding
+	case errors.Is(err, ErrNoXattr):
+		return ErrNoXattr
+	case errors.Is(err, ErrXattrUnsupported):
+		return ErrXattrUnsupported
+	}
+	return nil
+}
@@ -6,8 +6,6 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-
-	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -18,19 +16,13 @@ const (
 	minRead         = bytes.MinRead
 )
 
-// Err logs errors and passes them through unchanged.
-func Err(err error) error {
-	if err != nil {
-		log.Error(err)
-	}
-	return err
-}
-
 // Stat returns the os.FileInfo for file if it exists.
 // If the file does not exist, nil is returned.
-// Errors are logged if Err is active.
+// Errors are passed to the error handler (see SetErrorHandler).
+//
+// Use On(nil).Stat to have the error returned instead.
 func Stat(file string) os.FileInfo {
-	fi, err := os.Stat(file)
+	fi, err := On(nil).Stat(file)
 	if err != nil {
 		Err(err)
 		return nil
@@ -41,7 +33,12 @@ func Stat(file string) os.FileInfo {
 // StatCheck returns file information (after symlink evaluation)
 // using os.Stat(). If the file does not exist, is not a regular file,
 // or if the user lacks adequate permissions, an error is returned.
+// Like Stat, it uses the file system set with SetFS.
 func StatCheck(filename string) (os.FileInfo, error) {
+	return On(nil).StatCheck(filename)
+}
+
+func statCheckOS(filename string) (os.FileInfo, error) {
 
 	// EvalSymlinks also calls Abs and Clean as well as
 	// checking for existance of the file.
@@ -55,18 +52,17 @@ func StatCheck(filename string) (os.FileInfo, error) {
 		return nil, err
 	}
 
-	//Check 'others' permission
-	m := fi.Mode()
-	if m&(1<<2) == 0 {
-		return nil, fmt.Errorf("insufficient permissions: %v", filename)
+	// Check effective read permission for the current user
+	if a := accessInfo(filename, fi); !a.CanRead() {
+		return nil, fmt.Errorf("insufficient permissions: %v: %w", a, ErrPermission)
 	}
 
 	if fi.IsDir() {
-		return nil, fmt.Errorf("the filename %s refers to a directory", filename)
+		return nil, fmt.Errorf("the filename %s refers to a directory: %w", filename, ErrIsDir)
 	}
 
 	if !fi.Mode().IsRegular() {
-		return nil, fmt.Errorf("the filename %s is not a regular file", filename)
+		return nil, fmt.Errorf("the filename %s is not a regular file: %w", filename, ErrNotRegular)
 	}
 
 	return fi, err
@@ -87,7 +83,15 @@ func InitialCapacity(capacity int64) int {
 }
 
 // Mode returns the filemode of file.
-func Mode(file string) os.FileMode { return Stat(file).Mode() }
+// If the file does not exist, 0 is returned.
+// Errors are passed to the error handler (see SetErrorHandler).
+//
+// Use On(nil).Mode to have the error returned instead.
+func Mode(file string) os.FileMode {
+	m, err := On(nil).Mode(file)
+	Err(err)
+	return m
+}
 
 // Create creates or truncates the named file and returns an opened file as io.ReadCloser.
 //
@@ -100,16 +104,15 @@ func Mode(file string) os.FileMode { return Stat(file).Mode() }
 // If the file cannot be created, an error of type *PathError
 // is returned.
 //
-// Errors are logged if gofile.Err is active.
+// Errors are passed to the error handler (see SetErrorHandler).
+//
+// Use On(nil).Create to have the error returned instead.
 func Create(filename string) io.ReadWriteCloser {
-
-	// OpenFile is the generalized open call; most users will use Open or Create instead. It opens the named file with specified flag (O_RDONLY etc.). If the file does not exist, and the O_CREATE flag is passed, it is created with mode perm (before umask). If successful, methods on the returned File can be used for I/O. If there is an error, it will be of type *PathError.
-	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	f, err := On(nil).Create(filename)
 	if err != nil {
 		Err(err)
 		return nil
 	}
-
 	return f
 }
 
@@ -118,17 +121,15 @@ func Create(filename string) io.ReadWriteCloser {
 // If successful, methods on the returned File can be used
 // for I/O; the associated file descriptor has mode O_RDWR.
 //
-// If the file already exists, nil is returned.
-// Errors are logged if Err is active.
-//
-// If the file already exists, of an error occurs, it returns
-// nil and an error is sent to Err. If there is an error, it
-// will be of type *PathError.
+// The file is created exclusively (O_EXCL): if it already exists,
+// nil is returned and an error wrapping ErrExists is sent to Err.
+// Errors are passed to the error handler (see SetErrorHandler).
 //
+// Use CreateSafeWith to back up or rename around existing files.
 func CreateSafe(filename string) io.ReadWriteCloser {
-	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	f, err := On(nil).CreateSafe(filename)
 	if err != nil {
-		Err(fmt.Errorf("file already exists (%s): %v", filename, err))
+		Err(err)
 		return nil
 	}
 	return f
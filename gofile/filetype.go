@@ -0,0 +1,247 @@
+package gofile
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// Category is the broad kind of content found in a file.
+type Category int
+
+const (
+	CategoryUnknown Category = iota
+	CategoryText
+	CategoryImage
+	CategoryAudio
+	CategoryVideo
+	CategoryArchive
+	CategoryDocument
+	CategoryExecutable
+	CategoryBinary
+)
+
+var categoryNames = []string{"unknown", "text", "image", "audio", "video", "archive", "document", "executable", "binary"}
+
+func (c Category) String() string {
+	if c < 0 || int(c) >= len(categoryNames) {
+		return categoryNames[CategoryUnknown]
+	}
+	return categoryNames[c]
+}
+
+// FileType is the result of content sniffing.
+type FileType struct {
+	// MIME is the media type, e.g. "image/png" or "text/plain; charset=utf-8".
+	MIME string
+
+	// Category is the broad kind of content.
+	Category Category
+
+	// Encoding is the text encoding for text content ("utf-8",
+	// "utf-16le", "utf-16be", ...) and empty otherwise.
+	Encoding string
+
+	// BOM is the length of the byte order mark at the start of the
+	// content, or 0 if there is none.
+	BOM int
+}
+
+// IsText reports whether the content was detected as text.
+func (t FileType) IsText() bool { return t.Category == CategoryText }
+
+// sniffLen is the number of bytes examined by DetectType.
+const sniffLen = chunk
+
+type signature struct {
+	offset   int
+	magic    []byte
+	mime     string
+	category Category
+}
+
+// signatures is checked in order; the first match wins.
+var signatures = []signature{
+	// images
+	{0, []byte("\x89PNG\r\n\x1a\n"), "image/png", CategoryImage},
+	{0, []byte("\xff\xd8\xff"), "image/jpeg", CategoryImage},
+	{0, []byte("GIF87a"), "image/gif", CategoryImage},
+	{0, []byte("GIF89a"), "image/gif", CategoryImage},
+	{0, []byte("II*\x00"), "image/tiff", CategoryImage},
+	{0, []byte("MM\x00*"), "image/tiff", CategoryImage},
+	{0, []byte("\x00\x00\x01\x00"), "image/x-icon", CategoryImage},
+
+	// documents
+	{0, []byte("%PDF-"), "application/pdf", CategoryDocument},
+	{0, []byte("{\\rtf"), "application/rtf", CategoryDocument},
+
+	// archives and compression
+	{0, []byte("PK\x03\x04"), "application/zip", CategoryArchive},
+	{0, []byte("PK\x05\x06"), "application/zip", CategoryArchive},
+	{0, []byte("\x1f\x8b"), "application/gzip", CategoryArchive},
+	{0, []byte("BZh"), "application/x-bzip2", CategoryArchive},
+	{0, []byte("\xfd7zXZ\x00"), "application/x-xz", CategoryArchive},
+	{0, []byte("7z\xbc\xaf\x27\x1c"), "application/x-7z-compressed", CategoryArchive},
+	{0, []byte("Rar!\x1a\x07"), "application/vnd.rar", CategoryArchive},
+	{0, []byte("\x28\xb5\x2f\xfd"), "application/zstd", CategoryArchive},
+	{257, []byte("ustar"), "application/x-tar", CategoryArchive},
+
+	// executables
+	{0, []byte("\x7fELF"), "application/x-elf", CategoryExecutable},
+	{0, []byte("\xfe\xed\xfa\xce"), "application/x-mach-binary", CategoryExecutable},
+	{0, []byte("\xfe\xed\xfa\xcf"), "application/x-mach-binary", CategoryExecutable},
+	{0, []byte("\xce\xfa\xed\xfe"), "application/x-mach-binary", CategoryExecutable},
+	{0, []byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary", CategoryExecutable},
+	{0, []byte("MZ"), "application/vnd.microsoft.portable-executable", CategoryExecutable},
+	{0, []byte("\x00asm"), "application/wasm", CategoryExecutable},
+
+	// audio
+	{0, []byte("ID3"), "audio/mpeg", CategoryAudio},
+	{0, []byte("fLaC"), "audio/flac", CategoryAudio},
+	{0, []byte("OggS"), "audio/ogg", CategoryAudio},
+	{0, []byte("MThd"), "audio/midi", CategoryAudio},
+
+	// video
+	{0, []byte("\x1a\x45\xdf\xa3"), "video/x-matroska", CategoryVideo},
+	{0, []byte("FLV\x01"), "video/x-flv", CategoryVideo},
+}
+
+// DetectType sniffs the content type of data. At most the first 512
+// bytes are examined. It never returns an error; content it does not
+// recognize is reported as text or as application/octet-stream.
+func DetectType(data []byte) FileType {
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+	if len(data) == 0 {
+		return FileType{MIME: "text/plain", Category: CategoryText}
+	}
+
+	if t, ok := detectBOM(data); ok {
+		return t
+	}
+
+	for _, sig := range signatures {
+		if len(data) >= sig.offset+len(sig.magic) && bytes.Equal(data[sig.offset:sig.offset+len(sig.magic)], sig.magic) {
+			return FileType{MIME: sig.mime, Category: sig.category}
+		}
+	}
+
+	if t, ok := detectContainer(data); ok {
+		return t
+	}
+
+	if isUTF8Text(data) {
+		return FileType{MIME: "text/plain; charset=utf-8", Category: CategoryText, Encoding: "utf-8"}
+	}
+	return FileType{MIME: "application/octet-stream", Category: CategoryBinary}
+}
+
+// DetectReader sniffs the content type of the first bytes read from r.
+func DetectReader(r io.Reader) (FileType, error) {
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return FileType{}, err
+	}
+	return DetectType(buf[:n]), nil
+}
+
+// DetectFile sniffs the content type of the named file.
+func DetectFile(name string) (FileType, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return FileType{}, newPathError("detect", name, err)
+	}
+	defer f.Close()
+
+	t, err := DetectReader(f)
+	if err != nil {
+		return t, newPathError("detect", name, err)
+	}
+	return t, nil
+}
+
+// detectBOM reports text content that starts with a byte order mark.
+func detectBOM(data []byte) (FileType, bool) {
+	for _, b := range boms {
+		if bytes.HasPrefix(data, b.mark) {
+			return FileType{
+				MIME:     "text/plain; charset=" + b.charset,
+				Category: CategoryText,
+				Encoding: b.charset,
+				BOM:      len(b.mark),
+			}, true
+		}
+	}
+	return FileType{}, false
+}
+
+// detectContainer recognizes formats that need more than a fixed
+// magic number, such as RIFF and ISO base media containers whose
+// type is given by a tag that follows a generic header.
+func detectContainer(data []byte) (FileType, bool) {
+	if len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) {
+		switch string(data[8:12]) {
+		case "WEBP":
+			return FileType{MIME: "image/webp", Category: CategoryImage}, true
+		case "WAVE":
+			return FileType{MIME: "audio/wav", Category: CategoryAudio}, true
+		case "AVI ":
+			return FileType{MIME: "video/x-msvideo", Category: CategoryVideo}, true
+		}
+	}
+
+	if len(data) >= 12 && bytes.Equal(data[4:8], []byte("ftyp")) {
+		switch string(data[8:12]) {
+		case "qt  ":
+			return FileType{MIME: "video/quicktime", Category: CategoryVideo}, true
+		case "M4A ", "M4B ":
+			return FileType{MIME: "audio/mp4", Category: CategoryAudio}, true
+		case "heic", "heix", "mif1":
+			return FileType{MIME: "image/heic", Category: CategoryImage}, true
+		case "avif":
+			return FileType{MIME: "image/avif", Category: CategoryImage}, true
+		default:
+			return FileType{MIME: "video/mp4", Category: CategoryVideo}, true
+		}
+	}
+
+	// "BM" alone is too common at the start of text; also
+	// require the reserved header bytes to be zero
+	if len(data) >= 14 && data[0] == 'B' && data[1] == 'M' && bytes.Equal(data[6:10], []byte{0, 0, 0, 0}) {
+		return FileType{MIME: "image/bmp", Category: CategoryImage}, true
+	}
+
+	// MPEG audio frame sync without an ID3 tag
+	if len(data) >= 2 && data[0] == 0xff && data[1]&0xe0 == 0xe0 {
+		return FileType{MIME: "audio/mpeg", Category: CategoryAudio}, true
+	}
+
+	return FileType{}, false
+}
+
+// isUTF8Text reports whether data looks like UTF-8 text: valid UTF-8,
+// allowing a rune cut off at the end, and free of control characters
+// other than whitespace.
+func isUTF8Text(data []byte) bool {
+	for i := 0; i < len(data); {
+		c := data[i]
+		if c < utf8.RuneSelf {
+			if c < 0x20 && c != '\t' && c != '\n' && c != '\r' && c != '\f' && c != 0x1b {
+				return false
+			}
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size <= 1 {
+			// a multi-byte rune truncated by the sniff limit is fine
+			return len(data)-i < utf8.UTFMax && !utf8.FullRune(data[i:])
+		}
+		i += size
+	}
+	return true
+}
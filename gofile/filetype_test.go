@@ -0,0 +1,63 @@
+package gofile
+
+import (
+	"testing"
+)
+
+func TestDetectType(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		mime     string
+		category Category
+	}{
+		{"empty", "", "text/plain", CategoryText},
+		{"ascii", "hello, world\n", "text/plain; charset=utf-8", CategoryText},
+		{"utf-8", "héllo wörld", "text/plain; charset=utf-8", CategoryText},
+		{"utf-8 bom", "\xef\xbb\xbfhello", "text/plain; charset=utf-8", CategoryText},
+		{"utf-16le bom", "\xff\xfeh\x00i\x00", "text/plain; charset=utf-16le", CategoryText},
+		{"utf-16be bom", "\xfe\xff\x00h\x00i", "text/plain; charset=utf-16be", CategoryText},
+		{"binary", "\x00\x01\x02\x03", "application/octet-stream", CategoryBinary},
+		{"png", "\x89PNG\r\n\x1a\n\x00\x00", "image/png", CategoryImage},
+		{"jpeg", "\xff\xd8\xff\xe0", "image/jpeg", CategoryImage},
+		{"webp", "RIFF\x00\x00\x00\x00WEBPVP8 ", "image/webp", CategoryImage},
+		{"bmp text", "BMW motorcycles", "text/plain; charset=utf-8", CategoryText},
+		{"pdf", "%PDF-1.7\n", "application/pdf", CategoryDocument},
+		{"zip", "PK\x03\x04\x14\x00", "application/zip", CategoryArchive},
+		{"gzip", "\x1f\x8b\x08\x00", "application/gzip", CategoryArchive},
+		{"elf", "\x7fELF\x02\x01\x01", "application/x-elf", CategoryExecutable},
+		{"mp4", "\x00\x00\x00\x18ftypmp42", "video/mp4", CategoryVideo},
+		{"mkv", "\x1a\x45\xdf\xa3\x01", "video/x-matroska", CategoryVideo},
+		{"mp3", "ID3\x03\x00", "audio/mpeg", CategoryAudio},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectType([]byte(tt.data))
+			if got.MIME != tt.mime || got.Category != tt.category {
+				t.Errorf("DetectType() = %v (%v), want %v (%v)", got.MIME, got.Category, tt.mime, tt.category)
+			}
+		})
+	}
+}
+
+func TestDetectTypeTar(t *testing.T) {
+	data := make([]byte, 512)
+	copy(data, "file.txt")
+	copy(data[257:], "ustar\x0000")
+
+	if got := DetectType(data); got.MIME != "application/x-tar" {
+		t.Errorf("DetectType() = %v, want application/x-tar", got.MIME)
+	}
+}
+
+func TestDetectTypeTruncatedRune(t *testing.T) {
+	data := make([]byte, 0, sniffLen+2)
+	for len(data) < sniffLen-1 {
+		data = append(data, 'a')
+	}
+	data = append(data, "é"...) // split by the sniff limit
+
+	if got := DetectType(data); !got.IsText() {
+		t.Errorf("DetectType() = %v, want text", got.MIME)
+	}
+}
@@ -0,0 +1,267 @@
+package gofile
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FS is a file system that gofile can read and write. It is an io/fs
+// file system, so it works with fs.WalkDir, fs.ReadFile, fs.Glob and
+// the like, extended with the operations needed to change files.
+//
+// OS and DirFS use the operating system; MemFS keeps everything in
+// memory, which makes code built on gofile testable without touching
+// the disk.
+type FS interface {
+	fs.StatFS
+	fs.ReadDirFS
+	fs.ReadFileFS
+
+	// Lstat is like Stat but does not follow a final symlink.
+	Lstat(name string) (fs.FileInfo, error)
+
+	// OpenFile opens name with the os.O_* flags. New files are
+	// created with perm.
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+
+	Mkdir(name string, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Rename(oldname, newname string) error
+	Chmod(name string, mode fs.FileMode) error
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// File is an open file of an FS. *os.File implements File.
+type File interface {
+	fs.File
+	io.Writer
+	io.Seeker
+	io.ReaderAt
+
+	// Name returns the name the file was opened with.
+	Name() string
+	Sync() error
+	Truncate(size int64) error
+}
+
+// symlinkFS is implemented by file systems that have symlinks.
+type symlinkFS interface {
+	EvalSymlinks(name string) (string, error)
+}
+
+// OS is the FS of the operating system. Unlike a strict io/fs file
+// system it accepts any path the os package does, including absolute
+// paths and ones containing "..". Use DirFS to confine access to a
+// directory.
+var OS FS = osFS{}
+
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (osFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (osFS) Stat(name string) (fs.FileInfo, error)        { return os.Stat(name) }
+func (osFS) Lstat(name string) (fs.FileInfo, error)       { return os.Lstat(name) }
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error)   { return os.ReadDir(name) }
+func (osFS) ReadFile(name string) ([]byte, error)         { return os.ReadFile(name) }
+func (osFS) Mkdir(name string, perm fs.FileMode) error    { return os.Mkdir(name, perm) }
+func (osFS) MkdirAll(name string, perm fs.FileMode) error { return os.MkdirAll(name, perm) }
+func (osFS) Remove(name string) error                     { return os.Remove(name) }
+func (osFS) RemoveAll(name string) error                  { return os.RemoveAll(name) }
+func (osFS) Rename(oldname, newname string) error         { return os.Rename(oldname, newname) }
+func (osFS) Chmod(name string, mode fs.FileMode) error    { return os.Chmod(name, mode) }
+
+func (osFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+func (osFS) EvalSymlinks(name string) (string, error) { return filepath.EvalSymlinks(name) }
+
+// DirFS returns an FS for the tree of files rooted at dir. Names must
+// be valid io/fs paths (see fs.ValidPath), so they cannot reach
+// outside dir lexically. As with os.DirFS, symlinks are followed.
+func DirFS(dir string) FS { return dirFS(dir) }
+
+type dirFS string
+
+// join returns the OS path of name, or an error if name is not valid.
+func (d dirFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(string(d), filepath.FromSlash(name)), nil
+}
+
+func (d dirFS) Open(name string) (fs.File, error) {
+	p, err := d.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return OS.Open(p)
+}
+
+func (d dirFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	p, err := d.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return OS.OpenFile(p, flag, perm)
+}
+
+func (d dirFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := d.join("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(p)
+}
+
+func (d dirFS) Lstat(name string) (fs.FileInfo, error) {
+	p, err := d.join("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(p)
+}
+
+func (d dirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := d.join("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadDir(p)
+}
+
+func (d dirFS) ReadFile(name string) ([]byte, error) {
+	p, err := d.join("read", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p)
+}
+
+func (d dirFS) Mkdir(name string, perm fs.FileMode) error {
+	p, err := d.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.Mkdir(p, perm)
+}
+
+func (d dirFS) MkdirAll(name string, perm fs.FileMode) error {
+	p, err := d.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(p, perm)
+}
+
+func (d dirFS) Remove(name string) error {
+	p, err := d.join("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+func (d dirFS) RemoveAll(name string) error {
+	p, err := d.join("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(p)
+}
+
+func (d dirFS) Rename(oldname, newname string) error {
+	oldp, err := d.join("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newp, err := d.join("rename", newname)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldp, newp)
+}
+
+func (d dirFS) Chmod(name string, mode fs.FileMode) error {
+	p, err := d.join("chmod", name)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(p, mode)
+}
+
+func (d dirFS) Chtimes(name string, atime, mtime time.Time) error {
+	p, err := d.join("chtimes", name)
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(p, atime, mtime)
+}
+
+func (d dirFS) EvalSymlinks(name string) (string, error) {
+	p, err := d.join("evalsymlinks", name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(p)
+}
+
+// realPath resolves the symlinks in name if fsys has them.
+func realPath(fsys FS, name string) (string, error) {
+	if s, ok := fsys.(symlinkFS); ok {
+		return s.EvalSymlinks(name)
+	}
+	if _, err := fsys.Stat(name); err != nil {
+		return "", err
+	}
+	return filepath.Clean(name), nil
+}
+
+var (
+	fsMu      sync.RWMutex
+	currentFS = OS
+)
+
+// SetFS sets the file system used by Stat, StatCheck, Mode, Create,
+// CreateSafe, ReadFile, WriteFileAtomic, Head, Tail, Walk (unless
+// WalkOptions.FS is set) and gofile/json, and returns the previous one.
+// A nil fsys restores OS. Other functions always use the operating
+// system and ignore WalkOptions.FS.
+func SetFS(fsys FS) FS {
+	if fsys == nil {
+		fsys = OS
+	}
+	fsMu.Lock()
+	defer fsMu.Unlock()
+	prev := currentFS
+	currentFS = fsys
+	return prev
+}
+
+// CurrentFS returns the file system set with SetFS.
+func CurrentFS() FS {
+	fsMu.RLock()
+	defer fsMu.RUnlock()
+	return currentFS
+}
@@ -0,0 +1,315 @@
+package gofile
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// memTree returns a MemFS containing the given files, creating
+// parent directories as needed.
+func memTree(t *testing.T, files map[string]string) *MemFS {
+	t.Helper()
+	m := NewMemFS()
+	for name, data := range files {
+		if err := m.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		f, err := m.OpenFile(name, os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeSyncClose(f, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+// useFS sets fsys as the package file system for the rest of the test.
+func useFS(t *testing.T, fsys FS) {
+	prev := SetFS(fsys)
+	t.Cleanup(func() { SetFS(prev) })
+}
+
+var testFiles = map[string]string{
+	"a.txt":         "alpha",
+	"dir/b.txt":     "bravo",
+	"dir/sub/c.txt": "charlie",
+	"empty":         "",
+}
+
+func TestFSConformance(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range testFiles {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"a.txt", "dir/b.txt", "dir/sub/c.txt", "empty"}
+	for name, fsys := range map[string]FS{"MemFS": memTree(t, testFiles), "DirFS": DirFS(dir)} {
+		t.Run(name, func(t *testing.T) {
+			if err := fstest.TestFS(fsys, want...); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestMemFS(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      func(m *MemFS) error
+		wantErr error
+	}{
+		{"create exclusive", func(m *MemFS) error {
+			_, err := m.OpenFile("a.txt", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+			return err
+		}, ErrExists},
+		{"create without parent", func(m *MemFS) error {
+			_, err := m.OpenFile("missing/x", os.O_RDWR|os.O_CREATE, 0644)
+			return err
+		}, ErrNotExist},
+		{"create below file", func(m *MemFS) error {
+			_, err := m.OpenFile("a.txt/x", os.O_RDWR|os.O_CREATE, 0644)
+			return err
+		}, ErrNotDir},
+		{"write directory", func(m *MemFS) error {
+			_, err := m.OpenFile("dir", os.O_RDWR, 0)
+			return err
+		}, ErrIsDir},
+		{"invalid name", func(m *MemFS) error {
+			_, err := m.Stat("../a.txt")
+			return err
+		}, fs.ErrInvalid},
+		{"mkdir existing", func(m *MemFS) error { return m.Mkdir("dir", 0755) }, ErrExists},
+		{"mkdirall below file", func(m *MemFS) error { return m.MkdirAll("a.txt/x/y", 0755) }, ErrNotDir},
+		{"remove non-empty", func(m *MemFS) error { return m.Remove("dir") }, errors.New("directory not empty")},
+		{"remove missing", func(m *MemFS) error { return m.Remove("missing") }, ErrNotExist},
+		{"rename into itself", func(m *MemFS) error { return m.Rename("dir", "dir/sub/dir") }, errors.New("invalid argument")},
+		{"rename file over dir", func(m *MemFS) error { return m.Rename("a.txt", "dir") }, ErrIsDir},
+		{"read only write", func(m *MemFS) error {
+			f, err := m.Open("a.txt")
+			if err != nil {
+				return err
+			}
+			_, err = f.(io.Writer).Write([]byte("x"))
+			return err
+		}, errors.New("bad file descriptor")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fn(memTree(t, testFiles))
+			if err == nil {
+				t.Fatalf("no error, want %v", tt.wantErr)
+			}
+			if !errors.Is(err, tt.wantErr) && !errors.Is(kindOf(err), tt.wantErr) && !containsErr(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// containsErr reports whether the innermost error of err has the
+// message of want, for errors without a sentinel.
+func containsErr(err, want error) bool {
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return err.Error() == want.Error()
+		}
+		err = next
+	}
+}
+
+func TestMemFSFileOps(t *testing.T) {
+	m := memTree(t, testFiles)
+
+	// rename moves the whole tree
+	if err := m.Rename("dir", "moved"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := m.ReadFile("moved/sub/c.txt"); err != nil || string(data) != "charlie" {
+		t.Errorf("ReadFile after rename = %q, %v", data, err)
+	}
+	if _, err := m.Stat("dir/b.txt"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("old path still exists: %v", err)
+	}
+
+	// append, seek and truncate
+	f, err := m.OpenFile("a.txt", os.O_RDWR|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("-beta")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(7); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(2, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	rest, err := ioutil.ReadAll(f)
+	if err != nil || string(rest) != "pha-b" {
+		t.Errorf("read after seek = %q, %v, want %q", rest, err, "pha-b")
+	}
+	f.Close()
+	if _, err := f.Write([]byte("x")); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("write after close: %v", err)
+	}
+
+	// chmod and chtimes
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := m.Chmod("a.txt", 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Chtimes("a.txt", mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := m.Stat("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != 0600 || !fi.ModTime().Equal(mtime) || fi.Size() != 7 {
+		t.Errorf("Stat() = %v %v %d", fi.Mode(), fi.ModTime(), fi.Size())
+	}
+
+	// RemoveAll of the root empties it
+	if err := m.RemoveAll("."); err != nil {
+		t.Fatal(err)
+	}
+	if des, err := m.ReadDir("."); err != nil || len(des) != 0 {
+		t.Errorf("ReadDir after RemoveAll = %v, %v", des, err)
+	}
+}
+
+func TestOn(t *testing.T) {
+	m := memTree(t, testFiles)
+	if err := m.Chmod("empty", 0200); err != nil {
+		t.Fatal(err)
+	}
+	ops := On(m)
+
+	tests := []struct {
+		name string
+		fn   func() error
+		want error
+	}{
+		{"stat missing", func() error { _, err := ops.Stat("missing"); return err }, ErrNotExist},
+		{"create safe existing", func() error { _, err := ops.CreateSafe("a.txt"); return err }, ErrExists},
+		{"create directory", func() error { _, err := ops.Create("dir"); return err }, ErrIsDir},
+		{"stat check directory", func() error { _, err := ops.StatCheck("dir"); return err }, ErrIsDir},
+		{"stat check unreadable", func() error { _, err := ops.StatCheck("empty"); return err }, ErrPermission},
+		{"read missing", func() error { _, err := ops.ReadFile("missing"); return err }, ErrNotExist},
+		{"write over directory", func() error { return ops.WriteFileAtomic("dir", nil, 0644) }, ErrIsDir},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := ops.StatCheck("a.txt"); err != nil {
+		t.Errorf("StatCheck(a.txt) = %v", err)
+	}
+}
+
+func TestOnWriteFileAtomic(t *testing.T) {
+	m := memTree(t, testFiles)
+	ops := On(m)
+
+	if err := m.Chmod("dir/b.txt", 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.WriteFileAtomic("dir/b.txt", []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.WriteFileAtomic("dir/new.txt", []byte("created"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]struct {
+		data string
+		mode os.FileMode
+	}{
+		"dir/b.txt":   {"new", 0600},
+		"dir/new.txt": {"created", 0640},
+	} {
+		data, err := ops.ReadFile(name)
+		if err != nil || string(data) != want.data {
+			t.Errorf("ReadFile(%s) = %q, %v, want %q", name, data, err, want.data)
+		}
+		if mode, _ := ops.Mode(name); mode != want.mode {
+			t.Errorf("Mode(%s) = %v, want %v", name, mode, want.mode)
+		}
+	}
+
+	des, err := m.ReadDir("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, de := range des {
+		names = append(names, de.Name())
+	}
+	if want := []string{"b.txt", "new.txt", "sub"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("dir contains %v, want %v (temporary file left behind?)", names, want)
+	}
+}
+
+func TestSetFS(t *testing.T) {
+	m := memTree(t, testFiles)
+	useFS(t, m)
+	useHandler(t, IgnoreErrors)
+
+	if fi := Stat("dir/b.txt"); fi == nil || fi.Size() != 5 {
+		t.Errorf("Stat() = %v", fi)
+	}
+	if Stat("missing") != nil {
+		t.Errorf("Stat(missing) != nil")
+	}
+
+	f := CreateSafe("created.txt")
+	if f == nil {
+		t.Fatal("CreateSafe() = nil")
+	}
+	io.WriteString(f, "hello")
+	f.Close()
+	if CreateSafe("created.txt") != nil {
+		t.Errorf("CreateSafe of an existing file succeeded")
+	}
+
+	if err := WriteFileAtomic("dir/b.txt", []byte("replaced"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"created.txt": "hello", "dir/b.txt": "replaced"} {
+		if data, err := ReadFile(name); err != nil || string(data) != want {
+			t.Errorf("ReadFile(%s) = %q, %v, want %q", name, data, err, want)
+		}
+	}
+
+	got := walkRel(t, ".", &WalkOptions{Filters: []Filter{OfType(TypeFile)}})
+	want := []string{"a.txt", "created.txt", "dir/b.txt", "dir/sub/c.txt", "empty"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk() = %v, want %v", got, want)
+	}
+
+	if SetFS(nil) != m || CurrentFS() != OS {
+		t.Errorf("SetFS(nil) did not restore OS")
+	}
+}
@@ -0,0 +1,185 @@
+package gofile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+)
+
+// tmpCounter makes the names of temporary files unique within the process.
+var tmpCounter uint64
+
+// FSOps runs the basic gofile operations against an FS. Like Checked,
+// it returns errors instead of logging them; they are of type
+// *PathError and wrap one of the sentinel errors.
+//
+// On the OS file system the operations behave exactly like their
+// package level counterparts.
+type FSOps struct {
+	fsys FS // nil uses CurrentFS()
+}
+
+// On returns the gofile operations for fsys. A nil fsys uses the file
+// system set with SetFS at the time of each call.
+func On(fsys FS) FSOps { return FSOps{fsys} }
+
+// FS returns the file system the operations run against.
+func (o FSOps) FS() FS {
+	if o.fsys == nil {
+		return CurrentFS()
+	}
+	return o.fsys
+}
+
+func isOS(fsys FS) bool {
+	_, ok := fsys.(osFS)
+	return ok
+}
+
+// Stat returns the os.FileInfo for name.
+func (o FSOps) Stat(name string) (os.FileInfo, error) {
+	fi, err := o.FS().Stat(name)
+	if err != nil {
+		return nil, newPathError("stat", name, err)
+	}
+	return fi, nil
+}
+
+// Mode returns the file mode of name.
+func (o FSOps) Mode(name string) (os.FileMode, error) {
+	fi, err := o.Stat(name)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Mode(), nil
+}
+
+// Create creates or truncates the named file.
+func (o FSOps) Create(name string) (File, error) {
+	f, err := o.FS().OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, newPathError("create", name, err)
+	}
+	return f, nil
+}
+
+// CreateSafe creates the named file. If it already exists, an error
+// wrapping ErrExists is returned.
+func (o FSOps) CreateSafe(name string) (File, error) {
+	fsys := o.FS()
+	if isOS(fsys) {
+		f, err := CreateSafeWith(name, CollisionFail)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+
+	f, err := fsys.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return nil, newPathError("create", name, err)
+	}
+	return f, nil
+}
+
+// StatCheck returns the file information of name if it is a readable
+// regular file, as the package level StatCheck does. On file systems
+// other than OS, readability is judged by the owner permission bit.
+func (o FSOps) StatCheck(name string) (os.FileInfo, error) {
+	fsys := o.FS()
+	if isOS(fsys) {
+		return statCheckOS(name)
+	}
+
+	fi, err := fsys.Stat(name)
+	if err != nil {
+		return nil, newPathError("stat", name, err)
+	}
+	if fi.Mode().Perm()&0400 == 0 {
+		return nil, fmt.Errorf("insufficient permissions: %s %v: %w", name, fi.Mode(), ErrPermission)
+	}
+	if fi.IsDir() {
+		return nil, fmt.Errorf("the filename %s refers to a directory: %w", name, ErrIsDir)
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("the filename %s is not a regular file: %w", name, ErrNotRegular)
+	}
+	return fi, nil
+}
+
+// ReadFile reads the named file and returns its contents.
+func (o FSOps) ReadFile(name string) ([]byte, error) {
+	fsys := o.FS()
+	if isOS(fsys) {
+		return readFileOS(name)
+	}
+
+	data, err := fsys.ReadFile(name)
+	if err != nil {
+		return nil, newPathError("read", name, err)
+	}
+	return data, nil
+}
+
+// WriteFileAtomic writes data to name through a temporary file that is
+// renamed over name, as the package level WriteFileAtomic does. If name
+// already exists its mode is preserved, otherwise it is created with perm.
+func (o FSOps) WriteFileAtomic(name string, data []byte, perm os.FileMode) error {
+	fsys := o.FS()
+	if isOS(fsys) {
+		return writeFileAtomicOS(name, data, perm)
+	}
+
+	keep := false
+	if fi, err := fsys.Stat(name); err == nil {
+		if fi.IsDir() {
+			return newPathError("create", name, ErrIsDir)
+		}
+		perm, keep = fi.Mode().Perm(), true
+	}
+
+	dir, base := filepath.Split(name)
+	tmp := filepath.Join(dir, "."+base+".tmp-"+strconv.FormatUint(atomic.AddUint64(&tmpCounter, 1), 10))
+
+	f, err := fsys.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return newPathError("create", name, err)
+	}
+
+	err = writeSyncClose(f, data)
+	if err == nil && keep {
+		err = fsys.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = fsys.Rename(tmp, name)
+	}
+	if err != nil {
+		fsys.Remove(tmp)
+		return newPathError("create", name, err)
+	}
+	return nil
+}
+
+func writeSyncClose(f File, data []byte) error {
+	_, err := f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Walk walks the tree at root as the package level Walk does. It
+// overrides opts.FS with the operations' file system.
+func (o FSOps) Walk(root string, opts *WalkOptions, fn WalkFunc) error {
+	var wo WalkOptions
+	if opts != nil {
+		wo = *opts
+	}
+	wo.FS = o.FS()
+	return Walk(root, &wo, fn)
+}
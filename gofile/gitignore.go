@@ -0,0 +1,152 @@
+package gofile
+
+import (
+	"bufio"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRules holds the patterns of one .gitignore file. Rules of
+// nested directories are chained to their parent through parent.
+type ignoreRules struct {
+	parent *ignoreRules
+	base   string // slash separated directory of the .gitignore, relative to the walk root
+	rules  []ignoreRule
+}
+
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// loadIgnore reads the .gitignore file in dir, if any, and returns the
+// rules in effect for dir. If there is no .gitignore, parent is returned.
+func loadIgnore(fsys FS, parent *ignoreRules, dir, rel string) *ignoreRules {
+	f, err := fsys.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return parent
+	}
+	defer f.Close()
+
+	r := &ignoreRules{parent: parent, base: rel}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+			r.rules = append(r.rules, rule)
+		}
+	}
+
+	if len(r.rules) == 0 {
+		return parent
+	}
+	return r
+}
+
+// match reports whether the slash separated path rel (relative to the
+// walk root) is ignored. As in git, rules in deeper .gitignore files
+// take precedence, and the last matching rule in a file wins.
+func (r *ignoreRules) match(rel string, isDir bool) bool {
+	for ; r != nil; r = r.parent {
+		p := rel
+		if r.base != "." {
+			p = strings.TrimPrefix(rel, r.base+"/")
+		}
+
+		for i := len(r.rules) - 1; i >= 0; i-- {
+			rule := r.rules[i]
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.re.MatchString(p) {
+				return !rule.negate
+			}
+		}
+	}
+	return false
+}
+
+// parseIgnoreRule converts one line of a .gitignore file into a rule.
+// Blank lines and comments return false.
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	var rule ignoreRule
+
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || line[0] == '#' {
+		return rule, false
+	}
+
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+
+	// a pattern with a slash (other than a trailing one) is relative
+	// to the .gitignore; otherwise it matches at any depth
+	prefix := "(.*/)?"
+	if strings.Contains(line, "/") {
+		prefix = ""
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	re, err := regexp.Compile("^" + prefix + globToRegexp(line) + "$")
+	if err != nil {
+		return rule, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// globToRegexp translates a gitignore glob to a regular expression.
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			sb.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			j := strings.IndexByte(glob[i:], ']')
+			if j < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += j
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return sb.String()
+}
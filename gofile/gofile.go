@@ -3,6 +3,7 @@ package gofile
 
 import (
 	"os"
+	"path/filepath"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -22,3 +23,14 @@ func PWD() string {
 	}
 	return dir
 }
+
+// Normalize returns path with environment variables and a leading ~
+// expanded, made absolute relative to the current directory and
+// cleaned. Symlinks are not resolved.
+func Normalize(path string) (string, error) {
+	p, err := ExpandPath(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(p)
+}
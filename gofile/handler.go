@@ -0,0 +1,129 @@
+package gofile
+
+import (
+	"errors"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrorHandler decides what happens to the errors reported through Err,
+// such as the errors of the original gofile functions that return a
+// zero value instead of an error. Handle is only called with non-nil
+// errors and returns the error the caller should see.
+type ErrorHandler interface {
+	Handle(err error) error
+}
+
+// ErrorHandlerFunc adapts an ordinary function to an ErrorHandler.
+type ErrorHandlerFunc func(err error) error
+
+// Handle returns f(err).
+func (f ErrorHandlerFunc) Handle(err error) error { return f(err) }
+
+var (
+	// LogErrors logs errors with the standard logrus logger and passes
+	// them through unchanged. It is the default handler.
+	LogErrors ErrorHandler = NewLogHandler(log.StandardLogger())
+
+	// IgnoreErrors passes errors through without reporting them.
+	IgnoreErrors ErrorHandler = ErrorHandlerFunc(func(err error) error { return err })
+
+	// PanicOnError panics with the error. It is meant for tests, where
+	// an error that would otherwise only be logged should fail loudly.
+	PanicOnError ErrorHandler = ErrorHandlerFunc(func(err error) error { panic(err) })
+)
+
+// NewLogHandler returns an ErrorHandler that logs errors with l and
+// passes them through unchanged. The operation, path and command of
+// *PathError and *CmdError errors are logged as fields.
+func NewLogHandler(l log.FieldLogger) ErrorHandler {
+	return ErrorHandlerFunc(func(err error) error {
+		l.WithFields(ErrorFields(err)).Error(err)
+		return err
+	})
+}
+
+// ErrorFields returns the context recorded in err as log fields:
+// "op", "path" and "cmd", where present.
+func ErrorFields(err error) log.Fields {
+	fields := log.Fields{}
+
+	var pe *PathError
+	if errors.As(err, &pe) {
+		fields["op"] = pe.Op
+		fields["path"] = pe.Path
+	}
+
+	var ce *CmdError
+	if errors.As(err, &ce) {
+		if _, ok := fields["op"]; !ok {
+			fields["op"] = ce.Op
+		}
+		fields["cmd"] = ce.Cmd
+	}
+	return fields
+}
+
+// ErrorCollector is an ErrorHandler that records errors instead of
+// reporting them. It is safe for concurrent use.
+type ErrorCollector struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+// Handle records err and returns it unchanged.
+func (c *ErrorCollector) Handle(err error) error {
+	c.mu.Lock()
+	c.errs = append(c.errs, err)
+	c.mu.Unlock()
+	return err
+}
+
+// Errors returns the errors recorded so far, oldest first.
+func (c *ErrorCollector) Errors() []error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]error(nil), c.errs...)
+}
+
+// Reset discards the recorded errors.
+func (c *ErrorCollector) Reset() {
+	c.mu.Lock()
+	c.errs = nil
+	c.mu.Unlock()
+}
+
+var (
+	handlerMu sync.RWMutex
+	handler   = LogErrors
+)
+
+// SetErrorHandler sets the handler used by Err and returns the previous
+// one. A nil h restores LogErrors.
+func SetErrorHandler(h ErrorHandler) ErrorHandler {
+	if h == nil {
+		h = LogErrors
+	}
+	handlerMu.Lock()
+	defer handlerMu.Unlock()
+	prev := handler
+	handler = h
+	return prev
+}
+
+// CurrentErrorHandler returns the handler used by Err.
+func CurrentErrorHandler() ErrorHandler {
+	handlerMu.RLock()
+	defer handlerMu.RUnlock()
+	return handler
+}
+
+// Err passes non-nil errors to the package error handler
+// (see SetErrorHandler) and returns its result.
+func Err(err error) error {
+	if err == nil {
+		return nil
+	}
+	return CurrentErrorHandler().Handle(err)
+}
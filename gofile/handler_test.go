@@ -0,0 +1,127 @@
+package gofile
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// useHandler sets h as the package handler for the rest of the test.
+func useHandler(t *testing.T, h ErrorHandler) {
+	prev := SetErrorHandler(h)
+	t.Cleanup(func() { SetErrorHandler(prev) })
+}
+
+func TestErrCollector(t *testing.T) {
+	c := &ErrorCollector{}
+	useHandler(t, c)
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	if fi := Stat(missing); fi != nil {
+		t.Fatalf("Stat(missing) = %v, want nil", fi)
+	}
+	if m := Mode(missing); m != 0 {
+		t.Fatalf("Mode(missing) = %v, want 0", m)
+	}
+	if err := Err(nil); err != nil {
+		t.Fatalf("Err(nil) = %v", err)
+	}
+
+	errs := c.Errors()
+	if len(errs) != 2 {
+		t.Fatalf("collected %d errors, want 2: %v", len(errs), errs)
+	}
+	for _, err := range errs {
+		if !errors.Is(err, ErrNotExist) {
+			t.Errorf("error = %v, want ErrNotExist", err)
+		}
+	}
+
+	c.Reset()
+	if errs := c.Errors(); len(errs) != 0 {
+		t.Errorf("after Reset: %v", errs)
+	}
+}
+
+func TestWithHandler(t *testing.T) {
+	global := &ErrorCollector{}
+	useHandler(t, global)
+
+	local := &ErrorCollector{}
+	ops := WithHandler(Checked, local)
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	if _, err := ops.Stat(missing); !errors.Is(err, ErrNotExist) {
+		t.Fatalf("Stat error = %v, want ErrNotExist", err)
+	}
+
+	if n := len(local.Errors()); n != 1 {
+		t.Errorf("call handler got %d errors, want 1", n)
+	}
+	if n := len(global.Errors()); n != 0 {
+		t.Errorf("package handler got %d errors, want 0", n)
+	}
+}
+
+func TestPanicOnError(t *testing.T) {
+	useHandler(t, PanicOnError)
+
+	defer func() {
+		r := recover()
+		if err, ok := r.(error); !ok || !errors.Is(err, ErrNotExist) {
+			t.Errorf("recovered %v, want an ErrNotExist error", r)
+		}
+	}()
+	Stat(filepath.Join(t.TempDir(), "missing"))
+	t.Error("Stat did not panic")
+}
+
+func TestSetErrorHandlerNil(t *testing.T) {
+	useHandler(t, IgnoreErrors)
+
+	SetErrorHandler(nil)
+	if h := CurrentErrorHandler(); reflect.ValueOf(h).Pointer() != reflect.ValueOf(LogErrors).Pointer() {
+		t.Errorf("SetErrorHandler(nil) did not restore LogErrors")
+	}
+}
+
+func TestErrorFields(t *testing.T) {
+	base := errors.New("boom")
+
+	tests := []struct {
+		name string
+		err  error
+		want log.Fields
+	}{
+		{"plain", base, log.Fields{}},
+		{"path", &PathError{Op: "stat", Path: "/x", Err: base}, log.Fields{"op": "stat", "path": "/x"}},
+		{"cmd", NewCmdError("add", "git add --all", base), log.Fields{"op": "add", "cmd": "git add --all"}},
+		{"path in cmd", NewCmdError("init", "git init", &PathError{Op: "open", Path: "/y", Err: base}),
+			log.Fields{"op": "open", "path": "/y", "cmd": "git init"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorFields(tt.err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ErrorFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCmdError(t *testing.T) {
+	if err := NewCmdError("add", "git add", nil); err != nil {
+		t.Errorf("NewCmdError(nil) = %v, want nil", err)
+	}
+
+	base := errors.New("exit status 1")
+	err := NewCmdError("add", "git add --all", base)
+	if !errors.Is(err, base) {
+		t.Errorf("%v does not wrap %v", err, base)
+	}
+	if want := "add `git add --all`: exit status 1"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
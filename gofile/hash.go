@@ -0,0 +1,336 @@
+package gofile
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// HashAlgorithm names a checksum algorithm supported by Hash.
+type HashAlgorithm string
+
+const (
+	MD5    HashAlgorithm = "md5"
+	SHA1   HashAlgorithm = "sha1"
+	SHA256 HashAlgorithm = "sha256"
+	SHA512 HashAlgorithm = "sha512"
+	CRC32  HashAlgorithm = "crc32"
+)
+
+// maxBufSize caps the buffers sized with InitialCapacity
+// when streaming large files.
+const maxBufSize = 256 * chunk
+
+// New returns a new hash.Hash for the algorithm.
+func (a HashAlgorithm) New() (hash.Hash, error) {
+	switch a {
+	case MD5:
+		return md5.New(), nil
+	case SHA1:
+		return sha1.New(), nil
+	case SHA256:
+		return sha256.New(), nil
+	case SHA512:
+		return sha512.New(), nil
+	case CRC32:
+		return crc32.NewIEEE(), nil
+	}
+	return nil, fmt.Errorf("unknown hash algorithm: %q", string(a))
+}
+
+// Hash returns the hex encoded checksum of the named file.
+// The file is streamed through a buffer sized with InitialCapacity.
+func Hash(path string, algo HashAlgorithm) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", newPathError("hash", path, err)
+	}
+	defer f.Close()
+
+	var size int64
+	if fi, err := f.Stat(); err == nil {
+		size = fi.Size()
+	}
+
+	sum, err := hashReader(f, algo, size)
+	if err != nil {
+		return "", newPathError("hash", path, err)
+	}
+	return sum, nil
+}
+
+// HashReader returns the hex encoded checksum of everything read from r.
+func HashReader(r io.Reader, algo HashAlgorithm) (string, error) {
+	return hashReader(r, algo, 0)
+}
+
+func hashReader(r io.Reader, algo HashAlgorithm, size int64) (string, error) {
+	h, err := algo.New()
+	if err != nil {
+		return "", err
+	}
+
+	n := InitialCapacity(size)
+	if n > maxBufSize {
+		n = maxBufSize
+	}
+
+	if _, err := io.CopyBuffer(h, r, make([]byte, n)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ManifestEntry is one line of a checksum manifest.
+type ManifestEntry struct {
+	Sum  string
+	Path string // slash separated, relative to the manifest root
+}
+
+// Manifest hashes every regular file below root and returns the
+// entries sorted by path. Files are found with Walk using opts, which
+// may be nil, and hashed concurrently by opts.Workers goroutines.
+//
+// Files that cannot be hashed, such as unreadable ones or ones removed
+// during the walk, are passed to opts.OnError like errors reading a
+// directory. If it returns nil the file is left out of the manifest;
+// if OnError is nil, the first such error is returned.
+func Manifest(root string, algo HashAlgorithm, opts *WalkOptions) ([]ManifestEntry, error) {
+	var onError func(path string, err error) error
+	if opts != nil {
+		onError = opts.OnError
+	}
+	entries, err := hashFiles(root, algo, opts, onError)
+	if err != nil {
+		return nil, err
+	}
+
+	n := 0
+	for _, e := range entries {
+		if e.Sum != "" {
+			entries[n] = e
+			n++
+		}
+	}
+	return entries[:n], nil
+}
+
+// hashFiles is Manifest, but passes hash errors to onError instead of
+// opts.OnError, and keeps the entries of the files skipped by it with
+// an empty Sum. A nil onError returns the first error.
+func hashFiles(root string, algo HashAlgorithm, opts *WalkOptions, onError func(path string, err error) error) ([]ManifestEntry, error) {
+	if _, err := algo.New(); err != nil {
+		return nil, err
+	}
+
+	files, err := manifestFiles(root, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ManifestEntry, len(files))
+	err = parallel(len(files), workerCount(opts), func(i int) error {
+		name := filepath.Join(root, filepath.FromSlash(files[i]))
+		sum, err := Hash(name, algo)
+		entries[i] = ManifestEntry{Sum: sum, Path: files[i]}
+		if err != nil && onError != nil {
+			return onError(name, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// parallel calls fn for every index in [0, n) using up to workers
+// goroutines. It returns the first error, after all calls have finished.
+func parallel(n, workers int, fn func(i int) error) error {
+	jobs := make(chan int)
+	errc := make(chan error, 1)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := fn(i); err != nil {
+					select {
+					case errc <- err:
+					default:
+					}
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	select {
+	case err := <-errc:
+		return err
+	default:
+		return nil
+	}
+}
+
+// manifestFiles returns the sorted, slash separated paths of the
+// regular files below root.
+func manifestFiles(root string, opts *WalkOptions) ([]string, error) {
+	var o WalkOptions
+	if opts != nil {
+		o = *opts
+	}
+	o.Filters = append([]Filter{OfType(TypeFile)}, o.Filters...)
+	o.FS = OS
+
+	var files []string
+	err := Walk(root, &o, func(e Entry) error {
+		files = append(files, e.Rel)
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// WriteManifest writes entries to w in the format used by sha256sum
+// and friends: the checksum, two spaces and the path. Paths containing
+// a backslash or newline are escaped and the line starts with a
+// backslash, as in GNU coreutils.
+func WriteManifest(w io.Writer, entries []ManifestEntry) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		name := e.Path
+		prefix := ""
+		if strings.ContainsAny(name, "\\\n") {
+			prefix = "\\"
+			name = strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(name)
+		}
+		if _, err := fmt.Fprintf(bw, "%s%s  %s\n", prefix, e.Sum, name); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadManifest parses a manifest in sha256sum format, as written by
+// WriteManifest. Binary mode markers ("sum *path") are accepted.
+func ReadManifest(r io.Reader) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		escaped := strings.HasPrefix(line, "\\")
+		if escaped {
+			line = line[1:]
+		}
+
+		i := strings.IndexByte(line, ' ')
+		if i < 1 || i+2 > len(line) || (line[i+1] != ' ' && line[i+1] != '*') {
+			return nil, fmt.Errorf("manifest line %d: invalid format", n)
+		}
+
+		name := line[i+2:]
+		if escaped {
+			name = unescapeManifestPath(name)
+		}
+		entries = append(entries, ManifestEntry{Sum: strings.ToLower(line[:i]), Path: name})
+	}
+	return entries, scanner.Err()
+}
+
+func unescapeManifestPath(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' {
+				sb.WriteByte('\n')
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// VerifyReport lists the differences between a manifest and the files
+// on disk. All paths are slash separated and relative to the root.
+type VerifyReport struct {
+	OK      []string // present with the expected checksum
+	Changed []string // present with a different checksum
+	Missing []string // in the manifest but not on disk
+	Extra   []string // on disk but not in the manifest
+}
+
+// Clean reports whether the files on disk match the manifest exactly.
+func (r *VerifyReport) Clean() bool {
+	return len(r.Changed) == 0 && len(r.Missing) == 0 && len(r.Extra) == 0
+}
+
+// Verify compares the files below root with manifest. Files are hashed
+// concurrently as in Manifest; opts may be nil. Files that cannot be
+// hashed are passed to opts.OnError; if it is nil or returns nil, they
+// are reported as missing, or as extra if they are not in manifest.
+func Verify(root string, algo HashAlgorithm, manifest []ManifestEntry, opts *WalkOptions) (*VerifyReport, error) {
+	onError := func(path string, err error) error { return nil }
+	if opts != nil && opts.OnError != nil {
+		onError = opts.OnError
+	}
+	current, err := hashFiles(root, algo, opts, onError)
+	if err != nil {
+		return nil, err
+	}
+
+	want := make(map[string]string, len(manifest))
+	for _, e := range manifest {
+		want[e.Path] = e.Sum
+	}
+
+	r := &VerifyReport{}
+	for _, e := range current {
+		sum, ok := want[e.Path]
+		switch {
+		case !ok:
+			r.Extra = append(r.Extra, e.Path)
+		case e.Sum == "":
+			// left in want, so it is reported missing
+			continue
+		case sum == e.Sum:
+			r.OK = append(r.OK, e.Path)
+		default:
+			r.Changed = append(r.Changed, e.Path)
+		}
+		delete(want, e.Path)
+	}
+
+	for p := range want {
+		r.Missing = append(r.Missing, p)
+	}
+	sort.Strings(r.Missing)
+
+	return r, nil
+}
@@ -0,0 +1,173 @@
+package gofile
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "hello")
+	if err := ioutil.WriteFile(name, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		algo HashAlgorithm
+		want string
+	}{
+		{MD5, "5d41402abc4b2a76b9719d911017c592"},
+		{SHA1, "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"},
+		{SHA256, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{SHA512, "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043"},
+		{CRC32, "3610a686"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.algo), func(t *testing.T) {
+			got, err := Hash(name, tt.algo)
+			if err != nil {
+				t.Fatalf("Hash() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Hash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Hash(name, "sha3"); err == nil {
+		t.Errorf("Hash() with unknown algorithm did not fail")
+	}
+}
+
+func TestManifestVerify(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{"a": "a", "sub/b": "b", "sub/c": "c"})
+
+	entries, err := Manifest(dir, SHA256, &WalkOptions{Workers: 2})
+	if err != nil {
+		t.Fatalf("Manifest() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteManifest(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  a\n") {
+		t.Errorf("WriteManifest() = %q", buf.String())
+	}
+
+	read, err := ReadManifest(&buf)
+	if err != nil {
+		t.Fatalf("ReadManifest() error = %v", err)
+	}
+	if !reflect.DeepEqual(read, entries) {
+		t.Errorf("ReadManifest() = %v, want %v", read, entries)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "a"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "sub", "b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "new"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Verify(dir, SHA256, read, nil)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	want := &VerifyReport{
+		OK:      []string{"sub/c"},
+		Changed: []string{"a"},
+		Missing: []string{"sub/b"},
+		Extra:   []string{"new"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("Verify() = %+v, want %+v", report, want)
+	}
+}
+
+func TestManifestVanished(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{"a": "a", "sub/b": "b", "sub/c": "c"})
+	entries, err := Manifest(dir, SHA256, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// files removed after the walk found them, before they are hashed
+	vanish := func(names ...string) *WalkOptions {
+		return &WalkOptions{Filters: []Filter{func(e Entry) bool {
+			for _, name := range names {
+				if e.Rel == name {
+					os.Remove(e.Path)
+				}
+			}
+			return true
+		}}}
+	}
+
+	makeTree(t, dir, map[string]string{"sub/b": "b"})
+	if _, err := Manifest(dir, SHA256, vanish("sub/b")); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Manifest() error = %v, want %v", err, ErrNotExist)
+	}
+
+	makeTree(t, dir, map[string]string{"sub/b": "b"})
+	var failed []string
+	opts := vanish("sub/b")
+	opts.OnError = func(path string, err error) error {
+		failed = append(failed, path)
+		return nil
+	}
+	got, err := Manifest(dir, SHA256, opts)
+	if err != nil {
+		t.Fatalf("Manifest() error = %v", err)
+	}
+	if want := []ManifestEntry{entries[0], entries[2]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Manifest() = %v, want %v", got, want)
+	}
+	if want := []string{filepath.Join(dir, "sub", "b")}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("OnError() paths = %v, want %v", failed, want)
+	}
+
+	makeTree(t, dir, map[string]string{"sub/b": "b", "new": ""})
+	report, err := Verify(dir, SHA256, entries, vanish("sub/b", "new"))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	want := &VerifyReport{
+		OK:      []string{"a", "sub/c"},
+		Missing: []string{"sub/b"},
+		Extra:   []string{"new"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("Verify() = %+v, want %+v", report, want)
+	}
+}
+
+func TestManifestEscaping(t *testing.T) {
+	entries := []ManifestEntry{{Sum: "00", Path: "back\\slash\nnewline"}}
+
+	var buf bytes.Buffer
+	if err := WriteManifest(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\\00  back\\\\slash\\nnewline\n"; buf.String() != want {
+		t.Errorf("WriteManifest() = %q, want %q", buf.String(), want)
+	}
+
+	read, err := ReadManifest(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, entries) {
+		t.Errorf("ReadManifest() = %q, want %q", read, entries)
+	}
+}
@@ -3,7 +3,6 @@ package json
 
 import (
 	"encoding/json"
-	"io/ioutil"
 	"os"
 
 	"github.com/skeptycal/util/gofile"
@@ -18,7 +17,7 @@ func Load(filename string) (JSON, error) {
 		return nil, nil
     }
 
-	j := &jsonStruct{fi, &jsonMap{}}
+	j := &jsonStruct{fi, filename, &jsonMap{}}
 	err := j.ReadFile()
 	if err != nil {
 		return nil, err
@@ -26,18 +25,15 @@ func Load(filename string) (JSON, error) {
 	return j, err
 }
 
+// New returns an empty JSON structure for filename, which must not
+// exist yet. The file is written by Save.
 func New(filename string) (JSON, error) {
     fi := gofile.Stat(filename)
 	if fi != nil {
 		return nil, os.ErrExist
     }
 
-	j := &jsonStruct{fi, &jsonMap{}}
-	err := j.ReadFile()
-	if err != nil {
-		return nil, err
-	}
-	return j, err
+	return &jsonStruct{nil, filename, &jsonMap{}}, nil
 }
 
 // JSON describes a JSON file and data structure object.
@@ -51,9 +47,24 @@ type JSON interface {
 }
 
 // jsonStruct implements a JSON mapping with os.FileInfo included.
+// Files are read and written through gofile, so they use the file
+// system set with gofile.SetFS.
 type jsonStruct struct {
 	os.FileInfo
-	v *jsonMap
+	name string
+	v    *jsonMap
+}
+
+// Name returns the path of the underlying file.
+func (j *jsonStruct) Name() string { return j.name }
+
+// Size returns the size of the file when it was loaded,
+// or 0 for a new file.
+func (j *jsonStruct) Size() int64 {
+	if j.FileInfo == nil {
+		return 0
+	}
+	return j.FileInfo.Size()
 }
 
 // Load loads JSON data from the underlying file
@@ -61,7 +72,7 @@ type jsonStruct struct {
 // note: variable/field names should begin with an
 // uppercase letter or they will not load correctly
 func (j *jsonStruct) ReadFile() error {
-	data, err := ioutil.ReadFile(j.Name())
+	data, err := gofile.ReadFile(j.Name())
 	if err != nil {
 		return err
 	}
@@ -77,7 +88,7 @@ func (j *jsonStruct) Save() error {
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(j.Name(), data, 0644)
+	return gofile.WriteFileAtomic(j.Name(), data, 0644)
 }
 
 // Unmarshaler is the interface implemented by types
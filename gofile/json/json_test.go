@@ -0,0 +1,51 @@
+package json
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/skeptycal/util/gofile"
+)
+
+func TestNewSaveLoad(t *testing.T) {
+	m := gofile.NewMemFS()
+	prev := gofile.SetFS(m)
+	t.Cleanup(func() { gofile.SetFS(prev) })
+	prevH := gofile.SetErrorHandler(gofile.IgnoreErrors)
+	t.Cleanup(func() { gofile.SetErrorHandler(prevH) })
+
+	j, err := New("config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.UnmarshalJSON([]byte(`{"Name":"util","Count":3}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New("config.json"); !errors.Is(err, os.ErrExist) {
+		t.Errorf("New() of an existing file error = %v, want %v", err, os.ErrExist)
+	}
+
+	loaded, err := Load("config.json")
+	if err != nil || loaded == nil {
+		t.Fatalf("Load() = %v, %v", loaded, err)
+	}
+	got, err := loaded.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Count":3,"Name":"util"}`; string(got) != want {
+		t.Errorf("loaded %s, want %s", got, want)
+	}
+	if loaded.Name() != "config.json" || loaded.Size() != int64(len(got)) {
+		t.Errorf("Name() = %q, Size() = %d", loaded.Name(), loaded.Size())
+	}
+
+	if missing, err := Load("missing.json"); missing != nil || err != nil {
+		t.Errorf("Load(missing) = %v, %v, want nil, nil", missing, err)
+	}
+}
@@ -0,0 +1,400 @@
+package gofile
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"time"
+)
+
+// defaultPoll is how often Follow checks the file when
+// FollowOptions.Poll is 0.
+const defaultPoll = 250 * time.Millisecond
+
+// errStop ends a line scan early without reporting an error.
+var errStop = errors.New("stop")
+
+// trimEOL removes a trailing "\n" or "\r\n" from line.
+func trimEOL(line []byte) []byte {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+		if n > 1 && line[n-2] == '\r' {
+			line = line[:n-2]
+		}
+	}
+	return line
+}
+
+// ScanLines calls fn for each line read from r, without the line
+// ending. Unlike bufio.Scanner it has no limit on the length of a line.
+// A final line without a newline is reported too. If fn returns an
+// error, scanning stops and ScanLines returns it.
+func ScanLines(r io.Reader, fn func(line string) error) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if ferr := fn(string(trimEOL(line))); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Head returns the first n lines of the named file.
+func Head(name string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	f, err := CurrentFS().Open(name)
+	if err != nil {
+		return nil, newPathError("head", name, err)
+	}
+	defer f.Close()
+
+	lines := make([]string, 0, n)
+	err = ScanLines(f, func(line string) error {
+		lines = append(lines, line)
+		if len(lines) == n {
+			return errStop
+		}
+		return nil
+	})
+	if err != nil && err != errStop {
+		return nil, newPathError("head", name, err)
+	}
+	return lines, nil
+}
+
+// Tail returns the last n lines of the named file. The file is read
+// backwards from the end in chunks, so only the tail is read no matter
+// how large the file is.
+func Tail(name string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	f, err := CurrentFS().OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, newPathError("tail", name, err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, newPathError("tail", name, err)
+	}
+	off, err := tailOffset(f, fi.Size(), n)
+	if err != nil {
+		return nil, newPathError("tail", name, err)
+	}
+
+	lines := make([]string, 0, n)
+	err = ScanLines(io.NewSectionReader(f, off, fi.Size()-off), func(line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		return nil, newPathError("tail", name, err)
+	}
+	return lines, nil
+}
+
+// tailOffset returns the offset at which the last n lines of the first
+// size bytes of r begin. A newline ending the data does not start an
+// empty line, as with tail(1).
+func tailOffset(r io.ReaderAt, size int64, n int) (int64, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	end := size
+	if end > 0 {
+		var last [1]byte
+		if _, err := r.ReadAt(last[:], end-1); err != nil {
+			return 0, err
+		}
+		if last[0] == '\n' {
+			end--
+		}
+	}
+
+	for end > 0 {
+		start := end - int64(len(*buf))
+		if start < 0 {
+			start = 0
+		}
+		b := (*buf)[:end-start]
+		if _, err := r.ReadAt(b, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+		for i := len(b) - 1; i >= 0; i-- {
+			if b[i] != '\n' {
+				continue
+			}
+			if n--; n == 0 {
+				return start + int64(i) + 1, nil
+			}
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+// FollowOptions configures Follow.
+type FollowOptions struct {
+	// Lines is the number of existing lines reported before following
+	// the file, like tail -n. A negative value reports the whole file.
+	Lines int
+
+	// Poll is how often the file is checked for new data, truncation
+	// and rotation.
+	Poll time.Duration
+}
+
+// Follow reports the lines appended to the named file until ctx is
+// done or fn returns an error, like tail -F. It returns fn's error or
+// the context's error.
+//
+// The file is followed by name: if it is truncated, reading restarts
+// at its beginning, and if it is replaced (for example by a log
+// rotation) the rest of the old file is read before switching to the
+// new one. If the file does not exist, Follow waits for it to appear.
+// A final line without a newline is held back until it is completed,
+// or reported when the file is truncated or replaced.
+//
+// Follow always uses the operating system, not the file system set
+// with SetFS. opts may be nil.
+func Follow(ctx context.Context, name string, opts *FollowOptions, fn func(line string) error) error {
+	var o FollowOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Poll <= 0 {
+		o.Poll = defaultPoll
+	}
+
+	t := &follower{name: name, fn: fn}
+	defer t.close()
+
+	if err := t.open(o.Lines); err != nil {
+		return err
+	}
+	for {
+		if err := t.poll(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(o.Poll):
+		}
+	}
+}
+
+// follower holds the state of Follow.
+type follower struct {
+	name    string
+	fn      func(string) error
+	f       *os.File
+	fi      os.FileInfo // of f
+	r       *bufio.Reader
+	off     int64  // bytes of f consumed by r
+	partial []byte // unterminated last line
+}
+
+// open opens the file and positions it so that the last lines lines
+// are read next. A missing file is not an error; poll opens it when it
+// appears.
+func (t *follower) open(lines int) error {
+	f, err := os.Open(t.name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return newPathError("follow", t.name, err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return newPathError("follow", t.name, err)
+	}
+
+	var off int64
+	switch {
+	case lines == 0:
+		off = fi.Size()
+	case lines > 0:
+		if off, err = tailOffset(f, fi.Size(), lines); err != nil {
+			f.Close()
+			return newPathError("follow", t.name, err)
+		}
+	}
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		f.Close()
+		return newPathError("follow", t.name, err)
+	}
+
+	t.f, t.fi, t.off = f, fi, off
+	t.r = bufio.NewReader(f)
+	return nil
+}
+
+// poll reads any new lines and handles truncation and replacement.
+func (t *follower) poll() error {
+	if t.f == nil {
+		if err := t.open(-1); err != nil || t.f == nil {
+			return err
+		}
+	}
+	if err := t.drain(); err != nil {
+		return err
+	}
+
+	if fi, err := t.f.Stat(); err == nil && fi.Size() < t.off {
+		if err := t.flush(); err != nil {
+			return err
+		}
+		if _, err := t.f.Seek(0, io.SeekStart); err != nil {
+			return newPathError("follow", t.name, err)
+		}
+		t.r.Reset(t.f)
+		t.off = 0
+		return t.drain()
+	}
+
+	fi, err := os.Stat(t.name)
+	if err != nil || os.SameFile(fi, t.fi) {
+		// gone files are read until a new one appears
+		return nil
+	}
+	if err := t.drain(); err != nil {
+		return err
+	}
+	if err := t.flush(); err != nil {
+		return err
+	}
+	t.close()
+	if err := t.open(-1); err != nil {
+		return err
+	}
+	return t.drain()
+}
+
+// drain reports the complete lines available in the file.
+func (t *follower) drain() error {
+	for {
+		line, err := t.r.ReadSlice('\n')
+		t.off += int64(len(line))
+		t.partial = append(t.partial, line...)
+		switch err {
+		case nil:
+			if err := t.flush(); err != nil {
+				return err
+			}
+		case bufio.ErrBufferFull:
+		case io.EOF:
+			return nil
+		default:
+			return newPathError("follow", t.name, err)
+		}
+	}
+}
+
+// flush reports the buffered line, if any.
+func (t *follower) flush() error {
+	if len(t.partial) == 0 {
+		return nil
+	}
+	line := string(trimEOL(t.partial))
+	t.partial = t.partial[:0]
+	return t.fn(line)
+}
+
+func (t *follower) close() {
+	if t.f != nil {
+		t.f.Close()
+		t.f = nil
+	}
+}
+
+// GrepOptions configures Grep.
+type GrepOptions struct {
+	// Before and After are the number of context lines reported
+	// before and after each selected line, like grep -B and -A.
+	Before int
+	After  int
+
+	// Invert selects the lines that do not match, like grep -v.
+	Invert bool
+}
+
+// Line is a line reported by Grep.
+type Line struct {
+	Num     int    // 1-based line number
+	Text    string // without the line ending
+	Context bool   // a context line rather than a selected one
+}
+
+// Grep calls fn for each line of r that matches re, along with the
+// context lines requested in opts. Each line is reported at most once
+// and in order, so overlapping context is merged; a gap in Num marks
+// the start of a new group. opts may be nil.
+func Grep(r io.Reader, re *regexp.Regexp, opts *GrepOptions, fn func(Line) error) error {
+	return ScanLines(r, GrepFunc(re, opts, fn))
+}
+
+// GrepFunc returns a line function that filters the lines passed to it
+// as Grep does, numbering them from 1. It can be passed to ScanLines or
+// Follow to filter a growing file:
+//
+//	err := Follow(ctx, "app.log", nil, GrepFunc(re, nil, print))
+func GrepFunc(re *regexp.Regexp, opts *GrepOptions, fn func(Line) error) func(line string) error {
+	var o GrepOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	var (
+		num    int
+		before []Line // unreported lines, at most o.Before
+		after  int    // context lines still to report
+	)
+	return func(text string) error {
+		num++
+		line := Line{Num: num, Text: text}
+
+		if re.MatchString(text) != o.Invert {
+			for _, b := range before {
+				if err := fn(b); err != nil {
+					return err
+				}
+			}
+			before = before[:0]
+			after = o.After
+			return fn(line)
+		}
+
+		line.Context = true
+		if after > 0 {
+			after--
+			return fn(line)
+		}
+		if o.Before > 0 {
+			if len(before) == o.Before {
+				copy(before, before[1:])
+				before = before[:len(before)-1]
+			}
+			before = append(before, line)
+		}
+		return nil
+	}
+}
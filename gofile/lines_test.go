@@ -0,0 +1,236 @@
+package gofile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeadTail(t *testing.T) {
+	useFS(t, memTree(t, map[string]string{
+		"lf":       "one\ntwo\nthree\nfour\n",
+		"no-eol":   "one\ntwo\nthree",
+		"crlf":     "one\r\ntwo\r\nthree\r\n",
+		"blank":    "one\n\n\n",
+		"empty":    "",
+		"one-line": "only",
+	}))
+
+	tests := []struct {
+		name     string
+		n        int
+		wantHead []string
+		wantTail []string
+	}{
+		{"lf", 2, []string{"one", "two"}, []string{"three", "four"}},
+		{"lf", 10, []string{"one", "two", "three", "four"}, []string{"one", "two", "three", "four"}},
+		{"lf", 0, nil, nil},
+		{"no-eol", 1, []string{"one"}, []string{"three"}},
+		{"crlf", 2, []string{"one", "two"}, []string{"two", "three"}},
+		{"blank", 2, []string{"one", ""}, []string{"", ""}},
+		{"empty", 3, []string{}, []string{}},
+		{"one-line", 3, []string{"only"}, []string{"only"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.name, tt.n), func(t *testing.T) {
+			head, err := Head(tt.name, tt.n)
+			if err != nil || !reflect.DeepEqual(head, tt.wantHead) {
+				t.Errorf("Head() = %q, %v, want %q", head, err, tt.wantHead)
+			}
+			tail, err := Tail(tt.name, tt.n)
+			if err != nil || !reflect.DeepEqual(tail, tt.wantTail) {
+				t.Errorf("Tail() = %q, %v, want %q", tail, err, tt.wantTail)
+			}
+		})
+	}
+
+	if _, err := Tail("missing", 1); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Tail(missing) error = %v, want %v", err, ErrNotExist)
+	}
+}
+
+func TestTailLargeFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "large.log")
+	var b strings.Builder
+	for i := 1; i <= 20000; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	if err := ioutil.WriteFile(name, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{1, 5000, 19999, 20000, 30000} {
+		got, err := Tail(name, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := n
+		if want > 20000 {
+			want = 20000
+		}
+		if len(got) != want || got[len(got)-1] != "line 20000" || got[0] != fmt.Sprintf("line %d", 20001-want) {
+			t.Errorf("Tail(%d) returned %d lines from %q to %q", n, len(got), got[0], got[len(got)-1])
+		}
+	}
+}
+
+func TestGrep(t *testing.T) {
+	const text = "a\nb\nERROR 1\nc\nd\ne\nf\nERROR 2\nERROR 3\ng\n"
+	re := regexp.MustCompile(`^ERROR`)
+
+	tests := []struct {
+		name string
+		opts *GrepOptions
+		want []string
+	}{
+		{"matches only", nil, []string{"3:ERROR 1", "8:ERROR 2", "9:ERROR 3"}},
+		{"before", &GrepOptions{Before: 1}, []string{"2-b", "3:ERROR 1", "7-f", "8:ERROR 2", "9:ERROR 3"}},
+		{"after", &GrepOptions{After: 2}, []string{"3:ERROR 1", "4-c", "5-d", "8:ERROR 2", "9:ERROR 3", "10-g"}},
+		{"overlapping context", &GrepOptions{Before: 3, After: 3}, []string{
+			"1-a", "2-b", "3:ERROR 1", "4-c", "5-d", "6-e", "7-f", "8:ERROR 2", "9:ERROR 3", "10-g",
+		}},
+		{"invert", &GrepOptions{Invert: true}, []string{"1:a", "2:b", "4:c", "5:d", "6:e", "7:f", "10:g"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := Grep(strings.NewReader(text), re, tt.opts, func(l Line) error {
+				sep := ":"
+				if l.Context {
+					sep = "-"
+				}
+				got = append(got, fmt.Sprint(l.Num, sep, l.Text))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Grep() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	stop := errors.New("stop here")
+	err := Grep(strings.NewReader(text), re, nil, func(Line) error { return stop })
+	if err != stop {
+		t.Errorf("Grep() error = %v, want %v", err, stop)
+	}
+}
+
+// follow runs Follow on name in the background and returns the
+// channel its lines are sent on.
+func follow(t *testing.T, name string, opts *FollowOptions) <-chan string {
+	ctx, cancel := context.WithCancel(context.Background())
+	lines := make(chan string, 100)
+	done := make(chan error, 1)
+	go func() {
+		done <- Follow(ctx, name, opts, func(line string) error {
+			lines <- line
+			return nil
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Follow() error = %v, want %v", err, context.Canceled)
+		}
+	})
+	return lines
+}
+
+// expectLines fails unless the next lines received are want.
+func expectLines(t *testing.T, lines <-chan string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got := <-lines:
+			if got != w {
+				t.Fatalf("got line %q, want %q", got, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for line %q", w)
+		}
+	}
+}
+
+func appendFile(t *testing.T, name, data string) {
+	t.Helper()
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, name, "old 1\nold 2\nold 3\n")
+
+	lines := follow(t, name, &FollowOptions{Lines: 2, Poll: 5 * time.Millisecond})
+	expectLines(t, lines, "old 2", "old 3")
+
+	// appended lines, with a line written in two parts
+	appendFile(t, name, "new 1\nnew")
+	expectLines(t, lines, "new 1")
+	appendFile(t, name, " 2\n")
+	expectLines(t, lines, "new 2")
+
+	// truncated in place
+	if err := os.Truncate(name, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	appendFile(t, name, "after truncate\n")
+	expectLines(t, lines, "after truncate")
+
+	// rotated: renamed away and replaced
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, name+".1", "late write\n")
+	time.Sleep(20 * time.Millisecond)
+	appendFile(t, name, "rotated\n")
+	expectLines(t, lines, "late write", "rotated")
+}
+
+func TestFollowMissingFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "later.log")
+
+	lines := follow(t, name, &FollowOptions{Poll: 5 * time.Millisecond})
+	time.Sleep(20 * time.Millisecond)
+	appendFile(t, name, "first\nsecond\n")
+	expectLines(t, lines, "first", "second")
+}
+
+func TestFollowGrep(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, name, "info start\nerror boom\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var got []Line
+	err := Follow(ctx, name, &FollowOptions{Lines: -1, Poll: 5 * time.Millisecond},
+		GrepFunc(regexp.MustCompile("error"), &GrepOptions{Before: 1}, func(l Line) error {
+			got = append(got, l)
+			return errStop
+		}))
+	if err != errStop {
+		t.Fatalf("Follow() error = %v, want the error returned by fn", err)
+	}
+	if want := []Line{{1, "info start", true}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
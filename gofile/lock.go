@@ -0,0 +1,262 @@
+package gofile
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Polling intervals used while waiting for a lock with a context.
+const (
+	minLockPoll = time.Millisecond
+	maxLockPoll = 100 * time.Millisecond
+)
+
+// maxPIDLockAttempts bounds the retries when a PID lock file is
+// replaced by another process while LockPID is acquiring it.
+const maxPIDLockAttempts = 10
+
+// FileLock is an advisory lock on a file, held through an open file
+// descriptor and released with Unlock or when the process exits.
+//
+// Advisory locks only coordinate processes that lock the same file.
+// A file that is replaced by rename, as WriteFileAtomic does, must be
+// protected by locking a separate file such as name + ".lock".
+//
+// A FileLock must not be unlocked from several goroutines at once.
+type FileLock struct {
+	f      *os.File
+	path   string
+	shared bool
+	remove bool // remove the file on Unlock (PID lock files)
+}
+
+// LockOptions configures LockContext.
+type LockOptions struct {
+	// Shared takes a read lock that other shared locks can hold at
+	// the same time, instead of an exclusive one.
+	Shared bool
+
+	// Timeout gives up waiting for the lock after this long with an
+	// error wrapping context.DeadlineExceeded. 0 waits forever.
+	Timeout time.Duration
+}
+
+// Lock waits for an exclusive lock on path, creating the file if needed.
+func Lock(path string) (*FileLock, error) {
+	return LockContext(context.Background(), path, nil)
+}
+
+// RLock waits for a shared lock on path, creating the file if needed.
+func RLock(path string) (*FileLock, error) {
+	return LockContext(context.Background(), path, &LockOptions{Shared: true})
+}
+
+// TryLock takes an exclusive lock on path without waiting. If the lock
+// is held elsewhere, the error wraps ErrLocked.
+func TryLock(path string) (*FileLock, error) {
+	f, err := openLockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(f, false, false); err != nil {
+		f.Close()
+		return nil, newPathError("lock", path, err)
+	}
+	return &FileLock{f: f, path: path}, nil
+}
+
+// LockContext waits for a lock on path until it is acquired, ctx is
+// done or opts.Timeout expires. opts may be nil.
+func LockContext(ctx context.Context, path string, opts *LockOptions) (*FileLock, error) {
+	var o LockOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+
+	f, err := openLockFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// without a deadline or cancellation, let the kernel do the waiting
+	if ctx.Done() == nil {
+		if err := flock(f, o.Shared, true); err != nil {
+			f.Close()
+			return nil, newPathError("lock", path, err)
+		}
+		return &FileLock{f: f, path: path, shared: o.Shared}, nil
+	}
+
+	poll := minLockPoll
+	for {
+		err := flock(f, o.Shared, false)
+		if err == nil {
+			return &FileLock{f: f, path: path, shared: o.Shared}, nil
+		}
+		if kindOf(err) != ErrLocked {
+			f.Close()
+			return nil, newPathError("lock", path, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, newPathError("lock", path, ctx.Err())
+		case <-time.After(poll):
+		}
+		if poll *= 2; poll > maxLockPoll {
+			poll = maxLockPoll
+		}
+	}
+}
+
+// openLockFile opens path for locking, creating it if needed. Files
+// that cannot be opened for writing are locked read only.
+func openLockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if os.IsPermission(err) {
+		f, err = os.Open(path)
+	}
+	if err != nil {
+		return nil, newPathError("lock", path, err)
+	}
+	return f, nil
+}
+
+// Path returns the path of the locked file.
+func (l *FileLock) Path() string { return l.path }
+
+// Shared reports whether l is a shared lock.
+func (l *FileLock) Shared() bool { return l.shared }
+
+// Unlock releases the lock. For PID locks, the lock file is removed
+// first. Calling Unlock more than once is a no-op.
+func (l *FileLock) Unlock() error {
+	if l.f == nil {
+		return nil
+	}
+	f := l.f
+	l.f = nil
+
+	var err error
+	if l.remove {
+		// remove while still locked, so a waiting LockPID sees the
+		// file disappear and starts over with a new one
+		if rerr := os.Remove(l.path); rerr != nil && !os.IsNotExist(rerr) {
+			err = newPathError("unlock", l.path, rerr)
+		}
+	}
+	if uerr := funlock(f); uerr != nil && err == nil {
+		err = newPathError("unlock", l.path, uerr)
+	}
+	if cerr := f.Close(); cerr != nil && err == nil {
+		err = newPathError("unlock", l.path, cerr)
+	}
+	return err
+}
+
+// LockPID takes an exclusive lock on the lock file path and writes the
+// PID of the current process to it. The file is removed by Unlock.
+//
+// If another process holds the lock, the error wraps ErrLocked and
+// names its PID. A lock file left behind by a process that is no longer
+// running is stale and is taken over; so is one whose owner did not
+// hold a flock, as long as the PID it contains is not alive.
+func LockPID(path string) (*FileLock, error) {
+	for i := 0; i < maxPIDLockAttempts; i++ {
+		f, err := openLockFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := flock(f, false, false); err != nil {
+			f.Close()
+			if kindOf(err) == ErrLocked {
+				if pid, perr := ReadPID(path); perr == nil {
+					err = fmt.Errorf("%w by pid %d", ErrLocked, pid)
+				}
+			}
+			return nil, newPathError("lock", path, err)
+		}
+
+		// the previous owner may have removed the file between our
+		// open and flock; lock the file now at path instead
+		if !samePathFile(f, path) {
+			funlock(f)
+			f.Close()
+			continue
+		}
+
+		pid, _ := readPID(f)
+		if pid > 0 && pid != os.Getpid() && processAlive(pid) {
+			funlock(f)
+			f.Close()
+			return nil, newPathError("lock", path, fmt.Errorf("%w by pid %d", ErrLocked, pid))
+		}
+
+		if err := writePID(f); err != nil {
+			funlock(f)
+			f.Close()
+			return nil, newPathError("lock", path, err)
+		}
+		return &FileLock{f: f, path: path, remove: true}, nil
+	}
+	return nil, newPathError("lock", path, ErrLocked)
+}
+
+// ReadPID returns the PID stored in the lock file path.
+func ReadPID(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, newPathError("read", path, err)
+	}
+	defer f.Close()
+
+	pid, err := readPID(f)
+	if err != nil {
+		return 0, newPathError("read", path, err)
+	}
+	return pid, nil
+}
+
+// readPID parses the PID at the start of f without moving its offset.
+func readPID(f *os.File) (int, error) {
+	data, err := ReadAll(io.NewSectionReader(f, 0, 64))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(bytes.TrimSpace(data)))
+}
+
+func writePID(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// samePathFile reports whether f is still the file at path.
+func samePathFile(f *os.File, path string) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	pi, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fi, pi)
+}
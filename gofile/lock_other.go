@@ -0,0 +1,21 @@
+//go:build !linux && !darwin && !freebsd && !openbsd && !netbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!openbsd,!netbsd,!dragonfly
+
+package gofile
+
+import (
+	"errors"
+	"os"
+)
+
+var errLockUnsupported = errors.New("gofile: file locking is not supported on this platform")
+
+// flock is not available on this platform.
+func flock(f *os.File, shared, block bool) error { return errLockUnsupported }
+
+// funlock is not available on this platform.
+func funlock(f *os.File) error { return errLockUnsupported }
+
+// processAlive cannot tell on this platform, so every process is
+// assumed to be running and no lock is considered stale.
+func processAlive(pid int) bool { return true }
@@ -0,0 +1,214 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+// +build linux darwin freebsd openbsd netbsd dragonfly
+
+package gofile
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustLock(t *testing.T, l *FileLock, err error) *FileLock {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Unlock() })
+	return l
+}
+
+func TestTryLock(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "lock")
+
+	tests := []struct {
+		name    string
+		lock    func(string) (*FileLock, error)
+		wantErr error
+	}{
+		{"exclusive held", Lock, ErrLocked},
+		{"shared held", RLock, ErrLocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := tt.lock(name)
+			l = mustLock(t, l, err)
+
+			if _, err := TryLock(name); !errors.Is(err, tt.wantErr) {
+				t.Errorf("TryLock() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := l.Unlock(); err != nil {
+				t.Fatal(err)
+			}
+
+			l2, err := TryLock(name)
+			mustLock(t, l2, err)
+		})
+	}
+}
+
+func TestRLockShared(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "lock")
+
+	l1, err := RLock(name)
+	l1 = mustLock(t, l1, err)
+	l2, err := RLock(name)
+	l2 = mustLock(t, l2, err)
+
+	if !l1.Shared() || !l2.Shared() {
+		t.Errorf("RLock returned an exclusive lock")
+	}
+}
+
+func TestLockWaits(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "lock")
+
+	held, err := Lock(name)
+	mustLock(t, held, err)
+
+	got := make(chan error, 1)
+	go func() {
+		l, err := Lock(name)
+		if err == nil {
+			l.Unlock()
+		}
+		got <- err
+	}()
+
+	select {
+	case err := <-got:
+		t.Fatalf("Lock() returned %v while the lock was held", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	held.Unlock()
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Lock() did not return after Unlock")
+	}
+}
+
+func TestLockContext(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "lock")
+
+	held, err := Lock(name)
+	mustLock(t, held, err)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		opts    *LockOptions
+		wantErr error
+	}{
+		{"timeout", context.Background(), &LockOptions{Timeout: 20 * time.Millisecond}, context.DeadlineExceeded},
+		{"shared timeout", context.Background(), &LockOptions{Shared: true, Timeout: 20 * time.Millisecond}, context.DeadlineExceeded},
+		{"canceled", canceled, nil, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LockContext(tt.ctx, name, tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("LockContext() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// released while waiting
+	released := make(chan struct{})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		held.Unlock()
+		close(released)
+	}()
+	l, err := LockContext(context.Background(), name, &LockOptions{Timeout: 5 * time.Second})
+	mustLock(t, l, err)
+	<-released
+}
+
+func TestUnlockTwice(t *testing.T) {
+	l, err := Lock(filepath.Join(t.TempDir(), "lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Errorf("second Unlock() = %v", err)
+	}
+}
+
+// deadPID returns the PID of a process that has exited.
+func deadPID(t *testing.T) int {
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip("cannot run true:", err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestLockPID(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("acquire and release", func(t *testing.T) {
+		name := filepath.Join(dir, "acquire.pid")
+		l, err := LockPID(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pid, err := ReadPID(name); err != nil || pid != os.Getpid() {
+			t.Errorf("ReadPID() = %d, %v, want %d", pid, err, os.Getpid())
+		}
+
+		_, err = LockPID(name)
+		if !errors.Is(err, ErrLocked) || !strings.Contains(err.Error(), strconv.Itoa(os.Getpid())) {
+			t.Errorf("second LockPID() error = %v, want ErrLocked naming pid %d", err, os.Getpid())
+		}
+
+		if err := l.Unlock(); err != nil {
+			t.Fatal(err)
+		}
+		if exists(name) {
+			t.Errorf("lock file not removed by Unlock")
+		}
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		name := filepath.Join(dir, "stale.pid")
+		if err := ioutil.WriteFile(name, []byte(strconv.Itoa(deadPID(t))+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		l, err := LockPID(name)
+		mustLock(t, l, err)
+		if pid, _ := ReadPID(name); pid != os.Getpid() {
+			t.Errorf("stale lock not taken over: pid = %d", pid)
+		}
+	})
+
+	t.Run("alive without flock", func(t *testing.T) {
+		name := filepath.Join(dir, "alive.pid")
+		if err := ioutil.WriteFile(name, []byte(strconv.Itoa(os.Getppid())+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LockPID(name); !errors.Is(err, ErrLocked) {
+			t.Errorf("LockPID() error = %v, want ErrLocked", err)
+		}
+		if !exists(name) {
+			t.Errorf("lock file of a live process was removed")
+		}
+	})
+}
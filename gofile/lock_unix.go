@@ -0,0 +1,46 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+// +build linux darwin freebsd openbsd netbsd dragonfly
+
+package gofile
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// flock takes a shared or exclusive flock on f. Without block, a lock
+// held elsewhere fails with ErrLocked instead of waiting.
+func flock(f *os.File, shared, block bool) error {
+	how := unix.LOCK_EX
+	if shared {
+		how = unix.LOCK_SH
+	}
+	if !block {
+		how |= unix.LOCK_NB
+	}
+
+	for {
+		err := unix.Flock(int(f.Fd()), how)
+		switch err {
+		case nil:
+			return nil
+		case unix.EINTR:
+			continue
+		case unix.EWOULDBLOCK:
+			return ErrLocked
+		}
+		return err
+	}
+}
+
+// funlock releases the flock on f.
+func funlock(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
+
+// processAlive reports whether a process with the given pid exists.
+func processAlive(pid int) bool {
+	err := unix.Kill(pid, 0)
+	return err == nil || err == unix.EPERM
+}
@@ -0,0 +1,578 @@
+package gofile
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// MemFS is an FS that keeps files and directories in memory. It is
+// meant for tests: permission bits are recorded but not enforced, and
+// there are no symlinks.
+//
+// Names are io/fs paths (see fs.ValidPath), such as "." for the root
+// or "dir/file", except that OS separators are accepted as well, so
+// names built with filepath.Join work. A MemFS is safe for concurrent
+// use.
+type MemFS struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode // by clean slash path; the root is "."
+}
+
+type memNode struct {
+	mode    fs.FileMode
+	modTime time.Time
+	data    []byte
+}
+
+// NewMemFS returns a MemFS containing only an empty root directory.
+func NewMemFS() *MemFS {
+	return &MemFS{nodes: map[string]*memNode{
+		".": {mode: fs.ModeDir | 0755, modTime: time.Now()},
+	}}
+}
+
+// memPath returns the key of name in the node map.
+func memPath(op, name string) (string, error) {
+	p := filepath.ToSlash(name)
+	if !fs.ValidPath(p) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return p, nil
+}
+
+// parentDir returns an error unless the parent of p is a directory.
+// m.mu must be held.
+func (m *MemFS) parentDir(op, name, p string) error {
+	parent := m.nodes[path.Dir(p)]
+	if parent == nil {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !parent.mode.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	}
+	return nil
+}
+
+// Open opens name for reading.
+func (m *MemFS) Open(name string) (fs.File, error) {
+	f, err := m.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// OpenFile opens name with the os.O_* flags, creating it with perm
+// if needed.
+func (m *MemFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	p, err := memPath("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := m.nodes[p]
+	writable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+
+	switch {
+	case n == nil:
+		if flag&os.O_CREATE == 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		if err := m.parentDir("open", name, p); err != nil {
+			return nil, err
+		}
+		n = &memNode{mode: perm & fs.ModePerm, modTime: time.Now()}
+		m.nodes[p] = n
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case n.mode.IsDir() && writable:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	case flag&os.O_TRUNC != 0 && writable:
+		n.data = nil
+		n.modTime = time.Now()
+	}
+
+	return &memFile{fs: m, name: name, path: p, node: n, flag: flag}, nil
+}
+
+// Stat returns a FileInfo describing name.
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := memPath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n := m.nodes[p]
+	if n == nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return n.info(p), nil
+}
+
+// Lstat is the same as Stat, as a MemFS has no symlinks.
+func (m *MemFS) Lstat(name string) (fs.FileInfo, error) { return m.Stat(name) }
+
+// ReadDir returns the entries of the directory name sorted by name.
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := memPath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.readDir("readdir", name, p)
+}
+
+// readDir lists the directory p. m.mu must be held.
+func (m *MemFS) readDir(op, name, p string) ([]fs.DirEntry, error) {
+	n := m.nodes[p]
+	if n == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !n.mode.IsDir() {
+		return nil, &fs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	}
+
+	var entries []fs.DirEntry
+	for q, c := range m.nodes {
+		if q != "." && path.Dir(q) == p {
+			entries = append(entries, memDirEntry{c.info(q)})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// ReadFile returns a copy of the contents of name.
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	p, err := memPath("read", name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n := m.nodes[p]
+	if n == nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	if n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: syscall.EISDIR}
+	}
+	return append([]byte{}, n.data...), nil
+}
+
+// Mkdir creates the directory name. Its parent must exist.
+func (m *MemFS) Mkdir(name string, perm fs.FileMode) error {
+	p, err := memPath("mkdir", name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.nodes[p] != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if err := m.parentDir("mkdir", name, p); err != nil {
+		return err
+	}
+	m.nodes[p] = &memNode{mode: fs.ModeDir | perm&fs.ModePerm, modTime: time.Now()}
+	return nil
+}
+
+// MkdirAll creates the directory name and any missing parents.
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	p, err := memPath("mkdir", name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var missing []string
+	for q := p; ; q = path.Dir(q) {
+		if n := m.nodes[q]; n != nil {
+			if !n.mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+			}
+			break
+		}
+		missing = append(missing, q)
+	}
+	for _, q := range missing {
+		m.nodes[q] = &memNode{mode: fs.ModeDir | perm&fs.ModePerm, modTime: time.Now()}
+	}
+	return nil
+}
+
+// Remove removes the file or empty directory name.
+func (m *MemFS) Remove(name string) error {
+	p, err := memPath("remove", name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := m.nodes[p]
+	if n == nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if n.mode.IsDir() {
+		if p == "." || m.hasChildren(p) {
+			return &fs.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+		}
+	}
+	delete(m.nodes, p)
+	return nil
+}
+
+// RemoveAll removes name and everything below it. It is not an error
+// if name does not exist. Removing the root removes its contents.
+func (m *MemFS) RemoveAll(name string) error {
+	p, err := memPath("remove", name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for q := range m.nodes {
+		if q != "." && memWithin(p, q) {
+			delete(m.nodes, q)
+		}
+	}
+	return nil
+}
+
+// Rename moves oldname, and everything below it, to newname. An
+// existing file at newname is replaced, as is an empty directory if
+// oldname is a directory.
+func (m *MemFS) Rename(oldname, newname string) error {
+	oldp, err := memPath("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newp, err := memPath("rename", newname)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := m.nodes[oldp]
+	if n == nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrNotExist}
+	}
+	if oldp == newp {
+		return nil
+	}
+	if oldp == "." || memWithin(oldp, newp) {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.EINVAL}
+	}
+	if err := m.parentDir("rename", newname, newp); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err.(*fs.PathError).Err}
+	}
+
+	if dst := m.nodes[newp]; dst != nil {
+		var err error
+		switch {
+		case dst.mode.IsDir() && !n.mode.IsDir():
+			err = syscall.EISDIR
+		case !dst.mode.IsDir() && n.mode.IsDir():
+			err = syscall.ENOTDIR
+		case dst.mode.IsDir() && m.hasChildren(newp):
+			err = syscall.ENOTEMPTY
+		}
+		if err != nil {
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+		}
+	}
+
+	moved := make(map[string]*memNode)
+	for q, c := range m.nodes {
+		if memWithin(oldp, q) {
+			moved[newp+strings.TrimPrefix(q, oldp)] = c
+			delete(m.nodes, q)
+		}
+	}
+	for q, c := range moved {
+		m.nodes[q] = c
+	}
+	return nil
+}
+
+// Chmod changes the permission bits of name.
+func (m *MemFS) Chmod(name string, mode fs.FileMode) error {
+	return m.update("chmod", name, func(n *memNode) {
+		n.mode = n.mode&fs.ModeType | mode&fs.ModePerm
+	})
+}
+
+// Chtimes changes the modification time of name. A MemFS does not
+// record access times.
+func (m *MemFS) Chtimes(name string, atime, mtime time.Time) error {
+	return m.update("chtimes", name, func(n *memNode) { n.modTime = mtime })
+}
+
+func (m *MemFS) update(op, name string, fn func(n *memNode)) error {
+	p, err := memPath(op, name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := m.nodes[p]
+	if n == nil {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	fn(n)
+	return nil
+}
+
+// hasChildren reports whether the directory p has entries. m.mu must be held.
+func (m *MemFS) hasChildren(p string) bool {
+	for q := range m.nodes {
+		if q != "." && path.Dir(q) == p {
+			return true
+		}
+	}
+	return false
+}
+
+// memWithin reports whether q is p or below it.
+func memWithin(p, q string) bool {
+	return p == "." || q == p || strings.HasPrefix(q, p+"/")
+}
+
+func (n *memNode) info(p string) memInfo {
+	return memInfo{name: path.Base(p), size: int64(len(n.data)), mode: n.mode, modTime: n.modTime}
+}
+
+// memInfo is a snapshot of a memNode.
+type memInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() fs.FileMode  { return i.mode }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memInfo) Sys() interface{}   { return nil }
+
+type memDirEntry struct{ info memInfo }
+
+func (e memDirEntry) Name() string               { return e.info.name }
+func (e memDirEntry) IsDir() bool                { return e.info.IsDir() }
+func (e memDirEntry) Type() fs.FileMode          { return e.info.mode.Type() }
+func (e memDirEntry) Info() (fs.FileInfo, error) { return e.info, nil }
+
+// memFile is an open MemFS file. Like *os.File, its offset is not
+// safe for concurrent use.
+type memFile struct {
+	fs     *MemFS
+	name   string
+	path   string
+	node   *memNode
+	flag   int
+	off    int64
+	closed bool
+
+	dirents []fs.DirEntry // remaining entries for ReadDir
+	dirRead bool
+}
+
+func (f *memFile) Name() string { return f.name }
+
+func (f *memFile) check(op string, write bool) error {
+	if f.closed {
+		return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	}
+	readOnly := f.flag&(os.O_WRONLY|os.O_RDWR) == 0
+	if write && readOnly || !write && f.flag&os.O_WRONLY != 0 {
+		return &fs.PathError{Op: op, Path: f.name, Err: syscall.EBADF}
+	}
+	if f.node.mode.IsDir() && op != "readdir" && op != "stat" && op != "close" {
+		return &fs.PathError{Op: op, Path: f.name, Err: syscall.EISDIR}
+	}
+	return nil
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	if f.closed {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	return f.node.info(f.path), nil
+}
+
+func (f *memFile) Read(b []byte) (int, error) {
+	n, err := f.ReadAt(b, f.off)
+	f.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *memFile) ReadAt(b []byte, off int64) (int, error) {
+	if err := f.check("read", false); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrInvalid}
+	}
+
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.node.data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Write(b []byte) (int, error) {
+	if err := f.check("write", true); err != nil {
+		return 0, err
+	}
+
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.flag&os.O_APPEND != 0 {
+		f.off = int64(len(f.node.data))
+	}
+	end := f.off + int64(len(b))
+	if end > int64(len(f.node.data)) {
+		data := make([]byte, end)
+		copy(data, f.node.data)
+		f.node.data = data
+	}
+	copy(f.node.data[f.off:], b)
+	f.off = end
+	f.node.modTime = time.Now()
+	return len(b), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrClosed}
+	}
+
+	f.fs.mu.RLock()
+	size := int64(len(f.node.data))
+	f.fs.mu.RUnlock()
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += size
+	default:
+		offset = -1
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	if err := f.check("truncate", true); err != nil {
+		return err
+	}
+	if size < 0 {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrInvalid}
+	}
+
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	data := make([]byte, size)
+	copy(data, f.node.data)
+	f.node.data = data
+	f.node.modTime = time.Now()
+	return nil
+}
+
+func (f *memFile) Sync() error {
+	if f.closed {
+		return &fs.PathError{Op: "sync", Path: f.name, Err: fs.ErrClosed}
+	}
+	return nil
+}
+
+func (f *memFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
+
+// ReadDir implements fs.ReadDirFile for directories.
+func (f *memFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	if f.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrClosed}
+	}
+	if !f.dirRead {
+		f.fs.mu.RLock()
+		entries, err := f.fs.readDir("readdir", f.name, f.path)
+		f.fs.mu.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+		f.dirents, f.dirRead = entries, true
+	}
+
+	if count <= 0 {
+		entries := f.dirents
+		f.dirents = nil
+		return entries, nil
+	}
+	if len(f.dirents) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(f.dirents) {
+		count = len(f.dirents)
+	}
+	entries := f.dirents[:count]
+	f.dirents = f.dirents[count:]
+	return entries, nil
+}
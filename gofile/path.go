@@ -0,0 +1,176 @@
+package gofile
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinks bounds the number of symlinks followed while resolving
+// a path, as the kernel does with ELOOP.
+const maxSymlinks = 255
+
+// ExpandHome replaces a leading ~ with the home directory of the current
+// user and a leading ~name with that of user name. Other paths are
+// returned unchanged.
+func ExpandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+
+	name, rest := path[1:], ""
+	if i := strings.IndexAny(name, `/`+string(filepath.Separator)); i >= 0 {
+		name, rest = name[:i], name[i:]
+	}
+
+	var home string
+	if name == "" {
+		h, err := os.UserHomeDir()
+		if err != nil {
+			return "", newPathError("expand", path, err)
+		}
+		home = h
+	} else {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return "", newPathError("expand", path, err)
+		}
+		home = u.HomeDir
+	}
+	return home + rest, nil
+}
+
+// ExpandEnv replaces $VAR and ${VAR} in path with the values of the
+// environment variables. Unlike os.ExpandEnv, references to unset
+// variables are left in place, so "$UNSET/bin" does not become "/bin".
+func ExpandEnv(path string) string {
+	return os.Expand(path, func(key string) string {
+		if v, ok := os.LookupEnv(key); ok {
+			return v
+		}
+		return "${" + key + "}"
+	})
+}
+
+// ExpandPath expands environment variables and a leading ~ in path, in
+// that order, and cleans the result.
+func ExpandPath(path string) (string, error) {
+	p, err := ExpandHome(ExpandEnv(path))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Clean(p), nil
+}
+
+// XDG base directories. Each returns the directory named by its
+// environment variable if that is set to an absolute path, as the XDG
+// Base Directory Specification requires, or the default below the
+// home directory otherwise.
+
+// ConfigHome returns $XDG_CONFIG_HOME or ~/.config.
+func ConfigHome() (string, error) { return xdgDir("XDG_CONFIG_HOME", ".config") }
+
+// CacheHome returns $XDG_CACHE_HOME or ~/.cache.
+func CacheHome() (string, error) { return xdgDir("XDG_CACHE_HOME", ".cache") }
+
+// DataHome returns $XDG_DATA_HOME or ~/.local/share.
+func DataHome() (string, error) { return xdgDir("XDG_DATA_HOME", ".local/share") }
+
+// StateHome returns $XDG_STATE_HOME or ~/.local/state.
+func StateHome() (string, error) { return xdgDir("XDG_STATE_HOME", ".local/state") }
+
+func xdgDir(env, def string) (string, error) {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return filepath.Clean(dir), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, filepath.FromSlash(def)), nil
+}
+
+// SafeJoin joins elem to root like filepath.Join, but returns an error
+// wrapping ErrOutsideRoot if the result would not be below root, either
+// lexically through ".." or once symlinks in the existing part of the
+// path are followed. Use it for paths taken from archives, downloads or
+// user input.
+//
+// The check is made when SafeJoin is called; it cannot protect against
+// symlinks created afterwards.
+func SafeJoin(root string, elem ...string) (string, error) {
+	root = filepath.Clean(root)
+	joined := filepath.Join(append([]string{root}, elem...)...)
+
+	if !within(root, joined) {
+		return "", newPathError("join", joined, ErrOutsideRoot)
+	}
+
+	realRoot, err := evalSymlinks(root)
+	if err != nil {
+		return "", newPathError("join", root, err)
+	}
+	real, err := evalSymlinks(joined)
+	if err != nil {
+		return "", newPathError("join", joined, err)
+	}
+	if !within(realRoot, real) {
+		return "", newPathError("join", joined, ErrOutsideRoot)
+	}
+	return joined, nil
+}
+
+// within reports whether the clean path p is root or below it.
+func within(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// evalSymlinks is filepath.EvalSymlinks for paths that need not exist.
+// The longest existing prefix is resolved and the rest appended, and a
+// dangling symlink is resolved to where it points.
+func evalSymlinks(path string) (string, error) {
+	path = filepath.Clean(path)
+
+	for n := 0; n < maxSymlinks; n++ {
+		existing, rest := path, ""
+		for {
+			if _, err := os.Lstat(existing); err == nil {
+				break
+			}
+			parent := filepath.Dir(existing)
+			if parent == existing {
+				break
+			}
+			rest = filepath.Join(filepath.Base(existing), rest)
+			existing = parent
+		}
+
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !errors.Is(err, ErrNotExist) {
+			// EvalSymlinks reports loops with a plain error; Stat
+			// returns ELOOP
+			if _, serr := os.Stat(existing); serr != nil {
+				return "", serr
+			}
+			return "", err
+		}
+
+		// Lstat succeeded, so only the last element of existing
+		// can be a dangling symlink
+		target, err := os.Readlink(existing)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(existing), target)
+		}
+		path = filepath.Join(target, rest)
+	}
+	return "", ErrSymlinkLoop
+}
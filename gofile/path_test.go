@@ -0,0 +1,183 @@
+package gofile
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+)
+
+// setenv sets key to value for the rest of the test. An empty value
+// unsets key.
+func setenv(t *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	if value == "" {
+		os.Unsetenv(key)
+	} else {
+		os.Setenv(key, value)
+	}
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestExpandPath(t *testing.T) {
+	home := t.TempDir()
+	setenv(t, "HOME", home)
+	setenv(t, "USERPROFILE", home)
+	setenv(t, "GOFILE_TEST_DIR", "/opt/data")
+	setenv(t, "GOFILE_TEST_UNSET", "")
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"~", home},
+		{"~/", home},
+		{"~/docs/a.txt", filepath.Join(home, "docs", "a.txt")},
+		{"$GOFILE_TEST_DIR/x", filepath.FromSlash("/opt/data/x")},
+		{"${GOFILE_TEST_DIR}/x", filepath.FromSlash("/opt/data/x")},
+		{"$GOFILE_TEST_UNSET/bin", filepath.FromSlash("${GOFILE_TEST_UNSET}/bin")},
+		{"a/../b//c", filepath.FromSlash("b/c")},
+		{"not~home", "not~home"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := ExpandPath(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ExpandPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandHomeUser(t *testing.T) {
+	u, err := user.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	got, err := ExpandHome("~" + u.Username + "/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := u.HomeDir + "/x"; got != want {
+		t.Errorf("ExpandHome() = %q, want %q", got, want)
+	}
+
+	if _, err := ExpandHome("~no-such-user-gofile/x"); err == nil {
+		t.Errorf("ExpandHome() of an unknown user succeeded")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	wd := PWD()
+	got, err := Normalize("a/./b/..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(wd, "a"); got != want {
+		t.Errorf("Normalize() = %q, want %q", got, want)
+	}
+}
+
+func TestXDG(t *testing.T) {
+	home := t.TempDir()
+	setenv(t, "HOME", home)
+	setenv(t, "USERPROFILE", home)
+	setenv(t, "XDG_CONFIG_HOME", filepath.Join(home, "cfg"))
+	setenv(t, "XDG_CACHE_HOME", "relative/cache") // ignored, not absolute
+	setenv(t, "XDG_DATA_HOME", "")
+	setenv(t, "XDG_STATE_HOME", "")
+
+	tests := []struct {
+		name string
+		fn   func() (string, error)
+		want string
+	}{
+		{"config", ConfigHome, filepath.Join(home, "cfg")},
+		{"cache", CacheHome, filepath.Join(home, ".cache")},
+		{"data", DataHome, filepath.Join(home, ".local", "share")},
+		{"state", StateHome, filepath.Join(home, ".local", "state")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.fn()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSafeJoin(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{filepath.Join(root, "sub"), outside} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := map[string]string{
+		"escape":   outside,
+		"relative": "../outside",
+		"inside":   "sub",
+		"dangling": filepath.Join(outside, "missing"),
+		"loop":     "loop",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skip("symlinks not supported:", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		elem    []string
+		want    string
+		wantErr error
+	}{
+		{"file", []string{"a.txt"}, filepath.Join(root, "a.txt"), nil},
+		{"nested missing", []string{"new", "dir", "f"}, filepath.Join(root, "new", "dir", "f"), nil},
+		{"dot dot inside", []string{"sub/../b"}, filepath.Join(root, "b"), nil},
+		{"absolute elem", []string{"/etc/passwd"}, filepath.Join(root, "etc", "passwd"), nil},
+		{"root itself", []string{"."}, root, nil},
+		{"link inside", []string{"inside", "f"}, filepath.Join(root, "inside", "f"), nil},
+		{"dot dot", []string{"../outside/f"}, "", ErrOutsideRoot},
+		{"dot dot prefix", []string{"sub", "../../rootx"}, "", ErrOutsideRoot},
+		{"symlink out", []string{"escape", "f"}, "", ErrOutsideRoot},
+		{"relative symlink out", []string{"relative"}, "", ErrOutsideRoot},
+		{"dangling symlink out", []string{"dangling"}, "", ErrOutsideRoot},
+		{"loop", []string{"loop", "f"}, "", ErrSymlinkLoop},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SafeJoin(root, tt.elem...)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("SafeJoin() = %q, %v, want %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("SafeJoin() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
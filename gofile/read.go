@@ -0,0 +1,113 @@
+package gofile
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// copyBufSize is the size of the pooled buffers used by CopyStream.
+// It matches the buffer io.Copy allocates on every call.
+const copyBufSize = 64 * chunk
+
+// bufPool holds chunk aligned buffers for streaming copies.
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, copyBufSize)
+		return &b
+	},
+}
+
+// getBuffer returns a copyBufSize buffer from the pool.
+// Return it with putBuffer when done.
+func getBuffer() *[]byte { return bufPool.Get().(*[]byte) }
+
+func putBuffer(b *[]byte) { bufPool.Put(b) }
+
+// CopyStream copies from src to dst like io.Copy, but uses a buffer
+// from a shared pool instead of allocating one on every call. As with
+// io.CopyBuffer, the buffer is not used if src implements io.WriterTo
+// or dst implements io.ReaderFrom.
+func CopyStream(dst io.Writer, src io.Reader) (int64, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	return io.CopyBuffer(dst, src, *buf)
+}
+
+// ReadAll reads from r until EOF and returns the data it read.
+// A successful call returns err == nil, not err == io.EOF.
+//
+// If r reports its size, through Stat (as *os.File does) or Len (as
+// *bytes.Reader and *strings.Reader do), the buffer is allocated once
+// with InitialCapacity instead of being grown as data arrives.
+func ReadAll(r io.Reader) ([]byte, error) {
+	return readAll(r, sizeHint(r))
+}
+
+// ReadFile reads the named file and returns its contents. The buffer is
+// sized from Stat, so a regular file is read with a single allocation.
+func ReadFile(name string) ([]byte, error) {
+	return On(nil).ReadFile(name)
+}
+
+func readFileOS(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, newPathError("read", name, err)
+	}
+	defer f.Close()
+
+	var size int64
+	if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+		size = fi.Size()
+	}
+
+	data, err := readAll(f, size)
+	if err != nil {
+		return nil, newPathError("read", name, err)
+	}
+	return data, nil
+}
+
+// sizeHint returns the number of bytes r is expected to return, or 0.
+func sizeHint(r io.Reader) int64 {
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		return int64(v.Len())
+	case interface {
+		Stat() (os.FileInfo, error)
+	}:
+		fi, err := v.Stat()
+		if err != nil || !fi.Mode().IsRegular() {
+			return 0
+		}
+		size := fi.Size()
+		if s, ok := r.(io.Seeker); ok {
+			if off, err := s.Seek(0, io.SeekCurrent); err == nil && off <= size {
+				size -= off
+			}
+		}
+		return size
+	}
+	return 0
+}
+
+// readAll reads r until EOF into a buffer with room for size bytes.
+// InitialCapacity leaves at least one spare byte, so reading a source
+// of the expected size reaches EOF without growing the buffer.
+func readAll(r io.Reader, size int64) ([]byte, error) {
+	b := make([]byte, 0, InitialCapacity(size))
+	for {
+		if len(b) == cap(b) {
+			b = append(b, 0)[:len(b)]
+		}
+		n, err := r.Read(b[len(b):cap(b)])
+		b = b[:len(b)+n]
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return b, err
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package gofile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadAll(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1000) // 16000 bytes
+
+	name := filepath.Join(t.TempDir(), "data")
+	if err := ioutil.WriteFile(name, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		reader func(t *testing.T) io.Reader
+		want   []byte
+	}{
+		{"bytes reader", func(t *testing.T) io.Reader { return bytes.NewReader(data) }, data},
+		{"strings reader", func(t *testing.T) io.Reader { return strings.NewReader("short") }, []byte("short")},
+		{"empty", func(t *testing.T) io.Reader { return strings.NewReader("") }, []byte{}},
+		{"unsized", func(t *testing.T) io.Reader { return io.LimitReader(bytes.NewReader(data), 10000) }, data[:10000]},
+		{"file", func(t *testing.T) io.Reader { return openFile(t, name, 0) }, data},
+		{"file at offset", func(t *testing.T) io.Reader { return openFile(t, name, 6000) }, data[6000:]},
+		{"file at end", func(t *testing.T) io.Reader { return openFile(t, name, int64(len(data))) }, []byte{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadAll(tt.reader(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("ReadAll() returned %d bytes, want %d", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func openFile(t *testing.T, name string, off int64) *os.File {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestReadAllError(t *testing.T) {
+	boom := errors.New("boom")
+	r := io.MultiReader(strings.NewReader("partial"), &errReader{boom})
+
+	got, err := ReadAll(r)
+	if err != boom {
+		t.Errorf("error = %v, want %v", err, boom)
+	}
+	if string(got) != "partial" {
+		t.Errorf("ReadAll() = %q, want %q", got, "partial")
+	}
+}
+
+type errReader struct{ err error }
+
+func (r *errReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"small", 10},
+		{"chunk", chunk},
+		{"default buffer", defaultBufSize},
+		{"large", 3*defaultBufSize + 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := bytes.Repeat([]byte{'x'}, tt.size)
+			name := filepath.Join(dir, tt.name)
+			if err := ioutil.WriteFile(name, want, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("ReadFile() returned %d bytes, want %d", len(got), len(want))
+			}
+			if cap(got) != InitialCapacity(int64(tt.size)) {
+				t.Errorf("cap = %d, want %d (buffer was grown)", cap(got), InitialCapacity(int64(tt.size)))
+			}
+		})
+	}
+
+	if _, err := ReadFile(filepath.Join(dir, "missing")); !errors.Is(err, ErrNotExist) {
+		t.Errorf("ReadFile(missing) error = %v, want ErrNotExist", err)
+	}
+	if _, err := ReadFile(dir); !errors.Is(err, ErrIsDir) {
+		t.Errorf("ReadFile(dir) error = %v, want ErrIsDir", err)
+	}
+}
+
+func TestCopyStream(t *testing.T) {
+	data := bytes.Repeat([]byte("abc"), copyBufSize)
+
+	var dst bytes.Buffer
+	// hide WriterTo and ReaderFrom so the pooled buffer is used
+	n, err := CopyStream(struct{ io.Writer }{&dst}, struct{ io.Reader }{bytes.NewReader(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) || !bytes.Equal(dst.Bytes(), data) {
+		t.Errorf("CopyStream() copied %d bytes, want %d", n, len(data))
+	}
+}
+
+// benchFile creates a file of size bytes for the read benchmarks.
+func benchFile(b *testing.B, size int) string {
+	name := filepath.Join(b.TempDir(), "bench")
+	if err := ioutil.WriteFile(name, bytes.Repeat([]byte{'x'}, size), 0644); err != nil {
+		b.Fatal(err)
+	}
+	return name
+}
+
+const benchSize = 8 << 20
+
+func BenchmarkReadAll(b *testing.B) {
+	name := benchFile(b, benchSize)
+	b.ReportAllocs()
+	b.SetBytes(benchSize)
+	for i := 0; i < b.N; i++ {
+		f, err := os.Open(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := ReadAll(f); err != nil {
+			b.Fatal(err)
+		}
+		f.Close()
+	}
+}
+
+func BenchmarkIoutilReadAll(b *testing.B) {
+	name := benchFile(b, benchSize)
+	b.ReportAllocs()
+	b.SetBytes(benchSize)
+	for i := 0; i < b.N; i++ {
+		f, err := os.Open(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := ioutil.ReadAll(f); err != nil {
+			b.Fatal(err)
+		}
+		f.Close()
+	}
+}
+
+func BenchmarkReadFile(b *testing.B) {
+	name := benchFile(b, benchSize)
+	b.ReportAllocs()
+	b.SetBytes(benchSize)
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadFile(name); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCopyStream(b *testing.B) {
+	data := bytes.Repeat([]byte{'x'}, benchSize)
+	b.ReportAllocs()
+	b.SetBytes(benchSize)
+	for i := 0; i < b.N; i++ {
+		CopyStream(struct{ io.Writer }{ioutil.Discard}, struct{ io.Reader }{bytes.NewReader(data)})
+	}
+}
+
+func BenchmarkIoCopy(b *testing.B) {
+	data := bytes.Repeat([]byte{'x'}, benchSize)
+	b.ReportAllocs()
+	b.SetBytes(benchSize)
+	for i := 0; i < b.N; i++ {
+		io.Copy(struct{ io.Writer }{ioutil.Discard}, struct{ io.Reader }{bytes.NewReader(data)})
+	}
+}
@@ -0,0 +1,501 @@
+package gofile
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateNaming selects how rotated generations of a file are named.
+type RotateNaming int
+
+const (
+	// RotateNumbered names generations name.1, name.2, ... with
+	// name.1 the newest. Older generations are renumbered on every
+	// rotation, as logrotate does.
+	RotateNumbered RotateNaming = iota
+
+	// RotateTimestamp names generations "name-YYYYMMDD-HHMMSS.ext"
+	// after the time of the rotation, adding " (N)" if that name is
+	// taken. Generations are never renamed.
+	RotateTimestamp
+)
+
+var rotateNamingNames = []string{"numbered", "timestamp"}
+
+func (n RotateNaming) String() string {
+	if n < 0 || int(n) >= len(rotateNamingNames) {
+		return fmt.Sprintf("RotateNaming(%d)", int(n))
+	}
+	return rotateNamingNames[n]
+}
+
+// RotateOptions configures Rotate and RotatingWriter.
+type RotateOptions struct {
+	Naming RotateNaming
+
+	// Keep is the number of rotated generations kept, not counting
+	// the current file. Older ones are removed. 0 keeps all.
+	Keep int
+
+	// Compress gzips rotated generations, adding ".gz" to their names.
+	Compress bool
+
+	// MaxSize rotates a file that has reached MaxSize bytes, and
+	// MaxAge one that was started at least MaxAge ago. If neither is
+	// set, Rotate always rotates and RotatingWriter only rotates when
+	// its Rotate method is called.
+	MaxSize int64
+	MaxAge  time.Duration
+
+	// Perm is the mode of files created by RotatingWriter.
+	// The default is 0644.
+	Perm os.FileMode
+}
+
+func rotateOptions(opts *RotateOptions) RotateOptions {
+	var o RotateOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Perm == 0 {
+		o.Perm = 0644
+	}
+	return o
+}
+
+// due reports whether a file of size bytes started at start is due
+// for rotation.
+func (o RotateOptions) due(size int64, start time.Time) bool {
+	if o.MaxSize <= 0 && o.MaxAge <= 0 {
+		return true
+	}
+	return (o.MaxSize > 0 && size >= o.MaxSize) ||
+		(o.MaxAge > 0 && !start.IsZero() && time.Since(start) >= o.MaxAge)
+}
+
+// Rotate moves name to a new generation if it is due, according to
+// opts.MaxSize and opts.MaxAge, and removes generations beyond
+// opts.Keep. It returns the name of the new generation, or "" if name
+// was not rotated. A missing name is not an error and is not rotated.
+//
+// The age of a file is measured from when its newest generation was
+// rotated. With RotateTimestamp that time is in the generation's name.
+// Numbered generations do not record it, so the age is measured from
+// the last write to the newest one, which for a file that is written
+// up to its rotation, such as a log, is about the same. A file that
+// has never been rotated is as old as its own modification time.
+//
+// Rotate is meant to be called before a file is rewritten, such as a
+// database dump, or by a single writer of a log. Concurrent rotations
+// of the same file are not coordinated. opts may be nil.
+func Rotate(name string, opts *RotateOptions) (string, error) {
+	o := rotateOptions(opts)
+
+	fi, err := os.Stat(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", newPathError("rotate", name, err)
+	}
+	if !fi.Mode().IsRegular() {
+		return "", newPathError("rotate", name, ErrNotRegular)
+	}
+
+	if !o.due(fi.Size(), startTime(name, fi, o.Naming)) {
+		return "", nil
+	}
+	return rotate(name, o)
+}
+
+// startTime returns when the current generation of name, described by
+// fi, was started, as documented for Rotate.
+func startTime(name string, fi os.FileInfo, naming RotateNaming) time.Time {
+	gens, err := scanGenerations(name, naming)
+	if err != nil || len(gens) == 0 {
+		return fi.ModTime()
+	}
+	if naming == RotateTimestamp {
+		return gens[0].t
+	}
+	if gi, err := os.Stat(gens[0].path); err == nil {
+		return gi.ModTime()
+	}
+	return fi.ModTime()
+}
+
+// rotate moves name to a new generation unconditionally.
+func rotate(name string, o RotateOptions) (string, error) {
+	var gen string
+	var err error
+	if o.Naming == RotateTimestamp {
+		gen, err = rotateTimestamp(name)
+	} else {
+		gen, err = rotateNumbered(name, o.Keep)
+	}
+	if err != nil {
+		return "", newPathError("rotate", name, err)
+	}
+
+	if o.Compress {
+		if gen, err = gzipFile(gen); err != nil {
+			return "", newPathError("rotate", name, err)
+		}
+	}
+
+	if o.Keep > 0 {
+		gens, err := RotatedFiles(name, o.Naming)
+		if err != nil {
+			return "", err
+		}
+		for i := o.Keep; i < len(gens); i++ {
+			old := gens[i]
+			if err := os.Remove(old); err != nil {
+				return "", newPathError("rotate", old, err)
+			}
+		}
+	}
+	return gen, nil
+}
+
+// rotateNumbered shifts name.N to name.N+1, dropping generations that
+// would be beyond keep, and moves name to name.1.
+func rotateNumbered(name string, keep int) (string, error) {
+	gens, err := scanGenerations(name, RotateNumbered)
+	if err != nil {
+		return "", err
+	}
+
+	// highest first, so nothing is overwritten
+	for i := len(gens) - 1; i >= 0; i-- {
+		g := gens[i]
+		if keep > 0 && g.n >= keep {
+			if err := os.Remove(g.path); err != nil {
+				return "", err
+			}
+			continue
+		}
+		next := name + "." + strconv.Itoa(g.n+1) + g.ext
+		if err := os.Rename(g.path, next); err != nil {
+			return "", err
+		}
+	}
+
+	gen := name + ".1"
+	if err := os.Rename(name, gen); err != nil {
+		return "", err
+	}
+	return gen, nil
+}
+
+// rotateTimestamp moves name to the first free timestamped name. The
+// move is a hard link and a remove, so an existing generation is never
+// replaced.
+func rotateTimestamp(name string) (string, error) {
+	now := time.Now()
+	dir, file := filepath.Split(name)
+	stem, ext := splitExt(file)
+	base := dir + stem + "-" + now.Format(timestampLayout)
+
+	// continue after the newest generation of the same second, so
+	// generations still sort by age after older ones were removed
+	start := 0
+	gens, err := scanGenerations(name, RotateTimestamp)
+	if err != nil {
+		return "", err
+	}
+	if len(gens) > 0 && gens[0].t.Format(timestampLayout) == now.Format(timestampLayout) {
+		start = gens[0].n + 1
+	}
+
+	for n := start; n < start+maxCollisionAttempts; n++ {
+		gen := base + ext
+		if n > 0 {
+			gen = fmt.Sprintf("%s (%d)%s", base, n, ext)
+		}
+		if _, err := os.Lstat(gen + ".gz"); err == nil {
+			continue
+		}
+		err := os.Link(name, gen)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return gen, os.Remove(name)
+	}
+	return "", fmt.Errorf("no free generation name after %d attempts: %w", maxCollisionAttempts, ErrExists)
+}
+
+// gzipFile compresses name to name.gz, keeping its mode and
+// modification time, and removes name.
+func gzipFile(name string) (string, error) {
+	in, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	gz := name + ".gz"
+	a, err := CreateAtomic(gz, fi.Mode().Perm())
+	if err != nil {
+		return "", err
+	}
+	defer a.Abort()
+
+	zw := gzip.NewWriter(a)
+	zw.Name = filepath.Base(name)
+	zw.ModTime = fi.ModTime()
+	if _, err := CopyStream(zw, in); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	if err := a.Close(); err != nil {
+		return "", err
+	}
+
+	if err := os.Chtimes(gz, fi.ModTime(), fi.ModTime()); err != nil {
+		return "", err
+	}
+	return gz, os.Remove(name)
+}
+
+// generation is a rotated generation of a file.
+type generation struct {
+	path string
+	ext  string    // ".gz" or ""
+	n    int       // number, or collision index for timestamps
+	t    time.Time // timestamp
+}
+
+// RotatedFiles returns the rotated generations of name, newest first.
+func RotatedFiles(name string, naming RotateNaming) ([]string, error) {
+	gens, err := scanGenerations(name, naming)
+	if err != nil {
+		return nil, newPathError("rotate", name, err)
+	}
+	paths := make([]string, len(gens))
+	for i, g := range gens {
+		paths[i] = g.path
+	}
+	return paths, nil
+}
+
+// scanGenerations returns the generations of name, newest first.
+func scanGenerations(name string, naming RotateNaming) ([]generation, error) {
+	dir, base := filepath.Split(name)
+	list := dir
+	if list == "" {
+		list = "."
+	}
+	entries, err := os.ReadDir(list)
+	if err != nil {
+		return nil, err
+	}
+
+	stem, ext := splitExt(base)
+
+	var gens []generation
+	for _, de := range entries {
+		g := generation{path: filepath.Join(dir, de.Name())}
+		s := de.Name()
+		if strings.HasSuffix(s, ".gz") {
+			s, g.ext = strings.TrimSuffix(s, ".gz"), ".gz"
+		}
+
+		switch naming {
+		case RotateNumbered:
+			if !strings.HasPrefix(s, base+".") {
+				continue
+			}
+			n, err := strconv.Atoi(s[len(base)+1:])
+			if err != nil || n < 1 || strconv.Itoa(n) != s[len(base)+1:] {
+				continue
+			}
+			g.n = n
+		case RotateTimestamp:
+			if !strings.HasPrefix(s, stem+"-") || !strings.HasSuffix(s, ext) {
+				continue
+			}
+			s = strings.TrimSuffix(s[len(stem)+1:], ext)
+			if len(s) < len(timestampLayout) {
+				continue
+			}
+			t, err := time.ParseInLocation(timestampLayout, s[:len(timestampLayout)], time.Local)
+			if err != nil {
+				continue
+			}
+			if rest := s[len(timestampLayout):]; rest != "" {
+				if !strings.HasPrefix(rest, " (") || !strings.HasSuffix(rest, ")") {
+					continue
+				}
+				if g.n, err = strconv.Atoi(rest[2 : len(rest)-1]); err != nil {
+					continue
+				}
+			}
+			g.t = t
+		}
+		gens = append(gens, g)
+	}
+
+	sort.Slice(gens, func(i, j int) bool {
+		if !gens[i].t.Equal(gens[j].t) {
+			return gens[i].t.After(gens[j].t)
+		}
+		if naming == RotateNumbered {
+			return gens[i].n < gens[j].n
+		}
+		return gens[i].n > gens[j].n
+	})
+	return gens, nil
+}
+
+// RotatingWriter is an io.Writer that appends to a file and rotates it
+// when it reaches RotateOptions.MaxSize or MaxAge. It is safe for
+// concurrent use, but only one RotatingWriter should write to a file.
+type RotatingWriter struct {
+	mu    sync.Mutex
+	name  string
+	opts  RotateOptions
+	f     *os.File
+	size  int64
+	start time.Time
+}
+
+// NewRotatingWriter opens name for appending, creating it if needed,
+// and returns a RotatingWriter for it. The file is rotated first if
+// it is already due. opts may be nil.
+func NewRotatingWriter(name string, opts *RotateOptions) (*RotatingWriter, error) {
+	w := &RotatingWriter{name: name, opts: rotateOptions(opts)}
+	if w.opts.MaxSize > 0 || w.opts.MaxAge > 0 {
+		if _, err := Rotate(name, &w.opts); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// open opens the current file and records its size and start time.
+func (w *RotatingWriter) open() error {
+	f, err := os.OpenFile(w.name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, w.opts.Perm)
+	if err != nil {
+		return newPathError("rotate", w.name, err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return newPathError("rotate", w.name, err)
+	}
+
+	w.f, w.size = f, fi.Size()
+	w.start = time.Now()
+	if fi.Size() > 0 {
+		w.start = startTime(w.name, fi, w.opts.Naming)
+	}
+	return nil
+}
+
+// Name returns the name of the file being written.
+func (w *RotatingWriter) Name() string { return w.name }
+
+// Write appends p to the file, rotating it first if the write would
+// take it past MaxSize or it has reached MaxAge. A write is never
+// split, so a file can exceed MaxSize by a single write.
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return 0, newPathError("write", w.name, os.ErrClosed)
+	}
+	if w.size > 0 && w.due(len(p)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	if err != nil {
+		return n, newPathError("write", w.name, err)
+	}
+	return n, nil
+}
+
+// due reports whether the file must be rotated before writing n bytes.
+func (w *RotatingWriter) due(n int) bool {
+	o := w.opts
+	return (o.MaxSize > 0 && w.size+int64(n) > o.MaxSize) ||
+		(o.MaxAge > 0 && time.Since(w.start) >= o.MaxAge)
+}
+
+// Rotate rotates the file now, regardless of its size and age.
+func (w *RotatingWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return newPathError("rotate", w.name, os.ErrClosed)
+	}
+	return w.rotate()
+}
+
+func (w *RotatingWriter) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return newPathError("rotate", w.name, err)
+	}
+	w.f = nil
+	if _, err := rotate(w.name, w.opts); err != nil {
+		// keep writing to the current file
+		if oerr := w.open(); oerr != nil {
+			return oerr
+		}
+		return err
+	}
+	return w.open()
+}
+
+// Sync commits the current file to stable storage.
+func (w *RotatingWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return newPathError("sync", w.name, os.ErrClosed)
+	}
+	return w.f.Sync()
+}
+
+// Close closes the file. Writes after Close return an error.
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	if err != nil {
+		return newPathError("close", w.name, err)
+	}
+	return nil
+}
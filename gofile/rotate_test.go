@@ -0,0 +1,278 @@
+package gofile
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readGen returns the contents of a generation, decompressing it if needed.
+func readGen(t *testing.T, name string) string {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if !strings.HasSuffix(name, ".gz") {
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// genContents returns the contents of the generations of name, newest first.
+func genContents(t *testing.T, name string, naming RotateNaming) []string {
+	t.Helper()
+	gens, err := RotatedFiles(name, naming)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, g := range gens {
+		got = append(got, readGen(t, g))
+	}
+	return got
+}
+
+func TestRotate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts RotateOptions
+		want []string // generation names, newest first
+	}{
+		{"numbered", RotateOptions{}, []string{"app.log.1", "app.log.2", "app.log.3", "app.log.4"}},
+		{"numbered keep", RotateOptions{Keep: 2}, []string{"app.log.1", "app.log.2"}},
+		{"numbered compressed", RotateOptions{Keep: 3, Compress: true}, []string{"app.log.1.gz", "app.log.2.gz", "app.log.3.gz"}},
+		{"timestamp keep", RotateOptions{Naming: RotateTimestamp, Keep: 2}, nil},
+		{"timestamp compressed", RotateOptions{Naming: RotateTimestamp, Compress: true}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			name := filepath.Join(dir, "app.log")
+			// unrelated files that look a little like generations
+			makeTree(t, dir, map[string]string{"app.log.x": "", "app.log.01": "", "app-1.log": "", "other.log.1": ""})
+
+			for i := 1; i <= 4; i++ {
+				if err := ioutil.WriteFile(name, []byte(fmt.Sprint("gen ", i)), 0644); err != nil {
+					t.Fatal(err)
+				}
+				gen, err := Rotate(name, &tt.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if gen == "" || exists(name) {
+					t.Fatalf("Rotate() = %q, %s exists: %v", gen, name, exists(name))
+				}
+			}
+
+			want := []string{"gen 4", "gen 3", "gen 2", "gen 1"}
+			if tt.opts.Keep > 0 {
+				want = want[:tt.opts.Keep]
+			}
+			if got := genContents(t, name, tt.opts.Naming); !reflect.DeepEqual(got, want) {
+				t.Errorf("generations = %q, want %q", got, want)
+			}
+
+			if tt.want != nil {
+				gens, _ := RotatedFiles(name, tt.opts.Naming)
+				for i := range gens {
+					gens[i] = filepath.Base(gens[i])
+				}
+				if !reflect.DeepEqual(gens, tt.want) {
+					t.Errorf("RotatedFiles() = %v, want %v", gens, tt.want)
+				}
+			}
+			for _, other := range []string{"app.log.x", "app.log.01", "app-1.log", "other.log.1"} {
+				if !exists(filepath.Join(dir, other)) {
+					t.Errorf("%s was removed", other)
+				}
+			}
+		})
+	}
+}
+
+func TestRotateDotfile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, ".history")
+	for i := 1; i <= 2; i++ {
+		makeTree(t, dir, map[string]string{".history": fmt.Sprint("gen ", i)})
+		if _, err := Rotate(name, &RotateOptions{Naming: RotateTimestamp}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gens, err := RotatedFiles(name, RotateTimestamp)
+	if err != nil || len(gens) != 2 {
+		t.Fatalf("RotatedFiles() = %v, %v, want 2 generations", gens, err)
+	}
+	for _, gen := range gens {
+		if ok, _ := filepath.Match(".history-????????-??????*", filepath.Base(gen)); !ok {
+			t.Errorf("generation %s does not start with .history-", gen)
+		}
+	}
+}
+
+func TestRotateTriggers(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "dump.sql")
+
+	if gen, err := Rotate(name, nil); gen != "" || err != nil {
+		t.Errorf("Rotate(missing) = %q, %v, want no rotation", gen, err)
+	}
+
+	makeTree(t, dir, map[string]string{"dump.sql": "12345"})
+	tests := []struct {
+		name string
+		opts RotateOptions
+		want bool
+	}{
+		{"under size", RotateOptions{MaxSize: 6}, false},
+		{"at size", RotateOptions{MaxSize: 5}, true},
+		{"young", RotateOptions{MaxAge: time.Hour}, false},
+		{"old", RotateOptions{MaxAge: time.Minute}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the file was last changed half an hour ago
+			old := time.Now().Add(-30 * time.Minute)
+			if err := os.Chtimes(name, old, old); err != nil {
+				t.Fatal(err)
+			}
+			gen, err := Rotate(name, &tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (gen != "") != tt.want {
+				t.Errorf("Rotate() = %q, want rotated %v", gen, tt.want)
+			}
+			if gen != "" {
+				if err := os.Rename(gen, name); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRotateAge(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+
+	// the newest generation sat unchanged for two hours before it was
+	// rotated twenty minutes ago
+	rotated := time.Now().Add(-20 * time.Minute)
+	gen := filepath.Join(dir, "app-"+rotated.Format(timestampLayout)+".log")
+	makeTree(t, dir, map[string]string{"app.log": "new\n", filepath.Base(gen): "old\n"})
+	idle := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(gen, idle, idle); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		maxAge time.Duration
+		want   bool
+	}{
+		{time.Hour, false},
+		{10 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		fi, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := RotateOptions{Naming: RotateTimestamp, MaxAge: tt.maxAge}
+		if got := o.due(fi.Size(), startTime(name, fi, o.Naming)); got != tt.want {
+			t.Errorf("MaxAge %v: due = %v, want %v", tt.maxAge, got, tt.want)
+		}
+	}
+}
+
+func TestRotatingWriter(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	makeTree(t, dir, map[string]string{"app.log": "old\n"})
+
+	w, err := NewRotatingWriter(name, &RotateOptions{MaxSize: 10, Keep: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"one\n", "two\n", "three\n", "a line longer than MaxSize\n", "four\n"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readGen(t, name); got != "four\n" {
+		t.Errorf("current = %q, want %q", got, "four\n")
+	}
+	want := []string{"a line longer than MaxSize\n", "two\nthree\n"}
+	if got := genContents(t, name, RotateNumbered); !reflect.DeepEqual(got, want) {
+		t.Errorf("generations = %q, want %q", got, want)
+	}
+
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Errorf("Write() after Close succeeded")
+	}
+}
+
+func TestRotatingWriterConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	w, err := NewRotatingWriter(name, &RotateOptions{MaxSize: 100, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const writers, lines = 4, 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < lines; j++ {
+				fmt.Fprintf(w, "writer %d line %02d\n", i, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	for _, s := range genContents(t, name, RotateNumbered) {
+		if len(s) > 100 {
+			t.Errorf("generation of %d bytes, want at most 100", len(s))
+		}
+		n += strings.Count(s, "\n")
+	}
+	if n != writers*lines {
+		t.Errorf("%d lines in generations, want %d", n, writers*lines)
+	}
+}
@@ -0,0 +1,21 @@
+package gofile
+
+import "fmt"
+
+// HumanSize formats a byte count with binary prefixes,
+// e.g. 1536 is "1.5K" and 3221225472 is "3.0G".
+func HumanSize(n int64) string {
+	const units = "KMGTPE"
+
+	if n < 1024 && n > -1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	f := float64(n)
+	i := -1
+	for (f >= 1024 || f <= -1024) && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f%c", f, units[i])
+}
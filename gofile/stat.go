@@ -0,0 +1,7 @@
+package gofile
+
+// devIno identifies a file by device and inode number.
+type devIno struct {
+	dev uint64
+	ino uint64
+}
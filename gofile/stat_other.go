@@ -0,0 +1,26 @@
+//go:build windows || plan9 || js
+// +build windows plan9 js
+
+package gofile
+
+import "os"
+
+// fileOwner is not available on this platform.
+func fileOwner(fi os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
+
+// fileID is not available on this platform.
+func fileID(fi os.FileInfo) (id devIno, ok bool) {
+	return devIno{}, false
+}
+
+// allocSize is not available on this platform; it returns the size of fi.
+func allocSize(fi os.FileInfo) int64 {
+	return fi.Size()
+}
+
+// linkCount is not available on this platform.
+func linkCount(fi os.FileInfo) uint64 {
+	return 1
+}
@@ -0,0 +1,46 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package gofile
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner returns the uid and gid that own fi.
+func fileOwner(fi os.FileInfo) (uid, gid int, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
+
+// fileID returns the device and inode numbers of fi. Two paths
+// with the same fileID are hard links to the same file.
+func fileID(fi os.FileInfo) (id devIno, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return devIno{}, false
+	}
+	return devIno{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
+
+// allocSize returns the number of bytes of disk space allocated to fi.
+func allocSize(fi os.FileInfo) int64 {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fi.Size()
+	}
+	return int64(st.Blocks) * 512
+}
+
+// linkCount returns the number of hard links to fi.
+func linkCount(fi os.FileInfo) uint64 {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 1
+	}
+	return uint64(st.Nlink)
+}
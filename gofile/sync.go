@@ -0,0 +1,405 @@
+package gofile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SyncOptions configures Sync. The zero value copies new and changed
+// files, compared by size and modification time, and deletes nothing.
+type SyncOptions struct {
+	// Checksum compares files of the same size by their checksum with
+	// this algorithm instead of by modification time.
+	Checksum HashAlgorithm
+
+	// Delete removes files and directories in dst that are not in src,
+	// and lets a file in src replace a directory in dst. Files excluded
+	// by Filters or Exclude are never deleted.
+	Delete bool
+
+	// Filters must all match for a file or symlink to be synced.
+	// Directories are synced regardless, so files below them can be.
+	Filters []Filter
+
+	// Exclude skips entries that match any of the filters. An excluded
+	// directory is skipped along with everything below it.
+	Exclude []Filter
+
+	// DryRun plans the sync without changing anything.
+	DryRun bool
+}
+
+// SyncAction is what Sync does to a path in the destination.
+type SyncAction int
+
+const (
+	SyncAdd SyncAction = iota + 1
+	SyncUpdate
+	SyncDelete
+
+	// SyncConflict is a file in src that would replace a directory in
+	// dst. It is not synced, since that would delete the directory
+	// without Delete, or delete excluded files below it.
+	SyncConflict
+)
+
+func (a SyncAction) String() string {
+	switch a {
+	case SyncAdd:
+		return "add"
+	case SyncUpdate:
+		return "update"
+	case SyncDelete:
+		return "delete"
+	case SyncConflict:
+		return "conflict"
+	}
+	return fmt.Sprintf("SyncAction(%d)", int(a))
+}
+
+// SyncOp is one step of a sync.
+type SyncOp struct {
+	Action SyncAction
+	Path   string    // slash separated, relative to the sync roots
+	Type   EntryType // of the source, or of the destination for SyncDelete
+	Size   int64     // bytes copied, 0 for directories, symlinks and deletes
+}
+
+func (op SyncOp) String() string {
+	name := op.Path
+	if op.Type == TypeDir {
+		name += "/"
+	}
+	return fmt.Sprintf("%-8s %s", op.Action, name)
+}
+
+// Sync makes the directory dst a copy of the directory src, creating
+// dst if needed. Files are copied if they are missing from dst or have
+// changed, along with their mode and modification time; an unchanged
+// file is left alone. Files of the same size are compared by whole
+// second modification time and permissions, or with opts.Checksum by
+// contents. Symlinks are copied as symlinks. New directories get the
+// permissions of their source, but directory times are not synced.
+//
+// Sync returns the operations done, or with opts.DryRun the operations
+// that would be done: additions, updates and conflicts in walk order,
+// then deletions deepest first. On error, the operations done before
+// it are returned. Conflicts are skipped; once everything else is
+// synced, an error wrapping ErrIsDir is returned for the first of
+// them, unless opts.DryRun is set. opts may be nil.
+func Sync(src, dst string, opts *SyncOptions) ([]SyncOp, error) {
+	var o SyncOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Checksum != "" {
+		if _, err := o.Checksum.New(); err != nil {
+			return nil, err
+		}
+	}
+
+	if fi, err := os.Stat(src); err != nil {
+		return nil, newPathError("sync", src, err)
+	} else if !fi.IsDir() {
+		return nil, newPathError("sync", src, ErrNotDir)
+	}
+	if err := checkNotInside(src, dst); err != nil {
+		return nil, err
+	}
+
+	p := &syncPlan{src: src, dst: dst, o: o, seen: make(map[string]bool), replaced: make(map[string]bool)}
+	if err := p.planCopies(); err != nil {
+		return nil, err
+	}
+	if o.Delete {
+		if err := p.planDeletes(); err != nil {
+			return nil, err
+		}
+	}
+	if o.DryRun {
+		return p.ops, nil
+	}
+
+	if err := os.MkdirAll(dst, 0777); err != nil {
+		return nil, newPathError("sync", dst, err)
+	}
+	var conflict error
+	for i, op := range p.ops {
+		if op.Action == SyncConflict {
+			if conflict == nil {
+				conflict = newPathError("sync", filepath.Join(dst, filepath.FromSlash(op.Path)), ErrIsDir)
+			}
+			continue
+		}
+		if err := p.apply(op); err != nil {
+			return p.ops[:i], err
+		}
+	}
+	return p.ops, conflict
+}
+
+// syncPlan collects the operations of a sync before any are done.
+type syncPlan struct {
+	src, dst string
+	o        SyncOptions
+	ops      []SyncOp
+	seen     map[string]bool // paths synced from src
+	replaced map[string]bool // dst directories replaced by a file or symlink
+}
+
+func (o SyncOptions) excluded(e Entry) bool {
+	for _, f := range o.Exclude {
+		if f(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// planCopies walks src and plans the additions and updates.
+func (p *syncPlan) planCopies() error {
+	return Walk(p.src, &WalkOptions{FS: OS}, func(e Entry) error {
+		if e.Rel == "." {
+			return nil
+		}
+		if p.o.excluded(e) {
+			if e.IsDir() {
+				return SkipDir
+			}
+			return nil
+		}
+		if !e.IsDir() && !matchAll(p.o.Filters, e) {
+			return nil
+		}
+		if e.Type() == TypeOther {
+			return nil
+		}
+		p.seen[e.Rel] = true
+
+		target := filepath.Join(p.dst, filepath.FromSlash(e.Rel))
+		fi, err := os.Lstat(target)
+		if errors.Is(err, os.ErrNotExist) {
+			p.add(SyncAdd, e)
+			return nil
+		}
+		if err != nil {
+			return newPathError("sync", target, err)
+		}
+
+		if fi.IsDir() && !e.IsDir() {
+			ok, err := p.canReplace(e, target)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				p.ops = append(p.ops, SyncOp{Action: SyncConflict, Path: e.Rel, Type: e.Type()})
+				return nil
+			}
+			p.replaced[e.Rel] = true
+		}
+		changed, err := p.changed(e, target, fi)
+		if err != nil {
+			return err
+		}
+		if changed {
+			p.add(SyncUpdate, e)
+		}
+		return nil
+	})
+}
+
+// canReplace reports whether the src entry e may replace the dst
+// directory target, which deletes everything below it. That needs
+// Delete, and nothing below target may be excluded or filtered out.
+func (p *syncPlan) canReplace(e Entry, target string) (bool, error) {
+	if !p.o.Delete {
+		return false, nil
+	}
+	ok := true
+	err := Walk(target, &WalkOptions{FS: OS}, func(d Entry) error {
+		if d.Rel == "." {
+			return nil
+		}
+		d.Rel = e.Rel + "/" + d.Rel
+		d.Depth += e.Depth
+		if p.o.excluded(d) || !d.IsDir() && !matchAll(p.o.Filters, d) {
+			ok = false
+			return errStop
+		}
+		return nil
+	})
+	if err == errStop {
+		err = nil
+	}
+	return ok, err
+}
+
+func (p *syncPlan) add(action SyncAction, e Entry) {
+	op := SyncOp{Action: action, Path: e.Rel, Type: e.Type()}
+	if op.Type == TypeFile {
+		op.Size = e.Info.Size()
+	}
+	p.ops = append(p.ops, op)
+}
+
+// changed reports whether the dst file target, described by fi,
+// differs from the src entry e.
+func (p *syncPlan) changed(e Entry, target string, fi os.FileInfo) (bool, error) {
+	if fi.Mode().Type() != e.Info.Mode().Type() {
+		return true, nil
+	}
+
+	switch e.Type() {
+	case TypeDir:
+		return false, nil
+
+	case TypeSymlink:
+		want, err := os.Readlink(e.Path)
+		if err != nil {
+			return false, newPathError("sync", e.Path, err)
+		}
+		got, err := os.Readlink(target)
+		if err != nil {
+			return false, newPathError("sync", target, err)
+		}
+		return got != want, nil
+	}
+
+	if fi.Size() != e.Info.Size() || fi.Mode().Perm() != e.Info.Mode().Perm() {
+		return true, nil
+	}
+	if p.o.Checksum == "" {
+		return fi.ModTime().Unix() != e.Info.ModTime().Unix(), nil
+	}
+
+	want, err := Hash(e.Path, p.o.Checksum)
+	if err != nil {
+		return false, err
+	}
+	got, err := Hash(target, p.o.Checksum)
+	if err != nil {
+		return false, err
+	}
+	return got != want, nil
+}
+
+// planDeletes walks dst and plans the deletion of everything that was
+// not seen in src, except excluded and filtered out files and the
+// directories that hold them.
+func (p *syncPlan) planDeletes() error {
+	if _, err := os.Lstat(p.dst); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	var deletes []SyncOp
+	var kept []string
+	err := Walk(p.dst, &WalkOptions{FS: OS}, func(e Entry) error {
+		if e.Rel == "." {
+			return nil
+		}
+		if p.replaced[e.Rel] {
+			return SkipDir
+		}
+		if p.o.excluded(e) || !e.IsDir() && !matchAll(p.o.Filters, e) {
+			kept = append(kept, e.Rel)
+			if e.IsDir() {
+				return SkipDir
+			}
+			return nil
+		}
+		if !p.seen[e.Rel] {
+			deletes = append(deletes, SyncOp{Action: SyncDelete, Path: e.Rel, Type: e.Type()})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// deepest first, keeping the parents of kept files
+	for i := len(deletes) - 1; i >= 0; i-- {
+		op := deletes[i]
+		if op.Type == TypeDir && holdsAny(op.Path, kept) {
+			continue
+		}
+		p.ops = append(p.ops, op)
+	}
+	return nil
+}
+
+// holdsAny reports whether any of paths is below the directory dir.
+func holdsAny(dir string, paths []string) bool {
+	for _, rel := range paths {
+		if strings.HasPrefix(rel, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// apply carries out op.
+func (p *syncPlan) apply(op SyncOp) error {
+	src := filepath.Join(p.src, filepath.FromSlash(op.Path))
+	target := filepath.Join(p.dst, filepath.FromSlash(op.Path))
+
+	if op.Action == SyncDelete {
+		if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return newPathError("sync", target, err)
+		}
+		return nil
+	}
+
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return newPathError("sync", src, err)
+	}
+
+	// a file of another type is in the way
+	if op.Action == SyncUpdate {
+		if dfi, err := os.Lstat(target); err == nil && dfi.Mode().Type() != fi.Mode().Type() {
+			if err := os.RemoveAll(target); err != nil {
+				return newPathError("sync", target, err)
+			}
+		}
+	}
+
+	co := CopyOptions{PreserveMode: true, PreserveTimes: true, PreserveSymlinks: true, Collision: CollisionOverwrite}
+	switch op.Type {
+	case TypeDir:
+		// keep it writable, so the files below it can be synced
+		if err := os.Mkdir(target, fi.Mode().Perm()|0700); err != nil && !isDir(target) {
+			return newPathError("sync", target, err)
+		}
+		return nil
+	case TypeSymlink:
+		return copySymlink(src, target, co)
+	}
+	return copyFile(src, target, fi, co)
+}
+
+// WriteSyncPlan writes ops to w, one per line, such as "add    dir/"
+// or "update dir/file", followed by a summary.
+func WriteSyncPlan(w io.Writer, ops []SyncOp) error {
+	var counts [SyncConflict + 1]int
+	var size int64
+	for _, op := range ops {
+		if _, err := fmt.Fprintln(w, op); err != nil {
+			return err
+		}
+		if op.Action >= SyncAdd && op.Action <= SyncConflict {
+			counts[op.Action]++
+		}
+		size += op.Size
+	}
+	summary := fmt.Sprintf("%d to add, %d to update, %d to delete, %s to copy",
+		counts[SyncAdd], counts[SyncUpdate], counts[SyncDelete], HumanSize(size))
+	if n := counts[SyncConflict]; n > 0 {
+		summary += fmt.Sprintf(", %d conflicts", n)
+	}
+	_, err := fmt.Fprintln(w, summary)
+	return err
+}
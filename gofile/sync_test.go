@@ -0,0 +1,252 @@
+package gofile
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// syncOps formats ops as strings for comparisons.
+func syncOps(ops []SyncOp) []string {
+	var s []string
+	for _, op := range ops {
+		s = append(s, op.String())
+	}
+	return s
+}
+
+func TestSync(t *testing.T) {
+	setup := func(t *testing.T) (src, dst string) {
+		base := t.TempDir()
+		src, dst = filepath.Join(base, "src"), filepath.Join(base, "dst")
+		makeTree(t, src, map[string]string{
+			"same.txt":     "same",
+			"changed.txt":  "new contents",
+			"touched.txt":  "abc",
+			"new/file.go":  "package new",
+			"dir2file":     "now a file",
+			"keep/a.go":    "a",
+			"skip.log":     "log",
+			"cache/x.bin":  "x",
+			"chmod.sh":     "#!/bin/sh",
+			"sub/deep/f.c": "c",
+		})
+		makeTree(t, dst, map[string]string{
+			"same.txt":        "same",
+			"changed.txt":     "old",
+			"touched.txt":     "xyz",
+			"dir2file/x":      "gone",
+			"keep/a.go":       "a",
+			"keep/extra.go":   "extra",
+			"old/gone.txt":    "gone",
+			"skip.log":        "other log",
+			"old.log":         "protected",
+			"cache/y.bin":     "protected",
+			"chmod.sh":        "#!/bin/sh",
+			"sub/deep/f.c":    "c",
+			"sub/deep/old.md": "md",
+		})
+		if err := os.Chmod(filepath.Join(src, "chmod.sh"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink("same.txt", filepath.Join(src, "link")); err != nil {
+			t.Fatal(err)
+		}
+
+		// same sizes and times, except touched.txt which differs in
+		// contents but not in size or time
+		mtime := time.Date(2021, 4, 5, 6, 7, 8, 0, time.UTC)
+		for _, root := range []string{src, dst} {
+			for _, rel := range walkRel(t, root, &WalkOptions{Filters: []Filter{OfType(TypeFile)}}) {
+				if err := os.Chtimes(filepath.Join(root, filepath.FromSlash(rel)), mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		return src, dst
+	}
+
+	tests := []struct {
+		name string
+		opts SyncOptions
+		want []string
+	}{
+		{"size and time", SyncOptions{}, []string{
+			"add      cache/x.bin",
+			"update   changed.txt",
+			"update   chmod.sh",
+			"conflict dir2file",
+			"add      link",
+			"add      new/",
+			"add      new/file.go",
+			"update   skip.log",
+		}},
+		{"checksum", SyncOptions{Checksum: SHA256}, []string{
+			"add      cache/x.bin",
+			"update   changed.txt",
+			"update   chmod.sh",
+			"conflict dir2file",
+			"add      link",
+			"add      new/",
+			"add      new/file.go",
+			"update   skip.log",
+			"update   touched.txt",
+		}},
+		{"delete and exclude", SyncOptions{Delete: true, Exclude: []Filter{MatchGlob("*.log"), MatchGlob("cache")}}, []string{
+			"update   changed.txt",
+			"update   chmod.sh",
+			"update   dir2file",
+			"add      link",
+			"add      new/",
+			"add      new/file.go",
+			"delete   sub/deep/old.md",
+			"delete   old/gone.txt",
+			"delete   old/",
+			"delete   keep/extra.go",
+		}},
+		{"delete with an excluded file below", SyncOptions{Delete: true, Exclude: []Filter{MatchGlob("x")}}, []string{
+			"add      cache/x.bin",
+			"update   changed.txt",
+			"update   chmod.sh",
+			"conflict dir2file",
+			"add      link",
+			"add      new/",
+			"add      new/file.go",
+			"update   skip.log",
+			"delete   sub/deep/old.md",
+			"delete   old.log",
+			"delete   old/gone.txt",
+			"delete   old/",
+			"delete   keep/extra.go",
+			"delete   cache/y.bin",
+		}},
+		{"delete and filter", SyncOptions{Delete: true, Filters: []Filter{MatchGlob("*.go")}}, []string{
+			"add      new/",
+			"add      new/file.go",
+			"delete   keep/extra.go",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, dst := setup(t)
+
+			dry := tt.opts
+			dry.DryRun = true
+			before := treeState(t, dst)
+			plan, err := Sync(src, dst, &dry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := syncOps(plan); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dry run plan =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if !reflect.DeepEqual(treeState(t, dst), before) {
+				t.Errorf("dry run changed the destination")
+			}
+
+			// conflicts are reported, and leave the directory alone
+			var conflicts []SyncOp
+			for _, op := range plan {
+				if op.Action == SyncConflict {
+					conflicts = append(conflicts, op)
+				}
+			}
+			done, err := Sync(src, dst, &tt.opts)
+			if len(conflicts) > 0 {
+				if !errors.Is(err, ErrIsDir) {
+					t.Errorf("Sync() error = %v, want %v", err, ErrIsDir)
+				}
+				if !exists(filepath.Join(dst, "dir2file", "x")) {
+					t.Errorf("Sync() deleted a directory in conflict")
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(done, plan) {
+				t.Errorf("Sync() = %v, want the dry run plan %v", syncOps(done), syncOps(plan))
+			}
+
+			// a second sync has nothing to do but the conflicts
+			again, _ := Sync(src, dst, &tt.opts)
+			if !reflect.DeepEqual(syncOps(again), syncOps(conflicts)) {
+				t.Errorf("second Sync() = %v, want %v", syncOps(again), syncOps(conflicts))
+			}
+		})
+	}
+}
+
+func TestSyncMirror(t *testing.T) {
+	src := t.TempDir()
+	archiveTree(t, src)
+	dst := filepath.Join(t.TempDir(), "new", "backup")
+
+	if _, err := Sync(src, dst, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// directory times are not synced
+	want, got := treeState(t, src), treeState(t, dst)
+	for _, state := range []map[string]string{want, got} {
+		for rel, s := range state {
+			if strings.HasPrefix(s, "d") {
+				state[rel] = s[:10]
+			}
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("synced tree differs:\ngot  %v\nwant %v", got, want)
+	}
+}
+
+func TestSyncErrors(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{"file": "x", "src/a": "a"})
+
+	tests := []struct {
+		name     string
+		src, dst string
+		opts     *SyncOptions
+		wantErr  error
+	}{
+		{"missing source", "missing", "dst", nil, ErrNotExist},
+		{"file source", "file", "dst", nil, ErrNotDir},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Sync(filepath.Join(dir, tt.src), filepath.Join(dir, tt.dst), tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Sync() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := Sync(filepath.Join(dir, "src"), filepath.Join(dir, "src", "backup"), nil); err == nil {
+		t.Errorf("Sync() into its own source succeeded")
+	}
+	if _, err := Sync(filepath.Join(dir, "src"), filepath.Join(dir, "dst"), &SyncOptions{Checksum: "nope"}); err == nil {
+		t.Errorf("Sync() with an unknown checksum succeeded")
+	}
+}
+
+func TestWriteSyncPlan(t *testing.T) {
+	ops := []SyncOp{
+		{SyncAdd, "dir", TypeDir, 0},
+		{SyncAdd, "dir/f", TypeFile, 2048},
+		{SyncUpdate, "g", TypeFile, 1024},
+		{SyncDelete, "old", TypeFile, 0},
+		{SyncConflict, "dir", TypeFile, 0},
+	}
+	var buf bytes.Buffer
+	if err := WriteSyncPlan(&buf, ops); err != nil {
+		t.Fatal(err)
+	}
+	want := "add      dir/\nadd      dir/f\nupdate   g\ndelete   old\nconflict dir\n2 to add, 1 to update, 1 to delete, " + HumanSize(3072) + " to copy, 1 conflicts\n"
+	if buf.String() != want {
+		t.Errorf("WriteSyncPlan() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,264 @@
+package gofile
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// keepTempEnv turns on KeepOnFailure for every new TempScope when it is
+// set to a non-empty value, so temporary files can be inspected after a
+// failed run without changing any code.
+const keepTempEnv = "GOFILE_KEEP_TEMP"
+
+var errScopeClosed = errors.New("temp scope is closed")
+
+// TempScope owns a set of temporary files and directories and removes
+// them when it is closed. Open scopes are also cleaned up by CleanupTemp,
+// which runs on SIGINT and SIGTERM once CleanupOnSignal was called.
+//
+// A TempScope is safe for concurrent use.
+type TempScope struct {
+	// KeepOnFailure leaves the paths in place, and reports them to the
+	// package error handler (see SetErrorHandler), when the scope is
+	// closed after Fail or is interrupted by a signal. It defaults to
+	// true if GOFILE_KEEP_TEMP is set.
+	KeepOnFailure bool
+
+	mu     sync.Mutex
+	paths  []string
+	failed bool
+	closed bool
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[*TempScope]struct{})
+
+	// tempFiles holds the paths created by TempFile, TempDir and
+	// CreateAtomic. It is never closed.
+	tempFiles = newTempScope()
+
+	signalOnce sync.Once
+	exit       = os.Exit // guarded by registryMu
+)
+
+// NewTempScope returns an empty scope. Close it when the files are no
+// longer needed, usually with defer.
+func NewTempScope() *TempScope {
+	s := newTempScope()
+	registryMu.Lock()
+	registry[s] = struct{}{}
+	registryMu.Unlock()
+	return s
+}
+
+func newTempScope() *TempScope {
+	return &TempScope{KeepOnFailure: os.Getenv(keepTempEnv) != ""}
+}
+
+// TempScopeFor returns a scope that is closed when the test t finishes
+// and that counts as failed if t failed. t is usually a *testing.T.
+func TempScopeFor(t interface {
+	Cleanup(func())
+	Failed() bool
+}) *TempScope {
+	s := NewTempScope()
+	t.Cleanup(func() {
+		if t.Failed() {
+			s.Fail()
+		}
+		s.Close()
+	})
+	return s
+}
+
+// TempFile creates a new temporary file as ioutil.TempFile does and
+// adds it to the scope.
+func (s *TempScope) TempFile(dir, pattern string) (*os.File, error) {
+	f, err := ioutil.TempFile(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Track(f.Name()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+// TempDir creates a new temporary directory as ioutil.TempDir does and
+// adds it to the scope. The directory is removed with its contents.
+func (s *TempScope) TempDir(dir, pattern string) (string, error) {
+	name, err := ioutil.TempDir(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	if err := s.Track(name); err != nil {
+		os.Remove(name)
+		return "", err
+	}
+	return name, nil
+}
+
+// Track adds an existing path to the scope. It is removed, with its
+// contents if it is a directory, when the scope is closed.
+func (s *TempScope) Track(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return newPathError("track", path, errScopeClosed)
+	}
+	s.paths = append(s.paths, path)
+	return nil
+}
+
+// Forget removes path from the scope without deleting it, e.g. after
+// a temporary file was renamed into its final place.
+func (s *TempScope) Forget(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.paths) - 1; i >= 0; i-- {
+		if s.paths[i] == path {
+			s.paths = append(s.paths[:i], s.paths[i+1:]...)
+			return
+		}
+	}
+}
+
+// Paths returns the paths owned by the scope in the order they were added.
+func (s *TempScope) Paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.paths...)
+}
+
+// Fail marks the scope as failed. With KeepOnFailure, Close then
+// leaves the paths in place.
+func (s *TempScope) Fail() {
+	s.mu.Lock()
+	s.failed = true
+	s.mu.Unlock()
+}
+
+// Close removes the scope's paths, newest first, and returns the first
+// error. Closing a closed scope is a no-op.
+func (s *TempScope) Close() error {
+	registryMu.Lock()
+	delete(registry, s)
+	registryMu.Unlock()
+
+	return s.cleanup(true)
+}
+
+// cleanup removes or, if the scope failed and KeepOnFailure is set,
+// reports and forgets the scope's paths. If closing, the scope is closed.
+func (s *TempScope) cleanup(closing bool) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = closing
+	paths := s.paths
+	s.paths = nil
+	keep := s.KeepOnFailure && s.failed
+	s.mu.Unlock()
+
+	if keep {
+		if len(paths) > 0 {
+			Err(fmt.Errorf("keeping temporary files after failure: %s", strings.Join(paths, ", ")))
+		}
+		return nil
+	}
+
+	var first error
+	for i := len(paths) - 1; i >= 0; i-- {
+		if err := os.RemoveAll(paths[i]); err != nil && first == nil {
+			first = newPathError("remove", paths[i], err)
+		}
+	}
+	return first
+}
+
+// TempFile creates a new temporary file as ioutil.TempFile does. It is
+// removed by CleanupTemp, or when the process is interrupted if
+// CleanupOnSignal was called.
+func TempFile(dir, pattern string) (*os.File, error) {
+	return tempFiles.TempFile(dir, pattern)
+}
+
+// TempDir creates a new temporary directory as ioutil.TempDir does. It
+// is removed by CleanupTemp, or when the process is interrupted if
+// CleanupOnSignal was called.
+func TempDir(dir, pattern string) (string, error) {
+	return tempFiles.TempDir(dir, pattern)
+}
+
+// CleanupTemp removes the paths created by TempFile and TempDir, the
+// temporary files of unfinished AtomicFiles and those of all open
+// scopes, which are closed. It returns the first error.
+//
+// Go does not run deferred calls when os.Exit is called, so programs
+// should call CleanupTemp before exiting, e.g. with defer in main.
+func CleanupTemp() error {
+	return cleanupAll(false)
+}
+
+// cleanupAll cleans up every scope, marking them failed first if
+// interrupted is set.
+func cleanupAll(interrupted bool) error {
+	registryMu.Lock()
+	scopes := make([]*TempScope, 0, len(registry)+1)
+	for s := range registry {
+		scopes = append(scopes, s)
+	}
+	registry = make(map[*TempScope]struct{})
+	registryMu.Unlock()
+
+	var first error
+	for _, s := range append(scopes, tempFiles) {
+		if interrupted {
+			s.Fail()
+		}
+		if err := s.cleanup(s != tempFiles); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// CleanupOnSignal installs a handler that calls CleanupTemp when the
+// process receives SIGINT or SIGTERM and then exits with status 128
+// plus the signal number, as a shell would report it. Open scopes count
+// as failed, so KeepOnFailure applies.
+//
+// The package never installs the handler itself, since it takes over
+// the shutdown of the program: call it early in main. Calling it again
+// has no effect. Programs that handle these signals themselves should
+// call CleanupTemp from their own handler instead.
+func CleanupOnSignal() {
+	signalOnce.Do(func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			for sig := range c {
+				cleanupAll(true)
+				code := 1
+				if n, ok := sig.(syscall.Signal); ok {
+					code = 128 + int(n)
+				}
+				registryMu.Lock()
+				fn := exit
+				registryMu.Unlock()
+				fn(code)
+			}
+		}()
+	})
+}
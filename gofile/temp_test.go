@@ -0,0 +1,238 @@
+package gofile
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+func TestTempScope(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		keep     bool
+		fail     bool
+		wantGone bool
+	}{
+		{"success", false, false, true},
+		{"failure", false, true, true},
+		{"keep on success", true, false, true},
+		{"keep on failure", true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewTempScope()
+			s.KeepOnFailure = tt.keep
+
+			f, err := s.TempFile(dir, "file-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+
+			d, err := s.TempDir(dir, "dir-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(d, "inner"), []byte("x"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.fail {
+				s.Fail()
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, p := range []string{f.Name(), d} {
+				if gone := !exists(p); gone != tt.wantGone {
+					t.Errorf("%s removed = %v, want %v", p, gone, tt.wantGone)
+				}
+			}
+		})
+	}
+}
+
+func TestTempScopeForget(t *testing.T) {
+	s := NewTempScope()
+
+	f, err := s.TempFile(t.TempDir(), "file-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	s.Forget(f.Name())
+	if got := s.Paths(); len(got) != 0 {
+		t.Errorf("Paths() = %v after Forget", got)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(f.Name()) {
+		t.Errorf("forgotten file was removed")
+	}
+}
+
+func TestTempScopeClosed(t *testing.T) {
+	s := NewTempScope()
+	s.Close()
+
+	if _, err := s.TempDir(t.TempDir(), "dir-"); !errors.Is(err, errScopeClosed) {
+		t.Errorf("TempDir on closed scope: error = %v, want errScopeClosed", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+}
+
+type fakeT struct {
+	cleanups []func()
+	failed   bool
+}
+
+func (t *fakeT) Cleanup(fn func()) { t.cleanups = append(t.cleanups, fn) }
+func (t *fakeT) Failed() bool      { return t.failed }
+
+func (t *fakeT) finish() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func TestTempScopeFor(t *testing.T) {
+	dir := t.TempDir()
+	c := &ErrorCollector{}
+	useHandler(t, c)
+
+	for _, failed := range []bool{false, true} {
+		c.Reset()
+		ft := &fakeT{failed: failed}
+		s := TempScopeFor(ft)
+		s.KeepOnFailure = true
+
+		d, err := s.TempDir(dir, "dir-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ft.finish()
+
+		if exists(d) != failed {
+			t.Errorf("failed=%v: directory kept = %v", failed, exists(d))
+		}
+
+		// kept paths are reported to the error handler
+		errs := c.Errors()
+		if failed && (len(errs) != 1 || !strings.Contains(errs[0].Error(), d)) {
+			t.Errorf("failed=%v: reported %v, want %s", failed, errs, d)
+		}
+		if !failed && len(errs) > 0 {
+			t.Errorf("failed=%v: reported %v", failed, errs)
+		}
+	}
+}
+
+func TestCleanupTemp(t *testing.T) {
+	dir := t.TempDir()
+
+	f, err := TempFile(dir, "file-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	a, err := CreateAtomic(filepath.Join(dir, "target"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.File.Close()
+
+	s := NewTempScope()
+	d, err := s.TempDir(dir, "dir-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CleanupTemp(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{f.Name(), a.File.Name(), d} {
+		if exists(p) {
+			t.Errorf("%s was not removed", p)
+		}
+	}
+	if _, err := s.TempFile(dir, "file-"); !errors.Is(err, errScopeClosed) {
+		t.Errorf("scope still open after CleanupTemp")
+	}
+}
+
+func TestAtomicFileForgotten(t *testing.T) {
+	a, err := CreateAtomic(filepath.Join(t.TempDir(), "target"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp := a.File.Name()
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range tempFiles.Paths() {
+		if p == tmp {
+			t.Errorf("%s still tracked after Close", tmp)
+		}
+	}
+}
+
+func TestCleanupOnSignal(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		t.Skip("signals cannot be sent to self on " + runtime.GOOS)
+	}
+
+	codes := make(chan int, 1)
+	registryMu.Lock()
+	exit = func(code int) { codes <- code }
+	registryMu.Unlock()
+	defer func() {
+		registryMu.Lock()
+		exit = os.Exit
+		registryMu.Unlock()
+	}()
+
+	CleanupOnSignal()
+	d, err := TempDir(t.TempDir(), "dir-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case code := <-codes:
+		if want := 128 + int(syscall.SIGTERM); code != want {
+			t.Errorf("exit code = %d, want %d", code, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("signal handler did not exit")
+	}
+	if exists(d) {
+		t.Errorf("%s was not removed on SIGTERM", d)
+	}
+}
@@ -0,0 +1,322 @@
+package gofile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// trashInfoExt is the extension of the metadata files in Trash/info.
+const trashInfoExt = ".trashinfo"
+
+// trashDateLayout is the DeletionDate format of the trash spec, in
+// local time.
+const trashDateLayout = "2006-01-02T15:04:05"
+
+// TrashItem is a file or directory in the trash.
+type TrashItem struct {
+	// Name is the name in the trash, as passed to RestoreTrash.
+	Name string `json:"name"`
+
+	// Path is the absolute path the item was trashed from.
+	Path string `json:"path"`
+
+	// Deleted is when the item was trashed.
+	Deleted time.Time `json:"deleted"`
+}
+
+// TrashDir returns the home trash directory of the freedesktop.org
+// trash spec: $XDG_DATA_HOME/Trash, or ~/.local/share/Trash if
+// XDG_DATA_HOME is not set to an absolute path.
+func TrashDir() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if !filepath.IsAbs(dir) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "Trash"), nil
+}
+
+// Trash moves the named file or directory into the home trash, where
+// file managers can show and restore it, and returns its name in the
+// trash. The original path and time are recorded in a .trashinfo file
+// next to it, as the freedesktop.org trash spec describes.
+//
+// Files on another file system than the trash are copied into it and
+// then removed; per-volume trash directories are not used.
+func Trash(name string) (string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", newPathError("trash", name, err)
+	}
+	if _, err := os.Lstat(abs); err != nil {
+		return "", newPathError("trash", name, err)
+	}
+
+	trash, err := TrashDir()
+	if err != nil {
+		return "", newPathError("trash", name, err)
+	}
+	if abs == trash || strings.HasPrefix(trash, abs+string(filepath.Separator)) {
+		return "", newPathError("trash", name, fmt.Errorf("trash directory %s is inside it", trash))
+	}
+
+	files, info := filepath.Join(trash, "files"), filepath.Join(trash, "info")
+	for _, dir := range []string{files, info} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", newPathError("trash", dir, err)
+		}
+	}
+
+	// the info file is created first and exclusively, which
+	// claims the name in files for this call
+	item, infoFile, err := createTrashInfo(info, files, filepath.Base(abs))
+	if err != nil {
+		return "", newPathError("trash", name, err)
+	}
+	_, err = fmt.Fprintf(infoFile, "[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: abs}).EscapedPath(), time.Now().Format(trashDateLayout))
+	if cerr := infoFile.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(infoFile.Name())
+		return "", newPathError("trash", name, err)
+	}
+
+	// Move removes a partial copy if it fails
+	if err := Move(abs, filepath.Join(files, item), nil); err != nil {
+		os.Remove(infoFile.Name())
+		return "", err
+	}
+	return item, nil
+}
+
+// createTrashInfo creates the info file for the first free name based
+// on base, "name", "name.2.ext", "name.3.ext" and so on.
+func createTrashInfo(info, files, base string) (string, *os.File, error) {
+	stem, ext := splitExt(base)
+
+	for n := 1; n <= maxCollisionAttempts; n++ {
+		name := base
+		if n > 1 {
+			name = fmt.Sprintf("%s.%d%s", stem, n, ext)
+		}
+		if _, err := os.Lstat(filepath.Join(files, name)); err == nil {
+			// left behind without its info file
+			continue
+		}
+		f, err := os.OpenFile(filepath.Join(info, name+trashInfoExt), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		return name, f, nil
+	}
+	return "", nil, fmt.Errorf("no free name in the trash after %d attempts: %w", maxCollisionAttempts, ErrExists)
+}
+
+// ListTrash returns the items in the home trash, oldest first. Info
+// files that cannot be parsed, or whose item is missing, are skipped.
+func ListTrash() ([]TrashItem, error) {
+	trash, err := TrashDir()
+	if err != nil {
+		return nil, err
+	}
+	info := filepath.Join(trash, "info")
+	entries, err := os.ReadDir(info)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, newPathError("trash", info, err)
+	}
+
+	var items []TrashItem
+	for _, de := range entries {
+		if !strings.HasSuffix(de.Name(), trashInfoExt) {
+			continue
+		}
+		item, err := readTrashInfo(filepath.Join(info, de.Name()))
+		if err != nil {
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(trash, "files", item.Name)); err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Deleted.Before(items[j].Deleted) })
+	return items, nil
+}
+
+// readTrashInfo parses the .trashinfo file name.
+func readTrashInfo(name string) (TrashItem, error) {
+	item := TrashItem{Name: strings.TrimSuffix(filepath.Base(name), trashInfoExt)}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return item, err
+	}
+	defer f.Close()
+
+	var section string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "[") {
+			section = line
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if section != "[Trash Info]" || i < 0 {
+			continue
+		}
+		key, value := line[:i], line[i+1:]
+		switch key {
+		case "Path":
+			if item.Path, err = url.PathUnescape(value); err != nil {
+				return item, err
+			}
+		case "DeletionDate":
+			if item.Deleted, err = time.ParseInLocation(trashDateLayout, value, time.Local); err != nil {
+				return item, err
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return item, err
+	}
+	if item.Path == "" {
+		return item, errors.New("no Path in trash info")
+	}
+	return item, nil
+}
+
+// RestoreTrash moves the item with the given name in the home trash
+// back to where it was trashed from, creating missing parent
+// directories. It returns the restored path. An existing file at that
+// path is not replaced; the error wraps ErrExists.
+func RestoreTrash(name string) (string, error) {
+	trash, err := TrashDir()
+	if err != nil {
+		return "", err
+	}
+	if name == "" || filepath.Base(name) != name {
+		return "", newPathError("restore", name, ErrNotExist)
+	}
+
+	infoName := filepath.Join(trash, "info", name+trashInfoExt)
+	item, err := readTrashInfo(infoName)
+	if err != nil {
+		return "", newPathError("restore", name, err)
+	}
+	path := filepath.FromSlash(item.Path)
+	if !filepath.IsAbs(path) {
+		// relative paths are relative to the trash's top directory,
+		// which for the home trash is its parent
+		path = filepath.Join(filepath.Dir(trash), path)
+	}
+
+	src := filepath.Join(trash, "files", name)
+	if _, err := os.Lstat(src); err != nil {
+		return "", newPathError("restore", name, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return "", newPathError("restore", path, err)
+	}
+	if err := Move(src, path, nil); err != nil {
+		return "", err
+	}
+	if err := os.Remove(infoName); err != nil {
+		return path, newPathError("restore", infoName, err)
+	}
+	return path, nil
+}
+
+// EmptyTrash permanently removes the items in the home trash that
+// were trashed before the given time, or all items if before is zero.
+// Files left in the trash without an info file, such as by an
+// interrupted Trash, are always removed. It returns the number of
+// items removed.
+func EmptyTrash(before time.Time) (int, error) {
+	trash, err := TrashDir()
+	if err != nil {
+		return 0, err
+	}
+	info := filepath.Join(trash, "info")
+	entries, err := os.ReadDir(info)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, newPathError("trash", info, err)
+	}
+
+	var n int
+	for _, de := range entries {
+		if !strings.HasSuffix(de.Name(), trashInfoExt) {
+			continue
+		}
+		infoName := filepath.Join(info, de.Name())
+		item, err := readTrashInfo(infoName)
+		if !before.IsZero() && (err != nil || !item.Deleted.Before(before)) {
+			continue
+		}
+
+		// the item goes first, so a failure leaves it listed
+		file := filepath.Join(trash, "files", strings.TrimSuffix(de.Name(), trashInfoExt))
+		if err := removeAllWritable(file); err != nil {
+			return n, newPathError("trash", file, err)
+		}
+		if err := os.Remove(infoName); err != nil {
+			return n, newPathError("trash", infoName, err)
+		}
+		n++
+	}
+
+	files := filepath.Join(trash, "files")
+	entries, err = os.ReadDir(files)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return n, newPathError("trash", files, err)
+	}
+	for _, de := range entries {
+		if _, err := os.Lstat(filepath.Join(info, de.Name()+trashInfoExt)); err == nil {
+			continue
+		}
+		file := filepath.Join(files, de.Name())
+		if err := removeAllWritable(file); err != nil {
+			return n, newPathError("trash", file, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// removeAllWritable is os.RemoveAll, but makes read-only directories
+// writable first, so their contents can be removed.
+func removeAllWritable(name string) error {
+	err := os.RemoveAll(name)
+	if err == nil || !errors.Is(err, os.ErrPermission) {
+		return err
+	}
+	filepath.Walk(name, func(p string, fi os.FileInfo, err error) error {
+		if err == nil && fi.IsDir() {
+			os.Chmod(p, 0700)
+		}
+		return nil
+	})
+	return os.RemoveAll(name)
+}
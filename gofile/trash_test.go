@@ -0,0 +1,196 @@
+package gofile
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrashDir(t *testing.T) {
+	home := t.TempDir()
+	setenv(t, "HOME", home)
+
+	tests := []struct {
+		name string
+		xdg  string
+		want string
+	}{
+		{"xdg", "/data", filepath.Join("/data", "Trash")},
+		{"unset", "", filepath.Join(home, ".local", "share", "Trash")},
+		{"relative", "data", filepath.Join(home, ".local", "share", "Trash")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setenv(t, "XDG_DATA_HOME", tt.xdg)
+			got, err := TrashDir()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("TrashDir() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrash(t *testing.T) {
+	data := t.TempDir()
+	setenv(t, "XDG_DATA_HOME", data)
+	trash := filepath.Join(data, "Trash")
+
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{
+		"a/notes.txt":    "a",
+		"b/notes.txt":    "b",
+		"c/notes.txt":    "c",
+		"with space%.md": "escaped",
+		"sub/x/y":        "y",
+		".hidden":        "h",
+	})
+
+	var names []string
+	for _, rel := range []string{"a/notes.txt", "b/notes.txt", "c/notes.txt", "with space%.md", "sub", ".hidden"} {
+		name, err := Trash(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatalf("Trash(%s) error = %v", rel, err)
+		}
+		names = append(names, name)
+		if _, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(rel))); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Trash(%s) left the original: %v", rel, err)
+		}
+	}
+	want := []string{"notes.txt", "notes.2.txt", "notes.3.txt", "with space%.md", "sub", ".hidden"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Trash() names = %v, want %v", names, want)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(trash, "info", "with space%.md.trashinfo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantInfo := "[Trash Info]\nPath=" + filepath.ToSlash(dir) + "/with%20space%25.md\nDeletionDate="
+	if !strings.HasPrefix(string(b), wantInfo) {
+		t.Errorf("trashinfo =\n%s\nwant prefix\n%s", b, wantInfo)
+	}
+	if got := readGen(t, filepath.Join(trash, "files", "notes.2.txt")); got != "b" {
+		t.Errorf("trashed notes.2.txt = %q, want %q", got, "b")
+	}
+
+	items, err := ListTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != len(want) {
+		t.Fatalf("ListTrash() = %v, want %d items", items, len(want))
+	}
+	for _, item := range items {
+		if time.Since(item.Deleted) > time.Minute {
+			t.Errorf("ListTrash() %s deleted at %v", item.Name, item.Deleted)
+		}
+		if item.Name == "with space%.md" && item.Path != filepath.Join(dir, "with space%.md") {
+			t.Errorf("ListTrash() %s path = %q", item.Name, item.Path)
+		}
+	}
+
+	// restore into a missing parent, and over an existing file
+	if err := os.RemoveAll(filepath.Join(dir, "b")); err != nil {
+		t.Fatal(err)
+	}
+	path, err := RestoreTrash("notes.2.txt")
+	if err != nil {
+		t.Fatalf("RestoreTrash() error = %v", err)
+	}
+	if path != filepath.Join(dir, "b", "notes.txt") || readGen(t, path) != "b" {
+		t.Errorf("RestoreTrash() = %q", path)
+	}
+	if _, err := RestoreTrash("sub"); err != nil {
+		t.Fatalf("RestoreTrash(sub) error = %v", err)
+	}
+	if got := readGen(t, filepath.Join(dir, "sub", "x", "y")); got != "y" {
+		t.Errorf("restored sub/x/y = %q", got)
+	}
+	makeTree(t, dir, map[string]string{"a/notes.txt": "new"})
+	if _, err := RestoreTrash("notes.txt"); !errors.Is(err, ErrExists) {
+		t.Errorf("RestoreTrash() over a file error = %v, want %v", err, ErrExists)
+	}
+	if got := readGen(t, filepath.Join(dir, "a", "notes.txt")); got != "new" {
+		t.Errorf("RestoreTrash() replaced a file: %q", got)
+	}
+	for _, name := range []string{"missing", "../info", ""} {
+		if _, err := RestoreTrash(name); err == nil {
+			t.Errorf("RestoreTrash(%q) succeeded", name)
+		}
+	}
+
+	// nothing was trashed before an hour ago, but a file without an
+	// info file is swept, and its name is free again
+	makeTree(t, trash, map[string]string{"files/orphan/x": "partial copy"})
+	if n, err := EmptyTrash(time.Now().Add(-time.Hour)); err != nil || n != 1 {
+		t.Errorf("EmptyTrash(an hour ago) = %d, %v, want 1", n, err)
+	}
+	makeTree(t, dir, map[string]string{"orphan": "o"})
+	if name, err := Trash(filepath.Join(dir, "orphan")); err != nil || name != "orphan" {
+		t.Errorf("Trash(orphan) = %q, %v", name, err)
+	}
+	if n, err := EmptyTrash(time.Time{}); err != nil || n != 5 {
+		t.Errorf("EmptyTrash() = %d, %v, want 5", n, err)
+	}
+	for _, sub := range []string{"files", "info"} {
+		entries, err := os.ReadDir(filepath.Join(trash, sub))
+		if err != nil || len(entries) > 0 {
+			t.Errorf("EmptyTrash() left %s: %v %v", sub, entries, err)
+		}
+	}
+	if items, err := ListTrash(); err != nil || len(items) > 0 {
+		t.Errorf("ListTrash() after EmptyTrash() = %v, %v", items, err)
+	}
+}
+
+func TestTrashErrors(t *testing.T) {
+	data := t.TempDir()
+	setenv(t, "XDG_DATA_HOME", data)
+
+	if _, err := Trash(filepath.Join(data, "missing")); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Trash(missing) error = %v, want %v", err, ErrNotExist)
+	}
+	for _, name := range []string{data, filepath.Join(data, "Trash")} {
+		if err := os.MkdirAll(name, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Trash(name); err == nil {
+			t.Errorf("Trash(%s) containing the trash succeeded", name)
+		}
+	}
+
+	// an empty trash lists and empties nothing
+	if items, err := ListTrash(); err != nil || len(items) > 0 {
+		t.Errorf("ListTrash() = %v, %v", items, err)
+	}
+	if n, err := EmptyTrash(time.Time{}); err != nil || n != 0 {
+		t.Errorf("EmptyTrash() = %d, %v", n, err)
+	}
+}
+
+func TestDedupeTrash(t *testing.T) {
+	setenv(t, "XDG_DATA_HOME", t.TempDir())
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{"a": "same", "b": "same"})
+
+	g := DupeGroup{Size: 4, Paths: []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}}
+	changed, err := Dedupe(g, DedupeTrash, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 1 || exists(changed[0]) {
+		t.Errorf("Dedupe() changed %v", changed)
+	}
+	items, err := ListTrash()
+	if err != nil || len(items) != 1 || items[0].Path != g.Paths[1] {
+		t.Errorf("ListTrash() = %v, %v", items, err)
+	}
+}
@@ -0,0 +1,442 @@
+package gofile
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// SkipDir may be returned by a WalkFunc to skip the directory named
+// in the call, or the remaining entries of the current directory if
+// the entry is not a directory. It is the same value as filepath.SkipDir.
+var SkipDir = filepath.SkipDir
+
+// Entry describes a file found by Walk.
+type Entry struct {
+	// Path is the path of the file, joined from the root given to Walk.
+	Path string
+
+	// Rel is the slash separated path relative to the root.
+	// The root itself is ".".
+	Rel string
+
+	// Info is the result of os.Lstat on Path.
+	Info os.FileInfo
+
+	// Depth is the number of directories between the root and the
+	// entry. The root has depth 0, its children depth 1.
+	Depth int
+
+	real   string       // path with symlinks evaluated, used for loop detection
+	follow bool         // symlink that resolves to a directory
+	ignore *ignoreRules // .gitignore rules in effect for this directory
+}
+
+// IsDir reports whether the entry is a directory, or a symlink to a
+// directory that Walk is following.
+func (e Entry) IsDir() bool { return e.Info.IsDir() || e.follow }
+
+// WalkFunc is called by Walk for every entry that passes the filters.
+// If it returns SkipDir, the entry (or the rest of its directory) is
+// skipped. Any other non-nil error stops the walk and is returned by Walk.
+type WalkFunc func(e Entry) error
+
+// EntryType is a bit set of file types used by OfType.
+type EntryType int
+
+const (
+	TypeFile EntryType = 1 << iota
+	TypeDir
+	TypeSymlink
+	TypeOther // devices, pipes, sockets, ...
+)
+
+// Type returns the EntryType of the entry.
+func (e Entry) Type() EntryType {
+	m := e.Info.Mode()
+	switch {
+	case m.IsRegular():
+		return TypeFile
+	case m.IsDir():
+		return TypeDir
+	case m&os.ModeSymlink != 0:
+		return TypeSymlink
+	}
+	return TypeOther
+}
+
+// Filter reports whether an entry should be passed to the WalkFunc.
+// Filters never prevent Walk from descending into a directory.
+type Filter func(e Entry) bool
+
+// MatchGlob returns a Filter that matches entries whose base name
+// matches the shell pattern. If the pattern contains a slash it is
+// matched against the entry's Rel path instead.
+func MatchGlob(pattern string) Filter {
+	return func(e Entry) bool {
+		name := path.Base(e.Rel)
+		if strings.Contains(pattern, "/") {
+			name = e.Rel
+		}
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}
+}
+
+// MatchRegexp returns a Filter that matches entries whose Rel path
+// matches re.
+func MatchRegexp(re *regexp.Regexp) Filter {
+	return func(e Entry) bool { return re.MatchString(e.Rel) }
+}
+
+// SizeBetween returns a Filter that matches entries with
+// min <= size <= max. A max of 0 or less means no upper limit.
+func SizeBetween(min, max int64) Filter {
+	return func(e Entry) bool {
+		size := e.Info.Size()
+		return size >= min && (max <= 0 || size <= max)
+	}
+}
+
+// ModifiedBetween returns a Filter that matches entries modified
+// after 'after' and before 'before'. A zero time is not checked.
+func ModifiedBetween(after, before time.Time) Filter {
+	return func(e Entry) bool {
+		t := e.Info.ModTime()
+		return (after.IsZero() || t.After(after)) && (before.IsZero() || t.Before(before))
+	}
+}
+
+// OfType returns a Filter that matches entries of any of the given types.
+func OfType(types EntryType) Filter {
+	return func(e Entry) bool { return e.Type()&types != 0 }
+}
+
+// WalkOptions configures Walk. The zero value walks the whole tree with
+// runtime.NumCPU() workers, does not follow symlinks, and stops at the
+// first error.
+type WalkOptions struct {
+	// Workers is the maximum number of directories read concurrently.
+	// At most Workers subdirectories of each directory being walked
+	// are read ahead of fn.
+	Workers int
+
+	// MaxDepth stops the walk from descending below the given depth.
+	// 0 means no limit.
+	MaxDepth int
+
+	// FollowSymlinks descends into symlinks that point to directories.
+	// Symlink loops are detected and reported to OnError as ErrSymlinkLoop.
+	FollowSymlinks bool
+
+	// Gitignore skips files matched by .gitignore files found in the
+	// tree, as well as .git directories.
+	Gitignore bool
+
+	// Filters must all match for an entry to be passed to the WalkFunc.
+	Filters []Filter
+
+	// OnError is called with errors reading a directory. If it returns
+	// nil the walk continues; otherwise the error stops the walk.
+	// If OnError is nil, the first error stops the walk.
+	OnError func(path string, err error) error
+
+	// FS is the file system to walk. If nil, the file system set with
+	// SetFS is used.
+	FS FS
+}
+
+// Walk walks the file tree rooted at root, calling fn for each entry,
+// including root, that passes opts.Filters.
+//
+// Directories are read concurrently by up to opts.Workers goroutines,
+// but fn is always called from a single goroutine and in lexical order,
+// so the output is the same as a sequential walk. opts may be nil.
+func Walk(root string, opts *WalkOptions, fn WalkFunc) error {
+	w := newWalker(opts)
+	defer close(w.done)
+
+	info, err := w.fsys.Lstat(root)
+	if err != nil {
+		return newPathError("walk", root, err)
+	}
+
+	e := Entry{Path: root, Rel: ".", Info: info}
+	if e.real, err = realPath(w.fsys, root); err != nil {
+		return newPathError("walk", root, err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		// an explicit root is always followed
+		if fi, err := w.fsys.Stat(root); err == nil && fi.IsDir() {
+			e.follow = true
+		}
+	}
+	if w.opts.Gitignore && e.IsDir() {
+		e.ignore = loadIgnore(w.fsys, nil, root, ".")
+	}
+
+	err = w.emit(e, fn)
+	if err == SkipDir || !e.IsDir() {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	w.ancestors[e.real] = true
+	err = w.walkDir(e, w.list(e), fn)
+	if err == SkipDir {
+		return nil
+	}
+	return err
+}
+
+// WalkChan is like Walk but streams entries over a channel. The error
+// channel receives at most one error and is closed, along with the
+// entry channel, when the walk is finished or ctx is cancelled.
+func WalkChan(ctx context.Context, root string, opts *WalkOptions) (<-chan Entry, <-chan error) {
+	out := make(chan Entry)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(out)
+
+		err := Walk(root, opts, func(e Entry) error {
+			select {
+			case out <- e:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errc <- err
+		}
+	}()
+
+	return out, errc
+}
+
+// errWalkStopped is reported by listings that were abandoned
+// because the walk ended.
+var errWalkStopped = errors.New("walk stopped")
+
+type walker struct {
+	opts      WalkOptions
+	fsys      FS
+	sem       chan struct{}
+	done      chan struct{}
+	ancestors map[string]bool // real paths of the directories being walked
+}
+
+func newWalker(opts *WalkOptions) *walker {
+	w := &walker{
+		done:      make(chan struct{}),
+		ancestors: make(map[string]bool),
+	}
+	if opts != nil {
+		w.opts = *opts
+	}
+	w.opts.Workers = workerCount(opts)
+	w.fsys = w.opts.FS
+	if w.fsys == nil {
+		w.fsys = CurrentFS()
+	}
+	w.sem = make(chan struct{}, w.opts.Workers)
+	return w
+}
+
+// workerCount returns opts.Workers, or runtime.NumCPU() if it is not set.
+func workerCount(opts *WalkOptions) int {
+	if opts == nil || opts.Workers < 1 {
+		return runtime.NumCPU()
+	}
+	return opts.Workers
+}
+
+// listing is the pending result of reading a directory.
+type listing struct {
+	ready   chan struct{}
+	entries []Entry
+	err     error
+}
+
+// list reads dir in the background, bounded by the worker semaphore.
+func (w *walker) list(dir Entry) *listing {
+	l := &listing{ready: make(chan struct{})}
+	go func() {
+		defer close(l.ready)
+		select {
+		case w.sem <- struct{}{}:
+		case <-w.done:
+			l.err = errWalkStopped
+			return
+		}
+		defer func() { <-w.sem }()
+		l.entries, l.err = w.readDir(dir)
+	}()
+	return l
+}
+
+// readDir returns the entries of dir in lexical order.
+func (w *walker) readDir(dir Entry) ([]Entry, error) {
+	des, err := w.fsys.ReadDir(dir.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(des))
+	for _, de := range des {
+		name := de.Name()
+		rel := path.Join(dir.Rel, name)
+
+		if w.opts.Gitignore && (name == ".git" || dir.ignore.match(rel, de.IsDir())) {
+			continue
+		}
+
+		info, err := de.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue // removed since ReadDir
+			}
+			return nil, err
+		}
+
+		e := Entry{
+			Path:   filepath.Join(dir.Path, name),
+			Rel:    rel,
+			Info:   info,
+			Depth:  dir.Depth + 1,
+			real:   filepath.Join(dir.real, name),
+			ignore: dir.ignore,
+		}
+
+		if w.opts.FollowSymlinks && info.Mode()&os.ModeSymlink != 0 {
+			if fi, err := w.fsys.Stat(e.Path); err == nil && fi.IsDir() {
+				e.follow = true
+				if e.real, err = realPath(w.fsys, e.Path); err != nil {
+					e.follow = false
+				}
+			}
+		}
+
+		if w.opts.Gitignore && e.IsDir() {
+			e.ignore = loadIgnore(w.fsys, dir.ignore, e.Path, rel)
+		}
+
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// descend reports whether the walk should enter e.
+func (w *walker) descend(e Entry) bool {
+	if !e.IsDir() {
+		return false
+	}
+	if w.opts.MaxDepth > 0 && e.Depth >= w.opts.MaxDepth {
+		return false
+	}
+	return true
+}
+
+func (w *walker) walkDir(dir Entry, l *listing, fn WalkFunc) error {
+	<-l.ready
+	if l.err != nil {
+		return w.handleErr(dir.Path, l.err)
+	}
+
+	// the subdirectories to descend into
+	var dirs []int
+	for i, e := range l.entries {
+		if !w.descend(e) {
+			continue
+		}
+		if w.ancestors[e.real] {
+			if err := w.handleErr(e.Path, ErrSymlinkLoop); err != nil {
+				return err
+			}
+			continue
+		}
+		dirs = append(dirs, i)
+	}
+
+	// read up to Workers subdirectories ahead while the entries of
+	// this directory are handed to fn, so that wide directories do
+	// not hold every listing in memory at once
+	subs := make([]*listing, len(l.entries))
+	started := 0
+	prefetch := func(n int) {
+		for ; started < n && started < len(dirs); started++ {
+			i := dirs[started]
+			subs[i] = w.list(l.entries[i])
+		}
+	}
+	prefetch(w.opts.Workers)
+
+	reached := 0
+	for i, e := range l.entries {
+		sub := reached < len(dirs) && dirs[reached] == i
+		if sub {
+			reached++
+			prefetch(reached - 1 + w.opts.Workers)
+		}
+
+		err := w.emit(e, fn)
+		if err == SkipDir {
+			if e.IsDir() {
+				continue
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !sub {
+			continue
+		}
+
+		w.ancestors[e.real] = true
+		err = w.walkDir(e, subs[i], fn)
+		delete(w.ancestors, e.real)
+		subs[i] = nil
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emit passes e to fn if it matches all filters.
+func (w *walker) emit(e Entry, fn WalkFunc) error {
+	if !matchAll(w.opts.Filters, e) {
+		return nil
+	}
+	return fn(e)
+}
+
+// matchAll reports whether e matches all filters.
+func matchAll(filters []Filter, e Entry) bool {
+	for _, f := range filters {
+		if !f(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *walker) handleErr(path string, err error) error {
+	err = newPathError("walk", path, err)
+	if w.opts.OnError == nil {
+		return err
+	}
+	return w.opts.OnError(path, err)
+}
@@ -0,0 +1,174 @@
+package gofile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// makeTree creates the files in tree below dir. Names ending in a
+// slash are created as directories.
+func makeTree(t *testing.T, dir string, tree map[string]string) {
+	t.Helper()
+	for name, data := range tree {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(p, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func walkRel(t *testing.T, root string, opts *WalkOptions) []string {
+	t.Helper()
+	var got []string
+	err := Walk(root, opts, func(e Entry) error {
+		got = append(got, e.Rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+	return got
+}
+
+func TestWalk(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{
+		"a.go":           "package a",
+		"b.txt":          "hello world",
+		"sub/c.go":       "package c",
+		"sub/deep/d.log": "",
+		"sub/e.txt":      "e",
+		"vendor/f.go":    "package f",
+		"empty/":         "",
+		".gitignore":     "*.log\n/vendor/\n",
+		"sub/.gitignore": "e.txt\n",
+	})
+
+	tests := []struct {
+		name string
+		opts *WalkOptions
+		want string
+	}{
+		{"all", &WalkOptions{Workers: 4},
+			". .gitignore a.go b.txt empty sub sub/.gitignore sub/c.go sub/deep sub/deep/d.log sub/e.txt vendor vendor/f.go"},
+		{"glob", &WalkOptions{Filters: []Filter{MatchGlob("*.go")}},
+			"a.go sub/c.go vendor/f.go"},
+		{"regexp", &WalkOptions{Filters: []Filter{MatchRegexp(regexp.MustCompile(`^sub/.*\.txt$`))}},
+			"sub/e.txt"},
+		{"size", &WalkOptions{Filters: []Filter{OfType(TypeFile), SizeBetween(9, 10)}},
+			"a.go sub/c.go vendor/f.go"},
+		{"dirs", &WalkOptions{Filters: []Filter{OfType(TypeDir)}, MaxDepth: 1},
+			". empty sub vendor"},
+		{"gitignore", &WalkOptions{Gitignore: true, Filters: []Filter{OfType(TypeFile)}},
+			".gitignore a.go b.txt sub/.gitignore sub/c.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(walkRel(t, dir, tt.opts), " ")
+			if got != tt.want {
+				t.Errorf("Walk() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWalkSkipDir(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{"a/x": "", "b/y": "", "c": ""})
+
+	var got []string
+	err := Walk(dir, nil, func(e Entry) error {
+		got = append(got, e.Rel)
+		if e.Rel == "a" {
+			return SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ". a b b/y c"; strings.Join(got, " ") != want {
+		t.Errorf("Walk() = %v, want %v", strings.Join(got, " "), want)
+	}
+}
+
+// readDirCounter counts the directories read from an FS.
+type readDirCounter struct {
+	FS
+	n int32
+}
+
+func (c *readDirCounter) ReadDir(name string) ([]fs.DirEntry, error) {
+	atomic.AddInt32(&c.n, 1)
+	return c.FS.ReadDir(name)
+}
+
+func TestWalkReadAhead(t *testing.T) {
+	files := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		files[fmt.Sprintf("d%02d/f", i)] = "f"
+	}
+	fsys := &readDirCounter{FS: memTree(t, files)}
+
+	const workers = 2
+	var n int
+	err := Walk(".", &WalkOptions{FS: fsys, Workers: workers}, func(e Entry) error {
+		if e.Rel == "d00" {
+			// give prefetching goroutines time to run
+			time.Sleep(20 * time.Millisecond)
+			if got := atomic.LoadInt32(&fsys.n); got > 1+workers {
+				t.Errorf("%d directories read before the first was walked, want at most %d", got, 1+workers)
+			}
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 101 {
+		t.Errorf("Walk() visited %d entries, want 101", n)
+	}
+}
+
+func TestWalkSymlinkLoop(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{"a/b/file": ""})
+	if err := os.Symlink("..", filepath.Join(dir, "a", "b", "up")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	var loops int
+	opts := &WalkOptions{
+		FollowSymlinks: true,
+		OnError: func(path string, err error) error {
+			if errors.Is(err, ErrSymlinkLoop) {
+				loops++
+				return nil
+			}
+			return err
+		},
+	}
+	got := walkRel(t, dir, opts)
+	if loops != 1 {
+		t.Errorf("detected %d loops, want 1 (walked %v)", loops, got)
+	}
+}
@@ -0,0 +1,149 @@
+package gofile
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Op is a bit set of file system changes reported by a Watcher.
+type Op uint32
+
+const (
+	OpCreate Op = 1 << iota
+	OpWrite
+	OpRemove
+	OpRename // moved away from this path; the new path gets an OpCreate
+	OpChmod
+)
+
+func (op Op) String() string {
+	var names []string
+	for _, n := range []struct {
+		op   Op
+		name string
+	}{{OpCreate, "CREATE"}, {OpWrite, "WRITE"}, {OpRemove, "REMOVE"}, {OpRename, "RENAME"}, {OpChmod, "CHMOD"}} {
+		if op&n.op != 0 {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "0"
+	}
+	return strings.Join(names, "|")
+}
+
+// Event is a debounced change to a single path.
+type Event struct {
+	Path string
+	Op   Op
+}
+
+func (e Event) String() string { return e.Op.String() + " " + e.Path }
+
+// defaultDebounce is the quiet period used when WatchOptions.Debounce is 0.
+const defaultDebounce = 100 * time.Millisecond
+
+// defaultMaxWait is the number of debounce periods events are held back
+// at most when WatchOptions.MaxWait is 0.
+const defaultMaxWait = 10
+
+// WatchOptions configures a Watcher.
+type WatchOptions struct {
+	// Recursive watches all subdirectories of added directories,
+	// including ones created later.
+	Recursive bool
+
+	// Patterns limits events to paths whose base name matches one of
+	// the shell patterns, e.g. "*.json". Patterns containing a slash
+	// are matched against the whole path. No patterns matches all.
+	Patterns []string
+
+	// Debounce is how long the Watcher waits for events to stop
+	// arriving before it delivers them. Events for the same path within
+	// that period are coalesced into one.
+	Debounce time.Duration
+
+	// MaxWait is the longest the Watcher holds back events while
+	// changes keep arriving, so a file that is written continuously
+	// is still reported. 0 means 10 times Debounce.
+	MaxWait time.Duration
+}
+
+// match reports whether name passes the patterns.
+func (o *WatchOptions) match(name string) bool {
+	if len(o.Patterns) == 0 {
+		return true
+	}
+	for _, p := range o.Patterns {
+		s := filepath.Base(name)
+		if strings.Contains(p, "/") {
+			s = filepath.ToSlash(name)
+		}
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// coalescer merges bursts of raw events into one Event per path.
+type coalescer struct {
+	pending map[string]*pendingOp
+}
+
+type pendingOp struct {
+	op   Op
+	gone bool // removed or renamed away
+}
+
+func newCoalescer() *coalescer {
+	return &coalescer{pending: make(map[string]*pendingOp)}
+}
+
+// add records op for name. The rules keep the result meaningful for
+// someone who only sees the final state:
+//
+//   - a create followed by a remove or rename cancels out
+//   - a remove or rename followed by a create is a write (the file was replaced)
+//   - a remove or rename supersedes earlier writes
+func (c *coalescer) add(name string, op Op) {
+	p := c.pending[name]
+	if p == nil {
+		p = &pendingOp{}
+		c.pending[name] = p
+	}
+
+	switch {
+	case op&(OpRemove|OpRename) != 0:
+		if p.op&OpCreate != 0 {
+			delete(c.pending, name)
+			return
+		}
+		p.op = op & (OpRemove | OpRename)
+		p.gone = true
+	case op&OpCreate != 0 && p.gone:
+		p.op = OpWrite
+		p.gone = false
+	default:
+		p.op |= op
+	}
+}
+
+// flush returns the pending events sorted by path and resets c.
+func (c *coalescer) flush() []Event {
+	events := make([]Event, 0, len(c.pending))
+	for name, p := range c.pending {
+		op := p.op
+		if op&OpCreate != 0 {
+			op &^= OpWrite // a new file is written by definition
+		}
+		events = append(events, Event{Path: name, Op: op})
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+
+	c.pending = make(map[string]*pendingOp)
+	return events
+}
@@ -0,0 +1,378 @@
+package gofile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const watchMask = unix.IN_CREATE | unix.IN_MODIFY | unix.IN_ATTRIB |
+	unix.IN_DELETE | unix.IN_DELETE_SELF | unix.IN_MOVED_FROM |
+	unix.IN_MOVED_TO | unix.IN_MOVE_SELF
+
+// Watcher reports debounced changes to files and directories using
+// inotify. Events are delivered on Events in path order once no new
+// changes have arrived for the debounce period, or once the oldest
+// pending change has waited for MaxWait.
+type Watcher struct {
+	Events <-chan Event
+	Errors <-chan error
+
+	opts      WatchOptions
+	fd        int
+	file      *os.File // wraps fd so that Close unblocks Read
+	events    chan Event
+	errors    chan error
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+
+	mu    sync.Mutex
+	paths map[int]string  // watch descriptor to path
+	wds   map[string]int  // path to watch descriptor
+	dirs  map[string]bool // directories watched for all their entries
+	files map[string]bool // files watched through their directory
+	roots map[string]bool // directories passed to Add
+}
+
+// NewWatcher returns a Watcher with no watched paths. opts may be nil.
+func NewWatcher(opts *WatchOptions) (*Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify: %w", err)
+	}
+
+	w := &Watcher{
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan Event),
+		errors: make(chan error, 1),
+		done:   make(chan struct{}),
+		paths:  make(map[int]string),
+		wds:    make(map[string]int),
+		dirs:   make(map[string]bool),
+		files:  make(map[string]bool),
+		roots:  make(map[string]bool),
+	}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if w.opts.Debounce <= 0 {
+		w.opts.Debounce = defaultDebounce
+	}
+	if w.opts.MaxWait <= 0 {
+		w.opts.MaxWait = defaultMaxWait * w.opts.Debounce
+	}
+	w.Events, w.Errors = w.events, w.errors
+
+	raw := make(chan Event)
+	w.wg.Add(2)
+	go w.read(raw)
+	go w.debounce(raw)
+
+	return w, nil
+}
+
+// Add starts watching name. If name is a directory, changes to its
+// entries are reported, and with WatchOptions.Recursive those of all
+// its subdirectories as well.
+//
+// A file is watched through its directory, so the watch survives the
+// file being replaced, as atomic saves do by renaming a temporary file
+// over it. Such a save is reported as OpCreate.
+func (w *Watcher) Add(name string) error {
+	name = filepath.Clean(name)
+
+	fi, err := os.Stat(name)
+	if err != nil {
+		return newPathError("watch", name, err)
+	}
+	if !fi.IsDir() {
+		if err := w.addWatch(filepath.Dir(name), false); err != nil {
+			return err
+		}
+		w.mu.Lock()
+		w.files[name] = true
+		w.mu.Unlock()
+		return nil
+	}
+	w.mu.Lock()
+	w.roots[name] = true
+	w.mu.Unlock()
+	if !w.opts.Recursive {
+		return w.addWatch(name, true)
+	}
+
+	return Walk(name, &WalkOptions{Filters: []Filter{OfType(TypeDir)}, FS: OS}, func(e Entry) error {
+		return w.addWatch(e.Path, true)
+	})
+}
+
+// Remove stops watching name and, for recursive watches, everything below it.
+func (w *Watcher) Remove(name string) error {
+	name = filepath.Clean(name)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.files[name] {
+		delete(w.files, name)
+		w.unwatchUnused(filepath.Dir(name))
+		return nil
+	}
+
+	found := false
+	for p := range w.dirs {
+		if p == name || strings.HasPrefix(p, name+string(filepath.Separator)) {
+			found = true
+			delete(w.dirs, p)
+			delete(w.roots, p)
+			w.unwatchUnused(p)
+		}
+	}
+	if !found {
+		return newPathError("unwatch", name, errors.New("not watched"))
+	}
+	return nil
+}
+
+// unwatchUnused removes the watch on dir unless it is still needed for
+// all its entries or for a watched file in it. w.mu must be held.
+func (w *Watcher) unwatchUnused(dir string) {
+	if w.dirs[dir] {
+		return
+	}
+	for f := range w.files {
+		if filepath.Dir(f) == dir {
+			return
+		}
+	}
+	if wd, ok := w.wds[dir]; ok {
+		unix.InotifyRmWatch(w.fd, uint32(wd))
+		delete(w.wds, dir)
+		delete(w.paths, wd)
+	}
+}
+
+// unwatchTree removes the watches on dir and the directories below it.
+// w.mu must be held.
+func (w *Watcher) unwatchTree(dir string) {
+	for p, wd := range w.wds {
+		if p != dir && !strings.HasPrefix(p, dir+string(filepath.Separator)) {
+			continue
+		}
+		unix.InotifyRmWatch(w.fd, uint32(wd))
+		delete(w.wds, p)
+		delete(w.paths, wd)
+		delete(w.dirs, p)
+		delete(w.roots, p)
+	}
+}
+
+// Close stops the Watcher and closes the Events and Errors channels.
+// Pending events are discarded. Calls after the first return nil.
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		err = w.file.Close()
+		w.wg.Wait()
+		close(w.events)
+		close(w.errors)
+	})
+	return err
+}
+
+// addWatch watches the directory name, for all its entries if all is
+// set, or only for the files added to w.files.
+func (w *Watcher) addWatch(name string, all bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	wd, err := unix.InotifyAddWatch(w.fd, name, watchMask)
+	if err != nil {
+		return newPathError("watch", name, err)
+	}
+	w.paths[wd] = name
+	w.wds[name] = wd
+	if all {
+		w.dirs[name] = true
+	}
+	return nil
+}
+
+// read decodes inotify events and sends them, undebounced, to raw.
+func (w *Watcher) read(raw chan<- Event) {
+	defer w.wg.Done()
+	defer close(raw)
+
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.sendErr(err)
+			}
+			return
+		}
+
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameBytes := buf[off+unix.SizeofInotifyEvent : off+unix.SizeofInotifyEvent+int(ev.Len)]
+			off += unix.SizeofInotifyEvent + int(ev.Len)
+
+			for _, e := range w.translate(int(ev.Wd), ev.Mask, strings.TrimRight(string(nameBytes), "\x00")) {
+				select {
+				case raw <- e:
+				case <-w.done:
+					return
+				}
+			}
+		}
+	}
+}
+
+// translate turns one inotify event into zero or more Events and keeps
+// the watch table up to date.
+func (w *Watcher) translate(wd int, mask uint32, name string) []Event {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		w.sendErr(errors.New("inotify: event queue overflow, events were lost"))
+		return nil
+	}
+
+	p := ""
+	w.mu.Lock()
+	dir, ok := w.paths[wd]
+	if mask&unix.IN_IGNORED != 0 {
+		delete(w.paths, wd)
+		delete(w.wds, dir)
+		delete(w.dirs, dir)
+		delete(w.roots, dir)
+	}
+	if ok {
+		p = dir
+		if name != "" {
+			p = filepath.Join(dir, name)
+		}
+		// directories watched only for some files report just those
+		ok = w.dirs[dir] || w.files[p]
+	}
+	if mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF) != 0 && !w.roots[dir] {
+		// the watch on the parent reports it as one of its entries
+		ok = false
+	}
+	if ok && mask&unix.IN_MOVED_FROM != 0 && mask&unix.IN_ISDIR != 0 {
+		// a directory moved within the tree is watched again under
+		// its new name by IN_MOVED_TO
+		w.unwatchTree(p)
+	}
+	w.mu.Unlock()
+
+	if !ok || mask&unix.IN_IGNORED != 0 {
+		return nil
+	}
+
+	var op Op
+	switch {
+	case mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+		op = OpCreate
+	case mask&unix.IN_MODIFY != 0:
+		op = OpWrite
+	case mask&(unix.IN_DELETE|unix.IN_DELETE_SELF) != 0:
+		op = OpRemove
+	case mask&(unix.IN_MOVED_FROM|unix.IN_MOVE_SELF) != 0:
+		op = OpRename
+	case mask&unix.IN_ATTRIB != 0:
+		op = OpChmod
+	}
+	if op == 0 {
+		return nil
+	}
+
+	events := []Event{{Path: p, Op: op}}
+
+	// watch new directories and report what was created in them
+	// before the watch was in place
+	if op == OpCreate && mask&unix.IN_ISDIR != 0 && w.opts.Recursive {
+		err := Walk(p, &WalkOptions{FS: OS}, func(e Entry) error {
+			if e.Info.IsDir() {
+				if err := w.addWatch(e.Path, true); err != nil {
+					return err
+				}
+			}
+			if e.Path != p {
+				events = append(events, Event{Path: e.Path, Op: OpCreate})
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, ErrNotExist) {
+			w.sendErr(err)
+		}
+	}
+	return events
+}
+
+// debounce coalesces events from raw and delivers them once no new
+// events have arrived for the debounce period, or once the first of
+// them has waited for MaxWait.
+func (w *Watcher) debounce(raw <-chan Event) {
+	defer w.wg.Done()
+
+	c := newCoalescer()
+	timer := time.NewTimer(w.opts.Debounce)
+	timer.Stop()
+	var deadline time.Time // flush by then, while events are pending
+
+	for {
+		select {
+		case e, ok := <-raw:
+			if !ok {
+				return
+			}
+			if !w.opts.match(e.Path) {
+				continue
+			}
+			if len(c.pending) == 0 {
+				deadline = time.Now().Add(w.opts.MaxWait)
+			}
+			c.add(e.Path, e.Op)
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			wait := w.opts.Debounce
+			if left := time.Until(deadline); left < wait {
+				wait = left
+			}
+			timer.Reset(wait)
+
+		case <-timer.C:
+			for _, e := range c.flush() {
+				select {
+				case w.events <- e:
+				case <-w.done:
+					return
+				}
+			}
+
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *Watcher) sendErr(err error) {
+	select {
+	case w.errors <- err:
+	default:
+	}
+}
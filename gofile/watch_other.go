@@ -0,0 +1,24 @@
+//go:build !linux
+// +build !linux
+
+package gofile
+
+import "errors"
+
+var errWatchUnsupported = errors.New("gofile: Watcher is only supported on Linux")
+
+// Watcher reports debounced changes to files and directories.
+// It is only implemented on Linux.
+type Watcher struct {
+	Events <-chan Event
+	Errors <-chan error
+}
+
+// NewWatcher returns an error on this platform.
+func NewWatcher(opts *WatchOptions) (*Watcher, error) {
+	return nil, errWatchUnsupported
+}
+
+func (w *Watcher) Add(name string) error    { return errWatchUnsupported }
+func (w *Watcher) Remove(name string) error { return errWatchUnsupported }
+func (w *Watcher) Close() error             { return nil }
@@ -0,0 +1,253 @@
+package gofile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCoalescer(t *testing.T) {
+	type raw struct {
+		name string
+		op   Op
+	}
+	tests := []struct {
+		name string
+		raw  []raw
+		want []Event
+	}{
+		{"create and write", []raw{{"a", OpCreate}, {"a", OpWrite}, {"a", OpWrite}}, []Event{{"a", OpCreate}}},
+		{"writes", []raw{{"a", OpWrite}, {"a", OpChmod}, {"b", OpWrite}}, []Event{{"a", OpWrite | OpChmod}, {"b", OpWrite}}},
+		{"transient file", []raw{{"tmp", OpCreate}, {"tmp", OpWrite}, {"tmp", OpRename}}, nil},
+		{"replaced", []raw{{"a", OpRename}, {"a", OpCreate}}, []Event{{"a", OpWrite}}},
+		{"removed", []raw{{"a", OpWrite}, {"a", OpRemove}}, []Event{{"a", OpRemove}}},
+		{"atomic save", []raw{{"a.tmp", OpCreate}, {"a.tmp", OpWrite}, {"a.tmp", OpRename}, {"a", OpCreate}}, []Event{{"a", OpCreate}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCoalescer()
+			for _, r := range tt.raw {
+				c.add(r.name, r.op)
+			}
+			got := c.flush()
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flush() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWatcher(&WatchOptions{Recursive: true, Patterns: []string{"*.json"}, Debounce: 50 * time.Millisecond})
+	if err != nil {
+		t.Skip("watcher not available:", err)
+	}
+	defer w.Close()
+
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(sub, "config.json")
+	for i := 0; i < 3; i++ {
+		if err := WriteFileAtomic(name, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(sub, "ignored.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-w.Events:
+		if want := (Event{Path: name, Op: OpCreate}); e != want {
+			t.Errorf("event = %v, want %v", e, want)
+		}
+	case err := <-w.Errors:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+
+	select {
+	case e := <-w.Events:
+		t.Errorf("unexpected event %v", e)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWatcherMaxWait(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWatcher(&WatchOptions{Debounce: 100 * time.Millisecond, MaxWait: 300 * time.Millisecond})
+	if err != nil {
+		t.Skip("watcher not available:", err)
+	}
+	defer w.Close()
+
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// writes more often than the debounce period never go quiet
+	name := filepath.Join(dir, "log")
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+				ioutil.WriteFile(name, []byte("x"), 0644)
+			}
+		}
+	}()
+
+	select {
+	case e := <-w.Events:
+		if e.Path != name {
+			t.Errorf("event = %v, want %s", e, name)
+		}
+	case err := <-w.Errors:
+		t.Fatal(err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event while writing continuously")
+	}
+}
+
+func TestWatcherFile(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{"config.json": "{}", "other.json": "{}"})
+	w, err := NewWatcher(&WatchOptions{Debounce: 50 * time.Millisecond})
+	if err != nil {
+		t.Skip("watcher not available:", err)
+	}
+	defer w.Close()
+
+	name := filepath.Join(dir, "config.json")
+	if err := w.Add(name); err != nil {
+		t.Fatal(err)
+	}
+
+	// each save replaces the watched file
+	for i := 0; i < 2; i++ {
+		if err := WriteFileAtomic(name, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "other.json"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case e := <-w.Events:
+			if want := (Event{Path: name, Op: OpCreate}); e != want {
+				t.Errorf("save %d: event = %v, want %v", i, e, want)
+			}
+		case err := <-w.Errors:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("save %d: no event received", i)
+		}
+	}
+
+	select {
+	case e := <-w.Events:
+		t.Errorf("unexpected event %v", e)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := w.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Remove(name); err == nil {
+		t.Error("Remove() twice error = nil")
+	}
+}
+
+// nextEvents returns the events w delivers until none arrive for a
+// while.
+func nextEvents(t *testing.T, w *Watcher) []Event {
+	t.Helper()
+	var events []Event
+	timeout := 5 * time.Second
+	for {
+		select {
+		case e := <-w.Events:
+			events = append(events, e)
+			timeout = 300 * time.Millisecond
+		case err := <-w.Errors:
+			t.Fatal(err)
+		case <-time.After(timeout):
+			return events
+		}
+	}
+}
+
+func TestWatcherRenameDir(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{"a/sub/": ""})
+	w, err := NewWatcher(&WatchOptions{Recursive: true, Debounce: 50 * time.Millisecond})
+	if err != nil {
+		t.Skip("watcher not available:", err)
+	}
+	defer w.Close()
+
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	if err := os.Rename(a, b); err != nil {
+		t.Fatal(err)
+	}
+	want := []Event{{a, OpRename}, {b, OpCreate}, {filepath.Join(b, "sub"), OpCreate}}
+	if got := nextEvents(t, w); !reflect.DeepEqual(got, want) {
+		t.Errorf("rename events = %v, want %v", got, want)
+	}
+
+	// the renamed directories are still watched, under their new names
+	name := filepath.Join(b, "sub", "file")
+	if err := ioutil.WriteFile(name, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	want = []Event{{name, OpCreate}}
+	if got := nextEvents(t, w); !reflect.DeepEqual(got, want) {
+		t.Errorf("events after rename = %v, want %v", got, want)
+	}
+
+	if err := w.Remove(a); err == nil {
+		t.Errorf("Remove(%s) error = nil", a)
+	}
+	if err := w.Remove(b); err != nil {
+		t.Errorf("Remove(%s) error = %v", b, err)
+	}
+}
+
+func TestWatcherCloseConcurrent(t *testing.T) {
+	w, err := NewWatcher(nil)
+	if err != nil {
+		t.Skip("watcher not available:", err)
+	}
+
+	errc := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func() { errc <- w.Close() }()
+	}
+	for i := 0; i < 4; i++ {
+		<-errc
+	}
+	if _, ok := <-w.Events; ok {
+		t.Error("Events not closed")
+	}
+}
@@ -0,0 +1,45 @@
+package gofile
+
+import "strings"
+
+// Names of the common extended attributes of the freedesktop.org
+// CommonExtendedAttributes list, as used by browsers to record where a
+// download came from. They are in the user namespace.
+const (
+	XattrOriginURL   = "xdg.origin.url"
+	XattrReferrerURL = "xdg.referrer.url"
+)
+
+// xattrNamespace is the only namespace unprivileged users can write.
+const xattrNamespace = "user."
+
+// xattrName returns name in the user namespace. Names may be given with
+// or without the "user." prefix.
+func xattrName(name string) string {
+	return xattrNamespace + strings.TrimPrefix(name, xattrNamespace)
+}
+
+// XattrChecksum returns the attribute name for a checksum, such as
+// "checksum.sha256".
+func XattrChecksum(algo HashAlgorithm) string {
+	return "checksum." + string(algo)
+}
+
+// TagOrigin records the URL a file was downloaded from and its
+// checksum in extended attributes, the way browsers do. The checksum is
+// computed with algo and omitted if algo is empty. On file systems
+// without extended attributes it returns an error wrapping
+// ErrXattrUnsupported, which callers may ignore.
+func TagOrigin(name, url string, algo HashAlgorithm) error {
+	if err := SetXattr(name, XattrOriginURL, []byte(url)); err != nil {
+		return err
+	}
+	if algo == "" {
+		return nil
+	}
+	sum, err := Hash(name, algo)
+	if err != nil {
+		return err
+	}
+	return SetXattr(name, XattrChecksum(algo), []byte(sum))
+}
@@ -0,0 +1,111 @@
+package gofile
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// GetXattr returns the value of the extended attribute name of the
+// named file, following symlinks. Names are in the user namespace, so
+// "xdg.origin.url" reads "user.xdg.origin.url". A missing attribute,
+// or a file system without extended attributes, returns an error
+// wrapping ErrNoXattr.
+func GetXattr(path, name string) ([]byte, error) {
+	attr := xattrName(name)
+	for {
+		n, err := unix.Getxattr(path, attr, nil)
+		if err != nil {
+			return nil, newPathError("getxattr "+attr, path, xattrError(err, ErrNoXattr))
+		}
+		if n == 0 {
+			return []byte{}, nil
+		}
+
+		// the value may grow between the calls
+		buf := make([]byte, n)
+		n, err = unix.Getxattr(path, attr, buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, newPathError("getxattr "+attr, path, xattrError(err, ErrNoXattr))
+		}
+		return buf[:n], nil
+	}
+}
+
+// SetXattr sets the extended attribute name of the named file to value,
+// following symlinks. On file systems without extended attributes it
+// returns an error wrapping ErrXattrUnsupported.
+func SetXattr(path, name string, value []byte) error {
+	attr := xattrName(name)
+	if err := unix.Setxattr(path, attr, value, 0); err != nil {
+		return newPathError("setxattr "+attr, path, xattrError(err, ErrXattrUnsupported))
+	}
+	return nil
+}
+
+// ListXattr returns the sorted names of the extended attributes of the
+// named file in the user namespace, without the "user." prefix. A file
+// system without extended attributes lists none.
+func ListXattr(path string) ([]string, error) {
+	for {
+		n, err := unix.Listxattr(path, nil)
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, newPathError("listxattr", path, err)
+		}
+		if n == 0 {
+			return nil, nil
+		}
+
+		buf := make([]byte, n)
+		n, err = unix.Listxattr(path, buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, newPathError("listxattr", path, err)
+		}
+
+		var names []string
+		for _, attr := range strings.Split(string(buf[:n]), "\x00") {
+			if strings.HasPrefix(attr, xattrNamespace) {
+				names = append(names, strings.TrimPrefix(attr, xattrNamespace))
+			}
+		}
+		sort.Strings(names)
+		return names, nil
+	}
+}
+
+// RemoveXattr removes the extended attribute name of the named file.
+// A missing attribute, or a file system without extended attributes,
+// returns an error wrapping ErrNoXattr.
+func RemoveXattr(path, name string) error {
+	attr := xattrName(name)
+	if err := unix.Removexattr(path, attr); err != nil {
+		return newPathError("removexattr "+attr, path, xattrError(err, ErrNoXattr))
+	}
+	return nil
+}
+
+// xattrError maps the errno values of the xattr calls to sentinels.
+// unsupported is returned for file systems without extended attributes.
+func xattrError(err, unsupported error) error {
+	switch {
+	case errors.Is(err, unix.ENODATA):
+		return ErrNoXattr
+	case errors.Is(err, unix.ENOTSUP):
+		return unsupported
+	}
+	return err
+}
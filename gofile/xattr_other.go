@@ -0,0 +1,40 @@
+//go:build !linux
+// +build !linux
+
+package gofile
+
+import "os"
+
+// GetXattr returns an error wrapping ErrNoXattr on this platform, as
+// extended attributes are only implemented on Linux.
+func GetXattr(path, name string) ([]byte, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, newPathError("getxattr", path, err)
+	}
+	return nil, newPathError("getxattr "+xattrName(name), path, ErrNoXattr)
+}
+
+// SetXattr returns an error wrapping ErrXattrUnsupported on this
+// platform.
+func SetXattr(path, name string, value []byte) error {
+	if _, err := os.Stat(path); err != nil {
+		return newPathError("setxattr", path, err)
+	}
+	return newPathError("setxattr "+xattrName(name), path, ErrXattrUnsupported)
+}
+
+// ListXattr lists no attributes on this platform.
+func ListXattr(path string) ([]string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, newPathError("listxattr", path, err)
+	}
+	return nil, nil
+}
+
+// RemoveXattr returns an error wrapping ErrNoXattr on this platform.
+func RemoveXattr(path, name string) error {
+	if _, err := os.Stat(path); err != nil {
+		return newPathError("removexattr", path, err)
+	}
+	return newPathError("removexattr "+xattrName(name), path, ErrNoXattr)
+}
@@ -0,0 +1,113 @@
+package gofile
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// xattrFile returns a file for xattr tests, skipping the test if its
+// file system or platform has no extended attributes.
+func xattrFile(t *testing.T, contents string) string {
+	t.Helper()
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{"file": contents})
+	name := filepath.Join(dir, "file")
+	if err := SetXattr(name, "probe", nil); errors.Is(err, ErrXattrUnsupported) {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveXattr(name, "probe"); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestXattr(t *testing.T) {
+	name := xattrFile(t, "data")
+
+	if names, err := ListXattr(name); err != nil || len(names) > 0 {
+		t.Errorf("ListXattr() = %v, %v, want none", names, err)
+	}
+
+	attrs := []struct {
+		name  string
+		value string
+	}{
+		{XattrOriginURL, "https://example.com/file"},
+		{"user.note", "prefixed"},
+		{"empty", ""},
+		{"note", "replaced"},
+	}
+	for _, a := range attrs {
+		if err := SetXattr(name, a.name, []byte(a.value)); err != nil {
+			t.Fatalf("SetXattr(%s) error = %v", a.name, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"xdg.origin.url", "https://example.com/file"},
+		{"user.xdg.origin.url", "https://example.com/file"},
+		{"note", "replaced"},
+		{"empty", ""},
+	}
+	for _, tt := range tests {
+		got, err := GetXattr(name, tt.name)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("GetXattr(%s) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	want := []string{"empty", "note", "xdg.origin.url"}
+	if names, err := ListXattr(name); err != nil || !reflect.DeepEqual(names, want) {
+		t.Errorf("ListXattr() = %v, %v, want %v", names, err, want)
+	}
+
+	if err := RemoveXattr(name, "note"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetXattr(name, "note"); !errors.Is(err, ErrNoXattr) {
+		t.Errorf("GetXattr() after RemoveXattr() error = %v, want %v", err, ErrNoXattr)
+	}
+	if err := RemoveXattr(name, "note"); !errors.Is(err, ErrNoXattr) {
+		t.Errorf("RemoveXattr() twice error = %v, want %v", err, ErrNoXattr)
+	}
+}
+
+func TestXattrErrors(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	if _, err := GetXattr(missing, "a"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("GetXattr(missing) error = %v, want %v", err, ErrNotExist)
+	}
+	if err := SetXattr(missing, "a", nil); !errors.Is(err, ErrNotExist) {
+		t.Errorf("SetXattr(missing) error = %v, want %v", err, ErrNotExist)
+	}
+	if _, err := ListXattr(missing); !errors.Is(err, ErrNotExist) {
+		t.Errorf("ListXattr(missing) error = %v, want %v", err, ErrNotExist)
+	}
+	if err := RemoveXattr(missing, "a"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("RemoveXattr(missing) error = %v, want %v", err, ErrNotExist)
+	}
+}
+
+func TestTagOrigin(t *testing.T) {
+	name := xattrFile(t, "abc")
+
+	if err := TagOrigin(name, "https://example.com/abc", SHA256); err != nil {
+		t.Fatal(err)
+	}
+	origin, err := GetXattr(name, XattrOriginURL)
+	if err != nil || string(origin) != "https://example.com/abc" {
+		t.Errorf("origin = %q, %v", origin, err)
+	}
+	sum, err := GetXattr(name, XattrChecksum(SHA256))
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if err != nil || string(sum) != want {
+		t.Errorf("checksum = %q, %v, want %q", sum, err, want)
+	}
+}
@@ -2,13 +2,15 @@
 package http
 
 import (
-	"io"
 	"net/http"
 
 	"github.com/skeptycal/util/gofile"
 )
 
 // DownloadURL - download content from a URL to <filename>
+//
+// The file is written atomically; an interrupted download never
+// leaves a partial <filename> behind.
 func DownloadURL(url, filename string) error {
 	resp, err := http.Get(url)
 	if err != nil {
@@ -16,12 +18,28 @@ func DownloadURL(url, filename string) error {
 	}
 	defer resp.Body.Close()
 
-	f := gofile.Create(filename)
-	defer f.Close()
+	_, err = gofile.WriteAtomic(filename, resp.Body, 0644)
+	return err
+}
+
+// DownloadAndExtract - download an archive from a URL and extract it into <dir>
+//
+// The archive is downloaded to a temporary file, which is removed
+// afterwards. Its format is detected from the contents, and opts
+// (which may be nil) sets the filters and extraction limits.
+func DownloadAndExtract(url, dir string, opts *gofile.ArchiveOptions) error {
+	scope := gofile.NewTempScope()
+	defer scope.Close()
 
-	_, err = io.Copy(f, resp.Body)
+	f, err := scope.TempFile("", "download-*")
 	if err != nil {
 		return err
 	}
-	return nil
+	name := f.Name()
+	f.Close()
+
+	if err := DownloadURL(url, name); err != nil {
+		return err
+	}
+	return gofile.ExtractArchive(name, dir, opts)
 }
@@ -53,6 +53,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/skeptycal/util/gofile"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -371,15 +372,19 @@ func downloadYTVideo(videoURL string) error {
 	if err != nil {
 		return err
 	}
-	path := filepath.Join(outputDirectory, fileName)
+	// the file name comes from the video title
+	path, err := gofile.SafeJoin(outputDirectory, fileName)
+	if err != nil {
+		return fmt.Errorf("GoTube: Invalid file name %q: %v", fileName, err)
+	}
 
 	info(fmt.Sprintf("Creating a file %s...", path))
 
-	output, err := os.Create(path)
+	output, err := gofile.CreateAtomic(path, 0644)
 	if err != nil {
 		return fmt.Errorf("GoTube: Failed to create video file: %v", err)
 	}
-	defer output.Close()
+	defer output.Abort()
 
 	client := &http.Client{}
 
@@ -424,6 +429,10 @@ func downloadYTVideo(videoURL string) error {
 	if err != nil {
 		return fmt.Errorf("GoTube: Unable to download the video! :(")
 	}
+
+	if err := output.Close(); err != nil {
+		return fmt.Errorf("GoTube: Failed to save video file: %v", err)
+	}
 	info(messages["VideoSuccess"])
 
 	if audio {
@@ -435,7 +444,10 @@ func downloadYTVideo(videoURL string) error {
 }
 
 func saveAudio(outputDirectory, fileName, path string) error {
-	audioFile := filepath.Join(outputDirectory, strings.TrimRight(fileName, filepath.Ext(fileName))+".mp3")
+	audioFile, err := gofile.SafeJoin(outputDirectory, strings.TrimRight(fileName, filepath.Ext(fileName))+".mp3")
+	if err != nil {
+		return fmt.Errorf("GoTube: Invalid file name %q: %v", fileName, err)
+	}
 
 	info(fmt.Sprintf("Creating a file %s...", audioFile))
 
@@ -511,8 +523,16 @@ func main() {
 		flag.Usage()
 		os.Exit(1)
 	}
+	outdir, err := gofile.ExpandPath(outputDirectory)
+	if err != nil {
+		log.Fatal(err)
+	}
+	outputDirectory = outdir
+
+	// remove partial downloads if interrupted
+	gofile.CleanupOnSignal()
 
-	err := download(args)
+	err = download(args)
 	if err != nil {
 		log.Fatal(err)
 	}